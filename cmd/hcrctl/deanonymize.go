@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"adoption.latam/hcr/internal/pkg/anonymize"
+)
+
+// deanonymize opens the sealed mapping of an anonymized run and prints it as json. Given a file it
+// prints it with the original identifiers back, given several it rewrites them in place.
+func deanonymize(args []string) error {
+	fs := flag.NewFlagSet("deanonymize", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "file with the value of the spec.anonymize.keySecret key")
+	mapFile := fs.String("map", "", "sealed mapping, reportPath/anonymization/<namespace>/<config>/<run>.map")
+	out := fs.String("o", "", "write to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *keyFile == "" || *mapFile == "" {
+		return fmt.Errorf("-key and -map are mandatory")
+	}
+	key, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	sealed, err := os.ReadFile(*mapFile)
+	if err != nil {
+		return err
+	}
+	mapping, err := anonymize.Open(sealed, key)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		b, err := json.MarshalIndent(mapping, "", "  ")
+		if err != nil {
+			return err
+		}
+		return output(*out, b)
+	}
+	if fs.NArg() == 1 {
+		b, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		return output(*out, []byte(anonymize.Reverse(string(b), mapping)))
+	}
+	// several files are rewritten in place
+	if *out != "" {
+		return fmt.Errorf("-o takes a single file")
+	}
+	for _, f := range fs.Args() {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(f, []byte(anonymize.Reverse(string(b), mapping)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
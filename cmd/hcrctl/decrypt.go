@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+)
+
+// keyFiles collects the repeated -key flags naming files holding spec.encryption keys.
+type keyFiles []string
+
+func (k *keyFiles) String() string {
+	return strings.Join(*k, ",")
+}
+
+func (k *keyFiles) Set(file string) error {
+	*k = append(*k, file)
+	return nil
+}
+
+// use hands the keys to crypt for decryption only.
+func (k keyFiles) use() error {
+	keys := [][]byte{}
+	for _, f := range k {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, b)
+	}
+	crypt.Register("hcrctl", crypt.Keyring{Previous: keys})
+	return nil
+}
+
+// decrypt copies an encrypted reportPath, or any file or directory in it, to a clear one.
+func decrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keys := keyFiles{}
+	fs.Var(&keys, "key", "file with a spec.encryption key, repeat it for files under previous keys")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || len(keys) == 0 {
+		return fmt.Errorf("expected at least one -key, the source and the destination")
+	}
+	if err := keys.use(); err != nil {
+		return err
+	}
+	return crypt.Decrypt(fs.Arg(0), fs.Arg(1))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"adoption.latam/hcr/internal/pkg/diff"
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+// drift compares the dumps of two reportPath directories and prints the drift as json.
+func drift(args []string) error {
+	fs := flag.NewFlagSet("drift", flag.ContinueOnError)
+	out := fs.String("o", "", "write the drift json to this file instead of stdout")
+	dumpDir := fs.String("dump-dir", "dump", "dump directory inside each reportPath")
+	keys := keyFiles{}
+	fs.Var(&keys, "key", "file with a spec.encryption key for encrypted dumps, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("expected two reportPath directories")
+	}
+	if err := keys.use(); err != nil {
+		return err
+	}
+	before, err := dump.Load(filepath.Join(fs.Arg(0), *dumpDir))
+	if err != nil {
+		return err
+	}
+	after, err := dump.Load(filepath.Join(fs.Arg(1), *dumpDir))
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(diff.Objects(before, after), "", "  ")
+	if err != nil {
+		return err
+	}
+	return output(*out, b)
+}
@@ -0,0 +1,59 @@
+// hcrctl works on reportPath directories outside the cluster.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"deanonymize": {"deanonymize -key file -map file [-o file] [report files...]", deanonymize},
+	"decrypt":     {"decrypt -key file [-key file...] <encrypted file or directory> <destination>", decrypt},
+	"drift":       {"drift [-key file...] [-o file] <before reportPath> <after reportPath>", drift},
+	"pull":        {"pull [-authfile file] [-ca file] [-plain-http] [-o dir] [-list] <registry/repository[:tag]>", pull},
+	"redact":      {"redact [-spec file] [-o file] <source dump> <destination dump>", redactDump},
+	"upload":      {"upload -endpoint url -bucket name [-prefix p] [-ca file] [-sse type] [-create-bucket] <file or directory>", upload},
+	"verify":      {"verify -pub file [-key file...] [-o file] <run directory or bundle.tar.gz>", verify},
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "hcrctl "+flag.Arg(0)+": "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintln(os.Stderr, "  hcrctl "+commands[n].usage)
+	}
+}
+
+// output writes b to file or stdout when file is empty.
+func output(file string, b []byte) error {
+	if file == "" {
+		_, err := os.Stdout.Write(append(b, '\n'))
+		return err
+	}
+	return os.WriteFile(file, b, 0644)
+}
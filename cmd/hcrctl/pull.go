@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"adoption.latam/hcr/internal/pkg/oci"
+)
+
+// pull gets a bundle pushed by spec.sinks.oci into a directory and prints its annotations, or
+// with -list the tags of the repository. Credentials come from the podman or docker auth file.
+//
+//	hcrctl pull -list registry.example.com/hcr/reports
+//	hcrctl pull -o /tmp registry.example.com/hcr/reports:cluster-20261019T044256Z
+func pull(args []string) error {
+	fs := flag.NewFlagSet("pull", flag.ContinueOnError)
+	authFile := fs.String("authfile", "", "docker config json with the registry credentials, "+
+		"defaults to $REGISTRY_AUTH_FILE, $XDG_RUNTIME_DIR/containers/auth.json or ~/.docker/config.json")
+	caFile := fs.String("ca", "", "PEM file with the CA of the registry")
+	plainHTTP := fs.Bool("plain-http", false, "talk http to the registry")
+	dir := fs.String("o", ".", "directory to write the bundle to")
+	list := fs.Bool("list", false, "list the tags of the repository instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected the reference to pull")
+	}
+	ref, err := oci.ParseReference(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cfg := oci.Config{PlainHTTP: *plainHTTP}
+	if cfg.Auth, err = readAuthFile(*authFile); err != nil {
+		return err
+	}
+	if *caFile != "" {
+		if cfg.CA, err = os.ReadFile(*caFile); err != nil {
+			return err
+		}
+	}
+	client, err := oci.New(cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	var res any
+	if *list {
+		if res, err = client.Tags(ctx, ref); err != nil {
+			return err
+		}
+	} else {
+		if err = os.MkdirAll(*dir, 0755); err != nil {
+			return err
+		}
+		m, file, err := client.Pull(ctx, ref, *dir)
+		if err != nil {
+			return err
+		}
+		res = map[string]any{"reference": ref.String(), "file": file, "annotations": m.Annotations}
+	}
+	j, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	return output("", j)
+}
+
+// readAuthFile reads file or the first auth file found where podman and docker keep them,
+// nil when there is none.
+func readAuthFile(file string) ([]byte, error) {
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	candidates := []string{os.Getenv("REGISTRY_AUTH_FILE")}
+	if d := os.Getenv("XDG_RUNTIME_DIR"); d != "" {
+		candidates = append(candidates, filepath.Join(d, "containers", "auth.json"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, ".docker", "config.json"))
+	}
+	for _, c := range candidates {
+		if c == "" {
+			continue
+		}
+		if b, err := os.ReadFile(c); err == nil {
+			return b, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	"adoption.latam/hcr/internal/pkg/redact"
+)
+
+// redactDump redacts a dump taken outside the operator the way spec.redaction would and
+// prints the summary as json.
+func redactDump(args []string) error {
+	fs := flag.NewFlagSet("redact", flag.ContinueOnError)
+	specFile := fs.String("spec", "", "yaml or json file with the spec.redaction settings")
+	out := fs.String("o", "", "write the summary json to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("expected the source and destination dump directories")
+	}
+	spec := redact.DefaultSpec()
+	if *specFile != "" {
+		b, err := os.ReadFile(*specFile)
+		if err != nil {
+			return err
+		}
+		if err = yaml.Unmarshal(b, &spec); err != nil {
+			return err
+		}
+	}
+	r, err := redact.New(spec)
+	if err != nil {
+		return err
+	}
+	if err = r.Dir(fs.Arg(0), fs.Arg(1)); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(r.Summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return output(*out, b)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/s3"
+)
+
+// upload sends a file, usually a bundle, or every file of a directory to S3 compatible storage
+// the way spec.sinks.s3 does. Credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN. With a local MinIO:
+//
+//	hcrctl upload -endpoint http://localhost:9000 -bucket reports -create-bucket run.tar.gz
+func upload(args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	cfg := s3.Config{}
+	fs.StringVar(&cfg.Endpoint, "endpoint", "", "http(s)://host[:port] of the storage")
+	fs.StringVar(&cfg.Region, "region", s3.DefaultRegion, "region")
+	fs.StringVar(&cfg.Bucket, "bucket", "", "bucket")
+	fs.BoolVar(&cfg.VirtualHosted, "virtual-hosted", false, "address the bucket as a subdomain of the endpoint")
+	fs.StringVar(&cfg.SSE, "sse", "", "server side encryption, "+s3.SSES3+" or "+s3.SSEKMS)
+	fs.StringVar(&cfg.KMSKeyId, "kms-key-id", "", "kms key of "+s3.SSEKMS)
+	partSize := fs.Int64("part-size-mib", s3.DefaultPartSize>>20, "multipart upload part size")
+	caFile := fs.String("ca", "", "PEM file with the CA of the endpoint")
+	prefix := fs.String("prefix", "", "object name prefix")
+	create := fs.Bool("create-bucket", false, "create the bucket first")
+	out := fs.String("o", "", "write the results json to this file instead of stdout")
+	keys := keyFiles{}
+	fs.Var(&keys, "key", "file with a spec.encryption key for encrypted files, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected the file or directory to upload")
+	}
+	if err := keys.use(); err != nil {
+		return err
+	}
+	cfg.PartSize = *partSize << 20
+	cfg.Credentials = s3.Credentials{
+		AccessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if *caFile != "" {
+		b, err := os.ReadFile(*caFile)
+		if err != nil {
+			return err
+		}
+		cfg.CA = b
+	}
+	client, err := s3.New(cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if *create {
+		if err = client.CreateBucket(ctx); err != nil {
+			return err
+		}
+	}
+	src := fs.Arg(0)
+	results := []s3.Result{}
+	err = filepath.WalkDir(src, func(p string, e os.DirEntry, err error) error {
+		if err != nil || !e.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			rel = filepath.Base(p)
+		}
+		f, err := crypt.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r, err := client.Upload(ctx, path.Join(*prefix, filepath.ToSlash(rel)), f)
+		if err != nil {
+			return err
+		}
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return output(*out, b)
+}
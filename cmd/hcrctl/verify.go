@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"adoption.latam/hcr/internal/pkg/bundle"
+	"adoption.latam/hcr/internal/pkg/manifest"
+)
+
+// verify checks a run directory or bundle against its signed manifest and prints the report as
+// json. Anything but a complete match makes it fail.
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	pubFile := fs.String("pub", "", "file with the ed25519 public key, status.signature.publicKey of the Config")
+	out := fs.String("o", "", "write the report json to this file instead of stdout")
+	keys := keyFiles{}
+	fs.Var(&keys, "key", "file with a spec.encryption key for encrypted runs, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *pubFile == "" {
+		return fmt.Errorf("expected -pub and the run directory or bundle")
+	}
+	if err := keys.use(); err != nil {
+		return err
+	}
+	b, err := os.ReadFile(*pubFile)
+	if err != nil {
+		return err
+	}
+	pub, err := manifest.ParsePublicKey(b)
+	if err != nil {
+		return err
+	}
+	dir := fs.Arg(0)
+	if strings.HasSuffix(dir, bundle.Ext) {
+		tmp, err := os.MkdirTemp("", "hcr-verify")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		f, err := os.Open(dir)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if dir, err = bundle.Extract(f, tmp); err != nil {
+			return err
+		}
+	}
+	r, err := manifest.Verify(dir, pub)
+	if err != nil {
+		return err
+	}
+	j, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = output(*out, j); err != nil {
+		return err
+	}
+	if !r.Ok() {
+		return fmt.Errorf("%d modified, %d missing and %d unlisted artifacts", len(r.Modified), len(r.Missing), len(r.Unlisted))
+	}
+	return nil
+}
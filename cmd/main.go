@@ -17,19 +17,23 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrlRuntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	z "sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -41,6 +45,8 @@ import (
 
 	"github.com/mauricioscastro/kcdump/pkg/yjq"
 
+	"adoption.latam/hcr/internal/pkg/download"
+	"adoption.latam/hcr/internal/pkg/hcr"
 	"adoption.latam/hcr/internal/pkg/util"
 	"adoption.latam/hcr/internal/pkg/util/log"
 	webhookv1 "adoption.latam/hcr/internal/webhook/v1"
@@ -85,6 +91,8 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var tlsOpts []func(*tls.Config)
+	var downloadAddr, downloadCertPath string
+	var downloadMaxTTL time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -102,6 +110,11 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&downloadAddr, "download-bind-address", "0", "The address the run bundle download endpoint binds to. "+
+		"Use :8444 or leave as 0 to disable it.")
+	flag.StringVar(&downloadCertPath, "download-cert-path", "",
+		"The directory that contains the tls.crt and tls.key of the download endpoint. A self signed one is made when empty.")
+	flag.DurationVar(&downloadMaxTTL, "download-max-ttl", time.Hour, "The longest lifetime of a download token.")
 	opts := z.Options{
 		Development: true,
 	}
@@ -191,6 +204,9 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "18a88bba.adoption.latam",
+		// Secrets and ConfigMaps are read one at a time from the Config namespace, caching them
+		// would hold every Secret of the cluster in memory behind a cluster wide watch.
+		Client: client.Options{Cache: &client.CacheOptions{DisableFor: []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}}}},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -235,6 +251,20 @@ func main() {
 
 	// +kubebuilder:scaffold:builder
 
+	if downloadAddr != "0" {
+		srv := download.NewServer(mgr.GetClient(), downloadAddr, hcr.BundlesPath())
+		srv.CertDir = downloadCertPath
+		srv.MaxTTL = downloadMaxTTL
+		srv.TLSOpts = tlsOpts
+		srv.LoadKeys = func(ctx context.Context, namespace string, config string) error {
+			return hcr.LoadKeys(ctx, mgr.GetClient(), namespace, config)
+		}
+		if err := mgr.Add(srv); err != nil {
+			logger.Error("unable to set up the download endpoint", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		logger.Error("unable to set up health check", zap.Error(err))
 		os.Exit(1)
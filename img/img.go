@@ -0,0 +1,9 @@
+// Package img embeds the images shared by the README and the reports.
+package img
+
+import _ "embed"
+
+// Logo is hcreport.png.
+//
+//go:embed hcreport.png
+var Logo []byte
@@ -44,6 +44,10 @@ type ConfigReconciler struct {
 // +kubebuilder:rbac:groups=hcr.adoption.latam,resources=configs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=hcr.adoption.latam,resources=configs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=hcr.adoption.latam,resources=configs/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -66,7 +70,7 @@ func (r *ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		logger.Error("can not go past this error. returning", zap.Error(err))
 		return ctrl.Result{}, err
 	}
-	return hcr.NewReconciler(r.Status(), ctx, &cfg).Run()
+	return hcr.NewReconciler(r.Client, ctx, &cfg).Run()
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -81,7 +85,8 @@ func (r *ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 func filterUpdate() predicate.Predicate {
 	return predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			return e.ObjectNew.GetGeneration() != e.ObjectOld.GetGeneration()
+			return e.ObjectNew.GetGeneration() != e.ObjectOld.GetGeneration() ||
+				e.ObjectNew.GetAnnotations()[hcr.AnnotationApproveRemediation] != e.ObjectOld.GetAnnotations()[hcr.AnnotationApproveRemediation]
 		},
 	}
 }
@@ -0,0 +1,239 @@
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+const (
+	Namespace = "namespace"
+	Name      = "name"
+	Host      = "host"
+	Ip        = "ip"
+	Domain    = "domain"
+)
+
+var (
+	// DefaultKeep are the platform namespaces. Their names and the names of their objects are kept.
+	DefaultKeep = []string{`^(default|openshift|kube-system|kube-public|kube-node-lease)$`, `^(openshift|kube)-`}
+	wordRe      = regexp.MustCompile(`[A-Za-z0-9][A-Za-z0-9_.-]*`)
+	// tokenRe are the words and what looks like an ipv6 address, which wordRe would split at the colons.
+	tokenRe = regexp.MustCompile(`(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f.]*\b|` + wordRe.String())
+)
+
+// Spec is spec.anonymize without the key. Keep holds regular expressions of namespaces, and names of
+// cluster scoped objects, left as they are.
+// Domains are added to the cluster base and apps domains found in the dump.
+type Spec struct {
+	Keep    []string `json:"keep"`
+	Domains []string `json:"domains"`
+}
+
+// Entry is one identifier replaced in a report.
+type Entry struct {
+	Type     string `json:"type"`
+	Original string `json:"original"`
+}
+
+// Anonymizer replaces the namespaces, names, hostnames, ips and domains of a cluster
+// with pseudonyms derived from a key so they stay the same along a report.
+type Anonymizer struct {
+	key         []byte
+	hmacKey     []byte
+	keep        []*regexp.Regexp
+	identifiers map[string]string
+	domains     []string
+	mapping     map[string]Entry
+	reserved    map[string]bool
+}
+
+// New collects the identifiers to replace from the dump.
+func New(key []byte, spec Spec, d *dump.Dump) (*Anonymizer, error) {
+	if len(key) < 16 {
+		return nil, fmt.Errorf("the anonymization key must have at least 16 bytes")
+	}
+	if len(spec.Keep) == 0 {
+		spec.Keep = DefaultKeep
+	}
+	a := &Anonymizer{key: key, identifiers: map[string]string{}, mapping: map[string]Entry{}, reserved: map[string]bool{}}
+	var err error
+	if a.hmacKey, err = derive(key, pseudonymInfo); err != nil {
+		return nil, err
+	}
+	for _, k := range spec.Keep {
+		re, err := regexp.Compile(k)
+		if err != nil {
+			return nil, fmt.Errorf("keep %s: %w", k, err)
+		}
+		a.keep = append(a.keep, re)
+	}
+	for _, domain := range spec.Domains {
+		a.addDomain(domain)
+	}
+	for _, key := range d.Keys() {
+		for _, i := range d.Items(key) {
+			item, _ := i.(map[string]any)
+			a.collect(key, item)
+		}
+	}
+	return a, nil
+}
+
+// Reserve keeps words of the report vocabulary, severities, statuses or categories, as they are
+// even when an object has the same name.
+func (a *Anonymizer) Reserve(words ...string) {
+	for _, w := range words {
+		a.reserved[w] = true
+	}
+}
+
+func (a *Anonymizer) kept(ns string) bool {
+	for _, re := range a.keep {
+		if re.MatchString(ns) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Anonymizer) collect(key string, item map[string]any) {
+	meta, _ := item["metadata"].(map[string]any)
+	ns, _ := meta["namespace"].(string)
+	name, _ := meta["name"].(string)
+	switch {
+	case strings.HasPrefix(key, "namespaces."):
+		if !a.kept(name) {
+			a.add(Namespace, name)
+		}
+	case strings.HasPrefix(key, "nodes."):
+		a.add(Host, name)
+		status, _ := item["status"].(map[string]any)
+		addresses, _ := status["addresses"].([]any)
+		for _, ad := range addresses {
+			address, _ := ad.(map[string]any)
+			if s, _ := address["address"].(string); net.ParseIP(s) == nil && s != "" {
+				a.add(Host, s)
+			}
+		}
+	case strings.HasPrefix(key, "dnses.config.openshift.io/"):
+		spec, _ := item["spec"].(map[string]any)
+		if domain, _ := spec["baseDomain"].(string); domain != "" {
+			a.addDomain(domain)
+		}
+	case strings.HasPrefix(key, "ingresses.config.openshift.io/"):
+		spec, _ := item["spec"].(map[string]any)
+		if domain, _ := spec["domain"].(string); domain != "" {
+			a.addDomain(domain)
+		}
+	case ns != "" && !a.kept(ns):
+		a.add(Namespace, ns)
+		a.add(Name, name)
+	case ns == "" && !strings.Contains(key, ".openshift.io/") && !a.kept(name):
+		// cluster scoped objects, persistent volumes, storage classes, cluster roles or custom
+		// resources, but the cluster configuration and operators of the platform
+		a.add(Name, name)
+	}
+}
+
+func (a *Anonymizer) add(t string, s string) {
+	if _, ok := a.identifiers[s]; !ok && s != "" {
+		a.identifiers[s] = t
+	}
+}
+
+func (a *Anonymizer) addDomain(domain string) {
+	domain = strings.Trim(strings.ToLower(domain), ".")
+	for _, d := range a.domains {
+		if d == domain {
+			return
+		}
+	}
+	a.domains = append(a.domains, domain)
+	// the longest domains first so apps.cluster.example.com wins over example.com
+	sort.Slice(a.domains, func(i, j int) bool { return len(a.domains[i]) > len(a.domains[j]) })
+}
+
+// pseudonym is the type followed by the keyed hash of the original value.
+func (a *Anonymizer) pseudonym(t string, s string) string {
+	mac := hmac.New(sha256.New, a.hmacKey)
+	mac.Write([]byte(t + "\x00" + s))
+	p := t + "-" + hex.EncodeToString(mac.Sum(nil))[:10]
+	if t == Domain {
+		p += ".example"
+	}
+	a.mapping[p] = Entry{Type: t, Original: s}
+	return p
+}
+
+// hash keys h again, keeping its length, so the same hash stays the same along the reports
+// anonymized with the key but can not be computed from guessed names. It is not in the mapping.
+func (a *Anonymizer) hash(h string) string {
+	mac := hmac.New(sha256.New, a.hmacKey)
+	mac.Write([]byte("hash\x00" + h))
+	return hex.EncodeToString(mac.Sum(nil))[:len(h)]
+}
+
+// String replaces every known identifier, ip address and host under a known domain in s.
+// Only whole tokens are replaced so a name never changes the middle of a word, and character
+// references like &amp; are left alone.
+func (a *Anonymizer) String(s string) string {
+	return a.replace(s, tokenRe)
+}
+
+func (a *Anonymizer) replace(s string, re *regexp.Regexp) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		token := s[m[0]:m[1]]
+		out.WriteString(s[last:m[0]])
+		last = m[1]
+		if m[0] > 0 && s[m[0]-1] == '&' && m[1] < len(s) && s[m[1]] == ';' {
+			out.WriteString(token)
+			continue
+		}
+		trimmed := strings.TrimRight(token, ".")
+		if strings.Contains(trimmed, ":") && net.ParseIP(trimmed) == nil {
+			// a time or words joined by colons, not an address
+			out.WriteString(a.replace(token, wordRe))
+			continue
+		}
+		out.WriteString(a.token(trimmed) + token[len(trimmed):])
+	}
+	out.WriteString(s[last:])
+	return out.String()
+}
+
+func (a *Anonymizer) token(token string) string {
+	if a.reserved[token] {
+		return token
+	}
+	if t, ok := a.identifiers[token]; ok {
+		return a.pseudonym(t, token)
+	}
+	if ip := net.ParseIP(token); ip != nil && (strings.Contains(token, ":") || strings.Count(token, ".") == 3) {
+		return a.pseudonym(Ip, token)
+	}
+	lower := strings.ToLower(token)
+	for _, d := range a.domains {
+		if lower == d {
+			return a.pseudonym(Domain, token)
+		}
+		if strings.HasSuffix(lower, "."+d) {
+			return a.pseudonym(Host, strings.TrimSuffix(token, token[len(token)-len(d)-1:])) + "." + a.pseudonym(Domain, d)
+		}
+	}
+	return token
+}
+
+// Mapping is every pseudonym handed out so far with what it replaced.
+func (a *Anonymizer) Mapping() map[string]Entry {
+	return a.mapping
+}
@@ -0,0 +1,14 @@
+package anonymize_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAnonymize(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Anonymize Suite")
+}
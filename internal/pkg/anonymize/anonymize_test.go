@@ -0,0 +1,261 @@
+package anonymize_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/anonymize"
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+var key = []byte("0123456789abcdef0123456789abcdef")
+
+const cluster = `apiVersion: v1
+kind: NamespaceList
+metadata:
+  apiName: namespaces
+items:
+- metadata:
+    name: payments
+- metadata:
+    name: openshift-monitoring
+---
+apiVersion: v1
+kind: NodeList
+metadata:
+  apiName: nodes
+items:
+- metadata:
+    name: worker-0
+  status:
+    addresses:
+    - type: InternalIP
+      address: 10.0.0.5
+    - type: InternalIP
+      address: fd00:10::5
+    - type: Hostname
+      address: worker-0.internal
+---
+apiVersion: config.openshift.io/v1
+kind: DNSList
+metadata:
+  apiName: dnses
+items:
+- metadata:
+    name: cluster
+  spec:
+    baseDomain: prod.acme.com
+---
+apiVersion: config.openshift.io/v1
+kind: ClusterVersionList
+metadata:
+  apiName: clusterversions
+items:
+- metadata:
+    name: version
+---
+apiVersion: storage.k8s.io/v1
+kind: StorageClassList
+metadata:
+  apiName: storageclasses
+items:
+- metadata:
+    name: gold-tier
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleList
+metadata:
+  apiName: clusterroles
+items:
+- metadata:
+    name: payments-reader
+- metadata:
+    name: openshift-cluster-monitoring-view
+---
+apiVersion: v1
+kind: ConfigMapList
+metadata:
+  apiName: configmaps
+items:
+- metadata:
+    namespace: payments
+    name: ledger
+- metadata:
+    namespace: openshift-monitoring
+    name: cluster-monitoring-config
+`
+
+// anonymizer loads the cluster docs, one file each, and collects their identifiers.
+func anonymizer(spec anonymize.Spec) *anonymize.Anonymizer {
+	dir := GinkgoT().TempDir()
+	for i, doc := range strings.Split(cluster, "---\n") {
+		Expect(os.WriteFile(filepath.Join(dir, string(rune('a'+i))+".yaml"), []byte(doc), 0644)).To(Succeed())
+	}
+	d, err := dump.Load(dir)
+	Expect(err).NotTo(HaveOccurred())
+	a, err := anonymize.New(key, spec, d)
+	Expect(err).NotTo(HaveOccurred())
+	return a
+}
+
+var _ = Describe("Anonymize", func() {
+	It("rejects short keys and bad keep patterns", func() {
+		d, err := dump.Load(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = anonymize.New([]byte("short"), anonymize.Spec{}, d)
+		Expect(err).To(MatchError(ContainSubstring("at least 16 bytes")))
+		_, err = anonymize.New(key, anonymize.Spec{Keep: []string{"("}}, d)
+		Expect(err).To(MatchError(ContainSubstring("keep (")))
+	})
+
+	DescribeTable("strings",
+		func(s string, replaced []string, kept []string) {
+			a := anonymizer(anonymize.Spec{})
+			a.Reserve("critical")
+			out := a.String(s)
+			for _, r := range replaced {
+				Expect(out).NotTo(ContainSubstring(r))
+			}
+			for _, k := range kept {
+				Expect(out).To(ContainSubstring(k))
+			}
+			Expect(anonymize.Reverse(out, a.Mapping())).To(Equal(s))
+		},
+		Entry("namespaces and names of their objects", "payments/ledger is failing",
+			[]string{"payments", "ledger"}, []string{"namespace-", "name-", " is failing"}),
+		Entry("platform namespaces and their objects kept",
+			"openshift-monitoring/cluster-monitoring-config", nil, []string{"openshift-monitoring/cluster-monitoring-config"}),
+		Entry("nodes, their addresses and ips", "worker-0 (worker-0.internal, 10.0.0.5)",
+			[]string{"worker-0", "10.0.0.5"}, []string{"host-", "ip-"}),
+		Entry("ipv6 addresses", "from fd00:10::5 and 2001:db8::1.",
+			[]string{"fd00", "2001:db8::1"}, []string{"from ip-", " and ip-", "."}),
+		Entry("colons that are no address", "at 10:30:00 as system:serviceaccount:payments:ledger",
+			[]string{"payments", "ledger"}, []string{"at 10:30:00 as system:serviceaccount:namespace-"}),
+		Entry("cluster scoped objects", "gold-tier bound by payments-reader",
+			[]string{"gold-tier", "payments-reader"}, []string{"name-", " bound by name-"}),
+		Entry("platform cluster scoped objects kept", "version openshift-cluster-monitoring-view",
+			nil, []string{"version openshift-cluster-monitoring-view"}),
+		Entry("hosts under the base domain", "https://console.apps.prod.acme.com.",
+			[]string{"acme"}, []string{"https://host-", ".example."}),
+		Entry("whole tokens only", "paymentsx xledger", nil, []string{"paymentsx xledger"}),
+		Entry("character references", "&ledger; ledger", nil, []string{"&ledger; name-"}),
+		Entry("reserved words", "critical", nil, []string{"critical"}),
+	)
+
+	It("hands out the same pseudonym for the same key and another for other keys", func() {
+		a, b := anonymizer(anonymize.Spec{}), anonymizer(anonymize.Spec{})
+		Expect(a.String("payments")).To(Equal(b.String("payments")))
+		d, err := dump.Load(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		c, err := anonymize.New([]byte("fedcba9876543210fedcba9876543210"), anonymize.Spec{Domains: []string{"payments"}}, d)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.String("payments")).To(HavePrefix("domain-"))
+		Expect(c.String("payments")).NotTo(Equal(a.String("payments")))
+	})
+
+	It("keeps the namespaces asked for", func() {
+		a := anonymizer(anonymize.Spec{Keep: []string{"^payments$"}})
+		Expect(a.String("payments/ledger")).To(Equal("payments/ledger"))
+		Expect(a.String("openshift-monitoring")).To(HavePrefix("namespace-"), "keep replaces the default")
+	})
+
+	DescribeTable("sealed mappings",
+		func(open func([]byte) ([]byte, []byte), expected string) {
+			a := anonymizer(anonymize.Spec{})
+			a.String("payments/ledger")
+			sealed, err := a.Seal()
+			Expect(err).NotTo(HaveOccurred())
+			mapping, err := anonymize.Open(open(sealed))
+			if expected == "" {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mapping).To(Equal(a.Mapping()))
+			} else {
+				Expect(err).To(MatchError(ContainSubstring(expected)))
+			}
+		},
+		Entry("open with the key", func(s []byte) ([]byte, []byte) { return s, key }, ""),
+		Entry("do not open with another key",
+			func(s []byte) ([]byte, []byte) { return s, []byte("fedcba9876543210fedcba9876543210") }, "wrong key"),
+		Entry("do not open when tampered", func(s []byte) ([]byte, []byte) {
+			s[len(s)-1] ^= 1
+			return s, key
+		}, "corrupted"),
+		Entry("do not open when truncated", func(s []byte) ([]byte, []byte) { return s[:4], key }, "too short"),
+	)
+
+	DescribeTable("copies",
+		func(name string, content string, expected string) {
+			src, dst := GinkgoT().TempDir(), GinkgoT().TempDir()
+			Expect(os.MkdirAll(filepath.Join(src, "docs"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(src, "docs", name), []byte(content), 0644)).To(Succeed())
+			a := anonymizer(anonymize.Spec{})
+			Expect(a.Copy(src, dst)).To(Succeed())
+			b, err := os.ReadFile(filepath.Join(dst, "docs", name))
+			Expect(err).NotTo(HaveOccurred())
+			// $name and $ns stand for the pseudonyms of ledger and payments
+			expected = strings.NewReplacer("$name", a.String("ledger"), "$ns", a.String("payments")).Replace(expected)
+			Expect(string(b)).To(Equal(expected))
+			b, err = os.ReadFile(filepath.Join(src, "docs", name))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(Equal(content), "the source is left as it is")
+		},
+		Entry("json values but keys and vocabulary", "findings.json",
+			`{"checkId":"OBS-001","ledger":"payments/ledger","severity":"high"}`,
+			`{"checkId":"OBS-001","ledger":"$ns/$name","severity":"high"}`+"\n"),
+		Entry("yaml values but keys and vocabulary", "data.yaml",
+			"ledger:\n  name: ledger\n  kind: ledger\n",
+			"ledger:\n  name: $name\n  kind: ledger\n"),
+		Entry("html text and attributes but tags and structural attributes", "index.html",
+			`<td class="ledger" title="ledger">ledger</td>`,
+			`<td class="ledger" title="$name">$name</td>`),
+		Entry("markdown but link targets and attribute lists", "index.md",
+			"[ledger](ledger.md) ledger {#ledger}",
+			"[$name](ledger.md) $name {#ledger}"),
+		Entry("csv cells but the header", "findings.csv",
+			"ledger,namespace\nledger,payments\n",
+			"ledger,namespace\n$name,$ns\n"),
+	)
+
+	It("anonymizes the generated site config and copies unknown files as they are", func() {
+		src, dst := GinkgoT().TempDir(), GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(src, "mkdocs.yml"), []byte("site_name: ledger\nsite_author: payments\n"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(src, "logo.png"), []byte("ledger"), 0644)).To(Succeed())
+		a := anonymizer(anonymize.Spec{})
+		Expect(a.Copy(src, dst)).To(Succeed())
+		b, err := os.ReadFile(filepath.Join(dst, "mkdocs.yml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("site_name: " + a.String("ledger") + "\nsite_author: " + a.String("payments") + "\n"))
+		b, err = os.ReadFile(filepath.Join(dst, "logo.png"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("ledger"))
+	})
+
+	It("keys the fingerprints again", func() {
+		fp := check.Fingerprint(check.Finding{CheckId: "OBS-001", Kind: "ConfigMap", Namespace: "payments", Name: "ledger"})
+		doc := `{"fingerprint":"` + fp + `","partialFingerprints":{"hcreport/v1":"` + fp + `"}}`
+		copied := func(key []byte) string {
+			src, dst := GinkgoT().TempDir(), GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(src, "findings.json"), []byte(doc), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(src, "plan.yaml"), []byte("- fingerprint: "+fp+"\n"), 0644)).To(Succeed())
+			d, err := dump.Load(GinkgoT().TempDir())
+			Expect(err).NotTo(HaveOccurred())
+			a, err := anonymize.New(key, anonymize.Spec{}, d)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.Copy(src, dst)).To(Succeed())
+			j, err := os.ReadFile(filepath.Join(dst, "findings.json"))
+			Expect(err).NotTo(HaveOccurred())
+			y, err := os.ReadFile(filepath.Join(dst, "plan.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			return string(j) + string(y)
+		}
+		out := copied(key)
+		Expect(out).NotTo(ContainSubstring(fp))
+		Expect(out).To(Equal(copied(key)), "the same with the same key")
+		Expect(out).NotTo(Equal(copied([]byte("fedcba9876543210fedcba9876543210"))))
+	})
+})
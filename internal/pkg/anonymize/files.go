@@ -0,0 +1,291 @@
+package anonymize
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// vocabularyKeys hold values out of the checks and the report, never cluster identifiers.
+	vocabularyKeys = map[string]bool{
+		"checkId": true, "id": true, "severity": true, "status": true, "category": true, "kind": true,
+		"apiVersion": true, "lang": true, "grade": true, "fingerprint": true, "type": true, "level": true,
+		"ruleId": true, "framework": true, "version": true, "created": true, "date": true, "run": true,
+	}
+	// structuralAttrs are markup attributes never holding cluster identifiers.
+	structuralAttrs = map[string]bool{
+		"class": true, "id": true, "style": true, "type": true, "rel": true, "lang": true, "charset": true,
+		"for": true, "viewBox": true, "xmlns": true, "role": true, "data-sev": true, "data-cat": true,
+	}
+	markupRe  = regexp.MustCompile(`(?s)<!--.*?-->|<script\b[^>]*>.*?</script>|<style\b[^>]*>.*?</style>|<[^>]+>`)
+	attrRe    = regexp.MustCompile(`(\s[A-Za-z_:][-A-Za-z0-9_:.]*)=("[^"]*"|'[^']*')`)
+	jsonTagRe = regexp.MustCompile(`(?s)^(<script\b[^>]*type="application/json"[^>]*>)(.*)(</script>)$`)
+	// mdLinkRe are markdown link targets and attribute lists, left alone.
+	mdLinkRe = regexp.MustCompile(`\]\([^)]*\)|\{[:#.][^}]*\}`)
+	// hashRe are the finding fingerprints and other sha256 sums, unkeyed hashes of the objects they are about.
+	hashRe = regexp.MustCompile(`^(?:[0-9a-f]{32}|[0-9a-f]{64})$`)
+)
+
+// Copy writes an anonymized copy of every file under src into dst and leaves src as it is.
+// Files are anonymized by their structure: json and yaml string values but for vocabulary
+// fields, markup text and the attribute values that are not structural, csv cells but the
+// header and the text of the xlsx sheets. Keys, tags and other files are copied as they are.
+// Hashes like fingerprints would confirm guessed names, they are hashed again with the key.
+func (a *Anonymizer) Copy(src string, dst string) error {
+	return filepath.WalkDir(src, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if e.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !e.Type().IsRegular() {
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if b, err = a.content(e.Name(), b); err != nil {
+			return err
+		}
+		return os.WriteFile(target, b, 0644)
+	})
+}
+
+func (a *Anonymizer) content(name string, b []byte) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".sarif":
+		return a.json(b, false)
+	case ".jsonl":
+		lines := bytes.Split(b, []byte("\n"))
+		for i, l := range lines {
+			if len(bytes.TrimSpace(l)) == 0 {
+				continue
+			}
+			j, err := a.json(l, false)
+			if err != nil {
+				return nil, err
+			}
+			lines[i] = bytes.TrimRight(j, "\n")
+		}
+		return bytes.Join(lines, []byte("\n")), nil
+	case ".yaml", ".yml":
+		return a.yaml(b)
+	case ".html", ".xml", ".svg":
+		return []byte(a.markup(string(b), true)), nil
+	case ".md":
+		return []byte(a.markdown(string(b))), nil
+	case ".csv":
+		return a.csv(b)
+	case ".txt":
+		return []byte(a.String(string(b))), nil
+	case ".xlsx":
+		return a.xlsx(b)
+	}
+	return b, nil
+}
+
+// json anonymizes a json document, escapeHTML when it sits in a script.
+func (a *Anonymizer) json(b []byte, escapeHTML bool) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	e := json.NewEncoder(&out)
+	e.SetEscapeHTML(escapeHTML)
+	if bytes.Contains(b, []byte("\n  ")) {
+		e.SetIndent("", "  ")
+	}
+	if err := e.Encode(a.value("", v)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// value anonymizes the string values of v, key being the field holding v.
+func (a *Anonymizer) value(key string, v any) any {
+	switch t := v.(type) {
+	case string:
+		if hashRe.MatchString(t) {
+			return a.hash(t)
+		}
+		if vocabularyKeys[key] {
+			return t
+		}
+		return a.String(t)
+	case map[string]any:
+		for k, o := range t {
+			t[k] = a.value(k, o)
+		}
+	case []any:
+		for i, o := range t {
+			t[i] = a.value(key, o)
+		}
+	}
+	return v
+}
+
+func (a *Anonymizer) yaml(b []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	a.node("", &doc)
+	var out bytes.Buffer
+	e := yaml.NewEncoder(&out)
+	e.SetIndent(2)
+	if err := e.Encode(&doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), e.Close()
+}
+
+// node anonymizes the scalar values of n but the mapping keys, key being the field holding n.
+func (a *Anonymizer) node(key string, n *yaml.Node) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.Tag == "!!str" && hashRe.MatchString(n.Value) {
+			n.Value = a.hash(n.Value)
+		} else if n.Tag == "!!str" && !vocabularyKeys[key] {
+			n.Value = a.String(n.Value)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			a.node(n.Content[i].Value, n.Content[i+1])
+		}
+	default:
+		for _, c := range n.Content {
+			a.node(key, c)
+		}
+	}
+}
+
+// markup anonymizes the text of html or xml, the json of application/json scripts and, with
+// attrs, the attribute values that are not structural. Tags, comments, styles and code stay.
+func (a *Anonymizer) markup(s string, attrs bool) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range markupRe.FindAllStringIndex(s, -1) {
+		out.WriteString(a.String(s[last:m[0]]))
+		out.WriteString(a.tag(s[m[0]:m[1]], attrs))
+		last = m[1]
+	}
+	out.WriteString(a.String(s[last:]))
+	return out.String()
+}
+
+func (a *Anonymizer) tag(t string, attrs bool) string {
+	if strings.HasPrefix(t, "<!--") || strings.HasPrefix(t, "<style") {
+		return t
+	}
+	if strings.HasPrefix(t, "<script") {
+		m := jsonTagRe.FindStringSubmatch(t)
+		if m == nil {
+			return t
+		}
+		j, err := a.json([]byte(m[2]), true)
+		if err != nil {
+			return t
+		}
+		return m[1] + string(bytes.TrimRight(j, "\n")) + m[3]
+	}
+	if !attrs {
+		return t
+	}
+	return attrRe.ReplaceAllStringFunc(t, func(attr string) string {
+		m := attrRe.FindStringSubmatch(attr)
+		name := strings.TrimSpace(m[1])
+		if structuralAttrs[name] || strings.HasPrefix(name, "xmlns") {
+			return attr
+		}
+		q := m[2][:1]
+		return m[1] + "=" + q + a.String(m[2][1:len(m[2])-1]) + q
+	})
+}
+
+// markdown anonymizes like markup leaving the link targets and attribute lists alone.
+func (a *Anonymizer) markdown(s string) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range mdLinkRe.FindAllStringIndex(s, -1) {
+		out.WriteString(a.markup(s[last:m[0]], true))
+		out.WriteString(s[m[0]:m[1]])
+		last = m[1]
+	}
+	out.WriteString(a.markup(s[last:], true))
+	return out.String()
+}
+
+func (a *Anonymizer) csv(b []byte) ([]byte, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(records); i++ {
+		for j, c := range records[i] {
+			records[i][j] = a.String(c)
+		}
+	}
+	var out bytes.Buffer
+	w := csv.NewWriter(&out)
+	if err = w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// xlsx rewrites the workbook anonymizing the text of its xml parts.
+func (a *Anonymizer) xlsx(b []byte) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	w := zip.NewWriter(&out)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		part, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(f.Name, ".xml") {
+			part = []byte(a.markup(string(part), false))
+		}
+		fw, err := w.Create(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = fw.Write(part); err != nil {
+			return nil, err
+		}
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,80 @@
+package anonymize
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	pseudonymInfo = "hcr anonymization pseudonyms"
+	mappingInfo   = "hcr anonymization mapping"
+)
+
+func derive(key []byte, info string) ([]byte, error) {
+	return hkdf.Key(sha256.New, key, nil, info, 32)
+}
+
+func mappingCipher(key []byte) (cipher.AEAD, error) {
+	k, err := derive(key, mappingInfo)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts the mapping with AES-GCM under a key derived from key. The nonce leads the result.
+func (a *Anonymizer) Seal() ([]byte, error) {
+	j, err := json.Marshal(a.mapping)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := mappingCipher(a.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, j, nil), nil
+}
+
+// Open decrypts a sealed mapping with the key the report was anonymized with.
+func Open(sealed []byte, key []byte) (map[string]Entry, error) {
+	aead, err := mappingCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed mapping is too short")
+	}
+	j, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("wrong key or corrupted mapping: %w", err)
+	}
+	mapping := map[string]Entry{}
+	return mapping, json.Unmarshal(j, &mapping)
+}
+
+var pseudonymRe = regexp.MustCompile(`\b(?:` + strings.Join([]string{Namespace, Name, Host, Ip, Domain}, "|") + `)-[0-9a-f]{10}(?:\.example)?\b`)
+
+// Reverse puts back in s the originals of the pseudonyms found in mapping.
+func Reverse(s string, mapping map[string]Entry) string {
+	return pseudonymRe.ReplaceAllStringFunc(s, func(p string) string {
+		if e, ok := mapping[p]; ok {
+			return e.Original
+		}
+		return p
+	})
+}
@@ -0,0 +1,181 @@
+// Package bundle packages a run directory as a tar.gz for delivery.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+)
+
+const Ext = ".tar.gz"
+
+// Info describes a written bundle.
+type Info struct {
+	File   string `json:"file"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Path is where the bundle of run of the Config namespace/name is kept under root.
+func Path(root string, namespace string, name string, run string) string {
+	return filepath.Join(root, namespace, name, run+Ext)
+}
+
+// Write packs every file of dir under a top directory named after it into the tar.gz dst.
+// Encrypted files go in clear, dst itself is encrypted by whoever encrypts at rest.
+func Write(dir string, dst string) (Info, error) {
+	info := Info{File: dst}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return info, err
+	}
+	tmp := dst + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return info, err
+	}
+	defer os.Remove(tmp)
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, h))
+	tw := tar.NewWriter(gz)
+	top := filepath.Base(dir)
+	err = filepath.WalkDir(dir, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || !e.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		return add(tw, p, path.Join(top, filepath.ToSlash(rel)))
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return info, err
+	}
+	st, err := os.Stat(tmp)
+	if err != nil {
+		return info, err
+	}
+	info.Size = st.Size()
+	info.Sha256 = hex.EncodeToString(h.Sum(nil))
+	return info, os.Rename(tmp, dst)
+}
+
+// Prune removes all but the latest keep bundles under the Config directory dir and returns the
+// runs removed. Run ids sort by date, so the oldest bundles are the first by name.
+func Prune(dir string, keep int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	runs := []string{}
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), Ext) {
+			runs = append(runs, strings.TrimSuffix(e.Name(), Ext))
+		}
+	}
+	removed := []string{}
+	for _, run := range runs[:max(len(runs)-keep, 0)] {
+		if err = os.Remove(filepath.Join(dir, run+Ext)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, run)
+	}
+	return removed, nil
+}
+
+// add writes file in clear to tw. The clear size is only known once read so the file is
+// decrypted once to measure it when it is encrypted.
+func add(tw *tar.Writer, file string, name string) error {
+	st, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	size, err := clearSize(file)
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: st.ModTime(), Typeflag: tar.TypeReg}
+	if err = tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	r, err := crypt.Open(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(tw, r)
+	return err
+}
+
+func clearSize(file string) (int64, error) {
+	r, err := crypt.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.Copy(io.Discard, r)
+}
+
+// Extract unpacks the tar.gz read from r into dst and returns the top directory it held.
+func Extract(r io.Reader, dst string) (string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return "", err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	top := ""
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if !fs.ValidPath(name) {
+			return "", fmt.Errorf("bundle entry %s escapes the bundle", hdr.Name)
+		}
+		if t, _, _ := strings.Cut(name, "/"); top == "" {
+			top = t
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return "", err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dst, top), nil
+}
@@ -0,0 +1,98 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+)
+
+// tarball packs entries, names to contents, into a tar.gz.
+func tarball(entries ...[2]string) []byte {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		Expect(tw.WriteHeader(&tar.Header{Name: e[0], Mode: 0644, Size: int64(len(e[1])), Typeflag: tar.TypeReg})).To(Succeed())
+		_, err := tw.Write([]byte(e[1]))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(tw.Close()).To(Succeed())
+	Expect(gz.Close()).To(Succeed())
+	return b.Bytes()
+}
+
+var _ = Describe("Bundle", func() {
+	It("round trips a run decrypting its files", func() {
+		DeferCleanup(func() { crypt.Register("bundle-test", crypt.Keyring{}) })
+		keyring := crypt.Keyring{Key: []byte("0123456789abcdef0123456789abcdef")}
+		crypt.Register("bundle-test", keyring)
+		run := filepath.Join(GinkgoT().TempDir(), "20250101T000000Z")
+		Expect(os.MkdirAll(filepath.Join(run, "docs"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(run, "docs", "index.md"), []byte("# Report\n"), 0644)).To(Succeed())
+		Expect(keyring.WriteFile(filepath.Join(run, "findings.json"), []byte(`[]`), 0644)).To(Succeed())
+
+		file := Path(GinkgoT().TempDir(), "ns", "config", "20250101T000000Z")
+		info, err := Write(run, file)
+		Expect(err).NotTo(HaveOccurred())
+		b, err := os.ReadFile(file)
+		Expect(err).NotTo(HaveOccurred())
+		sum := sha256.Sum256(b)
+		Expect(info).To(Equal(Info{File: file, Size: int64(len(b)), Sha256: hex.EncodeToString(sum[:])}))
+
+		dst := GinkgoT().TempDir()
+		top, err := Extract(bytes.NewReader(b), dst)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(top).To(Equal(filepath.Join(dst, "20250101T000000Z")))
+		for name, content := range map[string]string{"docs/index.md": "# Report\n", "findings.json": "[]"} {
+			b, err := os.ReadFile(filepath.Join(top, name))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(Equal(content))
+		}
+	})
+
+	DescribeTable("extracting",
+		func(b []byte, expected string) {
+			dst := filepath.Join(GinkgoT().TempDir(), "out")
+			_, err := Extract(bytes.NewReader(b), dst)
+			if expected == "" {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(ContainSubstring(expected)))
+				_, err = os.Stat(filepath.Join(filepath.Dir(dst), "evil"))
+				Expect(os.IsNotExist(err)).To(BeTrue(), "nothing is written out of dst")
+			}
+		},
+		Entry("a run", tarball([2]string{"run/a.md", "a"}, [2]string{"run/docs/../b.md", "b"}), ""),
+		Entry("an entry climbing out", tarball([2]string{"run/../../evil", "x"}), "escapes the bundle"),
+		Entry("an absolute entry", tarball([2]string{"/evil", "x"}), "escapes the bundle"),
+		Entry("something else than a tar.gz", []byte("kind: ConfigMapList, not a bundle"), "gzip: invalid header"),
+	)
+
+	DescribeTable("pruning",
+		func(keep int, expected []string) {
+			dir := GinkgoT().TempDir()
+			for _, name := range []string{"20250103T000000Z.tar.gz", "20250101T000000Z.tar.gz", "20250102T000000Z.tar.gz", "notes.txt"} {
+				Expect(os.WriteFile(filepath.Join(dir, name), nil, 0644)).To(Succeed())
+			}
+			removed, err := Prune(dir, keep)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(Equal(expected))
+			for _, run := range removed {
+				Expect(filepath.Join(dir, run+Ext)).NotTo(BeAnExistingFile())
+			}
+			Expect(filepath.Join(dir, "notes.txt")).To(BeAnExistingFile())
+		},
+		Entry("the oldest past the limit", 1, []string{"20250101T000000Z", "20250102T000000Z"}),
+		Entry("nothing under the limit", 5, []string{}),
+		Entry("everything with no room", 0, []string{"20250101T000000Z", "20250102T000000Z", "20250103T000000Z"}),
+	)
+})
@@ -0,0 +1,329 @@
+package check
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/itchyny/gojq"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"adoption.latam/hcr/internal/pkg/dump"
+	"adoption.latam/hcr/internal/pkg/util/log"
+)
+
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
+
+	StatusPass         = "pass"
+	StatusFail         = "fail"
+	StatusError        = "error"
+	StatusNotEvaluated = "notEvaluated"
+)
+
+var (
+	logger = log.Logger().Named("hcr.check")
+	//go:embed packs/*.yaml
+	packsFS    embed.FS
+	Severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+)
+
+// Pack is a named bundle of checks shipped as yaml.
+type Pack struct {
+	Name   string  `json:"pack" yaml:"pack"`
+	Checks []Check `json:"checks" yaml:"checks"`
+}
+
+// Check is a jq query over the dumped resources it declares.
+// The query must yield one object per offending resource with the keys
+// namespace, kind, name, message and optionally apiVersion and evidence.
+// Besides the jq builtins a fromyaml function is available for yaml embedded in strings.
+// Subjects is a jq query over the same input yielding the objects the check judges, what the
+// score is normalized by. Without it every object of the resources counts as evaluated.
+// Autofix renders, like Snippet, a manifest the operator may server side apply to fix the
+// object once spec.remediation opts the check in and the plan is approved.
+type Check struct {
+	Id          string   `json:"id" yaml:"id"`
+	Title       string   `json:"title" yaml:"title"`
+	Category    string   `json:"category" yaml:"category"`
+	Severity    string   `json:"severity" yaml:"severity"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Remediation string   `json:"remediation,omitempty" yaml:"remediation,omitempty"`
+	Snippet     *Snippet `json:"snippet,omitempty" yaml:"snippet,omitempty"`
+	Autofix     *Snippet `json:"autofix,omitempty" yaml:"autofix,omitempty"`
+	// Controls maps a compliance framework (nist-800-53, pci-dss, cis-openshift...) to the ids of its controls the check evidences.
+	Controls map[string][]string `json:"controls,omitempty" yaml:"controls,omitempty"`
+	// Translations are keyed by language (pt-BR, es).
+	Translations map[string]Translation `json:"translations,omitempty" yaml:"translations,omitempty"`
+	Resources    []string               `json:"resources" yaml:"resources"`
+	Requires     []string               `json:"requires,omitempty" yaml:"requires,omitempty"`
+	Query        string                 `json:"query" yaml:"query"`
+	Subjects     string                 `json:"subjects,omitempty" yaml:"subjects,omitempty"`
+}
+
+type Finding struct {
+	CheckId     string `json:"checkId" yaml:"checkId"`
+	Title       string `json:"title" yaml:"title"`
+	Category    string `json:"category" yaml:"category"`
+	Severity    string `json:"severity" yaml:"severity"`
+	ApiVersion  string `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+	Kind        string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Namespace   string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	Message     string `json:"message" yaml:"message"`
+	Evidence    any    `json:"evidence,omitempty" yaml:"evidence,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty" yaml:"fingerprint,omitempty"`
+	Fix         *Fix   `json:"fix,omitempty" yaml:"fix,omitempty"`
+}
+
+type Result struct {
+	Check     Check     `json:"check" yaml:"check"`
+	Status    string    `json:"status" yaml:"status"`
+	Evaluated int       `json:"evaluated" yaml:"evaluated"`
+	Findings  []Finding `json:"findings,omitempty" yaml:"findings,omitempty"`
+	Error     string    `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// LoadPacks reads the embedded packs. An empty names list loads all of them.
+func LoadPacks(names ...string) ([]Check, error) {
+	files, err := packsFS.ReadDir("packs")
+	if err != nil {
+		return nil, err
+	}
+	checks := []Check{}
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
+		if len(names) > 0 && !slices.Contains(names, name) {
+			continue
+		}
+		b, err := packsFS.ReadFile("packs/" + f.Name())
+		if err != nil {
+			return nil, err
+		}
+		p, err := ParsePack(b)
+		if err != nil {
+			return nil, fmt.Errorf("pack %s: %w", name, err)
+		}
+		checks = append(checks, p.Checks...)
+	}
+	return checks, nil
+}
+
+func ParsePack(b []byte) (Pack, error) {
+	var p Pack
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return p, err
+	}
+	for i, c := range p.Checks {
+		if c.Id == "" || c.Query == "" {
+			return p, fmt.Errorf("check %d: id and query are mandatory", i)
+		}
+		if !slices.Contains(Severities, c.Severity) {
+			return p, fmt.Errorf("check %s: unknown severity '%s'", c.Id, c.Severity)
+		}
+		if c.Snippet != nil {
+			if err := c.Snippet.validate(); err != nil {
+				return p, fmt.Errorf("check %s: %w", c.Id, err)
+			}
+		}
+		if c.Autofix != nil {
+			if c.Autofix.Lang == "" {
+				c.Autofix.Lang = SnippetYaml
+			}
+			if err := c.Autofix.validate(); err != nil || c.Autofix.Lang != SnippetYaml {
+				return p, fmt.Errorf("check %s: autofix must be a yaml manifest template: %v", c.Id, err)
+			}
+		}
+		for lang, t := range c.Translations {
+			if err := t.validate(); err != nil {
+				return p, fmt.Errorf("check %s: translation %s: %w", c.Id, lang, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Evaluate runs every check against the dump. A failing query does not stop
+// the others and is recorded in its result.
+func Evaluate(d *dump.Dump, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		results = append(results, evaluate(d, c))
+	}
+	return results
+}
+
+func evaluate(d *dump.Dump, c Check) Result {
+	r := Result{Check: c, Status: StatusPass}
+	for _, req := range c.Requires {
+		if !d.Has(req) {
+			r.Status = StatusNotEvaluated
+			return r
+		}
+	}
+	input := make(map[string]any, len(c.Resources))
+	for _, res := range c.Resources {
+		items := d.Items(res)
+		input[res] = items
+		r.Evaluated += len(items)
+	}
+	findings, err := query(c, input)
+	if err == nil && c.Subjects != "" {
+		r.Evaluated, err = subjects(c, input)
+	}
+	if err != nil {
+		logger.Error("check query", zap.String("check", c.Id), zap.Error(err))
+		r.Status = StatusError
+		r.Error = err.Error()
+		return r
+	}
+	if c.Snippet != nil {
+		for i := range findings {
+			// a snippet that does not render leaves the finding without a fix
+			if err = c.Snippet.render(c, &findings[i], d); err != nil {
+				logger.Warn("check snippet", zap.String("check", c.Id), zap.Error(err))
+			}
+		}
+	}
+	if len(findings) > 0 {
+		r.Status = StatusFail
+		r.Findings = findings
+	}
+	return r
+}
+
+// compile parses a query of a check with the functions checks may call.
+func compile(s string) (*gojq.Code, error) {
+	q, err := gojq.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return gojq.Compile(q, gojq.WithFunction("fromyaml", 0, 0, fromYaml))
+}
+
+// subjects counts what the subjects query of c yields.
+func subjects(c Check, input map[string]any) (int, error) {
+	code, err := compile(c.Subjects)
+	if err != nil {
+		return 0, fmt.Errorf("subjects: %w", err)
+	}
+	n := 0
+	iter := code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return n, nil
+		}
+		if err, ok := v.(error); ok {
+			return 0, fmt.Errorf("subjects: %w", err)
+		}
+		n++
+	}
+}
+
+func query(c Check, input map[string]any) ([]Finding, error) {
+	code, err := compile(c.Query)
+	if err != nil {
+		return nil, err
+	}
+	findings := []Finding{}
+	iter := code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		f := Finding{}
+		if err = json.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("query output: %w", err)
+		}
+		f.CheckId = c.Id
+		f.Title = c.Title
+		f.Category = c.Category
+		f.Severity = c.Severity
+		f.Fingerprint = Fingerprint(f)
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+func fromYaml(v any, _ []any) any {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("fromyaml cannot be applied to: %v", v)
+	}
+	var doc any
+	if err := yaml.Unmarshal([]byte(s), &doc); err != nil {
+		return err
+	}
+	return dump.Normalize(doc)
+}
+
+// Findings flattens and sorts the findings of all results by severity, check and object.
+func Findings(results []Result) []Finding {
+	findings := []Finding{}
+	for _, r := range results {
+		findings = append(findings, r.Findings...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if sa, sb := SeverityRank(a.Severity), SeverityRank(b.Severity); sa != sb {
+			return sa < sb
+		}
+		return strings.Join([]string{a.CheckId, a.Namespace, a.Kind, a.Name}, "/") <
+			strings.Join([]string{b.CheckId, b.Namespace, b.Kind, b.Name}, "/")
+	})
+	return findings
+}
+
+// Fingerprint identifies a finding across runs by its check and object identity. The version
+// is left out of the api version so an object served by a newer version keeps its fingerprint.
+func Fingerprint(f Finding) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{f.CheckId, apiGroup(f.ApiVersion), f.Kind, f.Namespace, f.Name}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// apiGroup is the group of an api version, empty for the core group.
+func apiGroup(apiVersion string) string {
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		return apiVersion[:i]
+	}
+	return ""
+}
+
+// SeverityRank is 0 for critical and grows as severity decreases.
+func SeverityRank(s string) int {
+	if i := slices.Index(Severities, s); i >= 0 {
+		return i
+	}
+	return len(Severities)
+}
+
+func CountBySeverity(findings []Finding) map[string]int {
+	count := make(map[string]int, len(Severities))
+	for _, s := range Severities {
+		count[s] = 0
+	}
+	for _, f := range findings {
+		count[f.Severity]++
+	}
+	return count
+}
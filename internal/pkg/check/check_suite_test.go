@@ -0,0 +1,14 @@
+package check
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCheck(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Check Suite")
+}
@@ -0,0 +1,184 @@
+package check
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"slices"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+const configMaps = `apiVersion: v1
+kind: ConfigMapList
+metadata:
+  apiName: configmaps
+items:
+- metadata:
+    namespace: app
+    name: settings
+  data:
+    level: debug
+- metadata:
+    namespace: app
+    name: empty
+`
+
+// loadDump writes the resource lists docs as a kcdump directory and loads it.
+func loadDump(docs ...string) *dump.Dump {
+	dir := GinkgoT().TempDir()
+	for i, doc := range docs {
+		Expect(os.WriteFile(filepath.Join(dir, string(rune('a'+i))+".yaml"), []byte(doc), 0644)).To(Succeed())
+	}
+	d, err := dump.Load(dir)
+	Expect(err).NotTo(HaveOccurred())
+	return d
+}
+
+var _ = Describe("Check", func() {
+	DescribeTable("parsing packs",
+		func(pack string, expected string) {
+			_, err := ParsePack([]byte(pack))
+			if expected == "" {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(ContainSubstring(expected)))
+			}
+		},
+		Entry("a complete check", "pack: t\nchecks:\n- id: T-1\n  severity: high\n  query: .\n", ""),
+		Entry("a check without id", "pack: t\nchecks:\n- severity: high\n  query: .\n", "id and query are mandatory"),
+		Entry("a check without query", "pack: t\nchecks:\n- id: T-1\n  severity: high\n", "id and query are mandatory"),
+		Entry("an unknown severity", "pack: t\nchecks:\n- id: T-1\n  severity: urgent\n  query: .\n", "unknown severity 'urgent'"),
+		Entry("a snippet of an unknown lang", "pack: t\nchecks:\n- id: T-1\n  severity: low\n  query: .\n  snippet:\n    lang: python\n    template: x\n", "lang must be"),
+		Entry("a snippet that does not parse", "pack: t\nchecks:\n- id: T-1\n  severity: low\n  query: .\n  snippet:\n    lang: shell\n    template: '{{ .Finding'\n", "snippet"),
+		Entry("a shell autofix", "pack: t\nchecks:\n- id: T-1\n  severity: low\n  query: .\n  autofix:\n    lang: shell\n    template: x\n", "autofix must be a yaml manifest template"),
+	)
+
+	It("loads the embedded packs with unique ids", func() {
+		checks, err := LoadPacks()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checks).NotTo(BeEmpty())
+		ids := map[string]bool{}
+		for _, c := range checks {
+			Expect(ids).NotTo(HaveKey(c.Id))
+			ids[c.Id] = true
+		}
+	})
+
+	DescribeTable("evaluating a check",
+		func(c Check, status string, evaluated int, names []string) {
+			c.Id, c.Category, c.Severity = "T-1", "test", SeverityHigh
+			r := Evaluate(loadDump(configMaps), []Check{c})[0]
+			Expect(r.Status).To(Equal(status), r.Error)
+			Expect(r.Evaluated).To(Equal(evaluated))
+			found := []string{}
+			for _, f := range r.Findings {
+				Expect(f.CheckId).To(Equal("T-1"))
+				Expect(f.Severity).To(Equal(SeverityHigh))
+				Expect(f.Fingerprint).To(Equal(Fingerprint(f)))
+				found = append(found, f.Name)
+			}
+			Expect(found).To(Equal(names))
+		},
+		Entry("passes without findings",
+			Check{Resources: []string{"configmaps.v1"}, Query: `.["configmaps.v1"][] | select(.data == null and false)`},
+			StatusPass, 2, []string{}),
+		Entry("fails with a finding per object",
+			Check{Resources: []string{"configmaps.v1"}, Query: `.["configmaps.v1"][] | select(.data == null) | {kind: "ConfigMap", namespace: .metadata.namespace, name: .metadata.name, message: "empty"}`},
+			StatusFail, 2, []string{"empty"}),
+		Entry("is not evaluated when a required resource was not dumped",
+			Check{Resources: []string{"configmaps.v1"}, Requires: []string{"routes.route.openshift.io/v1"}, Query: `.`},
+			StatusNotEvaluated, 0, []string{}),
+		Entry("records a query error",
+			Check{Resources: []string{"configmaps.v1"}, Query: `.["configmaps.v1"] | error("boom")`},
+			StatusError, 2, []string{}),
+		Entry("counts only the subjects as evaluated",
+			Check{Resources: []string{"configmaps.v1"}, Subjects: `.["configmaps.v1"][] | select(.data != null)`, Query: `empty`},
+			StatusPass, 1, []string{}),
+		Entry("records a subjects error",
+			Check{Resources: []string{"configmaps.v1"}, Subjects: `error("boom")`, Query: `empty`},
+			StatusError, 0, []string{}),
+		Entry("reads yaml embedded in strings with fromyaml",
+			Check{Resources: []string{"configmaps.v1"}, Query: `.["configmaps.v1"][] | select((.data.level // "") | fromyaml == "debug") | {name: .metadata.name, message: "debug"}`},
+			StatusFail, 2, []string{"settings"}),
+	)
+
+	DescribeTable("OBS-007 over alertmanager.yaml",
+		func(alertmanager string, names []string) {
+			checks, err := LoadPacks()
+			Expect(err).NotTo(HaveOccurred())
+			i := slices.IndexFunc(checks, func(c Check) bool { return c.Id == "OBS-007" })
+			Expect(i).NotTo(Equal(-1))
+			secrets := "apiVersion: v1\nkind: SecretList\nmetadata:\n  apiName: secrets\nitems:\n" +
+				"- metadata:\n    namespace: openshift-monitoring\n    name: alertmanager-main\n  data:\n" +
+				"    alertmanager.yaml: " + base64.StdEncoding.EncodeToString([]byte(alertmanager)) + "\n"
+			r := Evaluate(loadDump(secrets), checks[i:i+1])[0]
+			Expect(r.Error).To(BeEmpty())
+			Expect(r.Evaluated).To(Equal(1), "the alertmanager-main secret")
+			found := []string{}
+			for _, f := range r.Findings {
+				found = append(found, f.Name)
+			}
+			Expect(found).To(Equal(names))
+		},
+		Entry("finds a config without receivers", "route:\n  receiver: default\n", []string{"alertmanager-main"}),
+		Entry("finds receivers without integrations", "receivers:\n- name: default\n", []string{"alertmanager-main"}),
+		Entry("passes a receiver with an integration", "receivers:\n- name: default\n- name: hook\n  webhook_configs:\n  - url: http://hook\n", []string{}),
+	)
+
+	It("renders the snippet of every finding against the dumped object", func() {
+		c := Check{Id: "T-1", Severity: SeverityLow, Resources: []string{"configmaps.v1"},
+			Query:   `.["configmaps.v1"][] | {apiVersion: "v1", kind: "ConfigMap", namespace: .metadata.namespace, name: .metadata.name, message: "m"}`,
+			Snippet: &Snippet{Lang: SnippetShell, Template: `oc -n {{ .Finding.Namespace }} label cm {{ .Finding.Name | quote }} level={{ with .Object.data }}{{ .level }}{{ else }}none{{ end }}`}}
+		r := Evaluate(loadDump(configMaps), []Check{c})[0]
+		Expect(r.Findings).To(HaveLen(2))
+		Expect(r.Findings[0].Fix).To(Equal(&Fix{Lang: SnippetShell, Text: "oc -n app label cm 'settings' level=debug"}))
+		Expect(r.Findings[1].Fix.Text).To(Equal("oc -n app label cm 'empty' level=none"))
+	})
+
+	DescribeTable("fingerprints",
+		func(a Finding, b Finding, same bool) {
+			Expect(Fingerprint(a) == Fingerprint(b)).To(Equal(same))
+		},
+		Entry("ignore the version of the api",
+			Finding{CheckId: "T-1", ApiVersion: "apps/v1beta1", Kind: "Deployment", Namespace: "app", Name: "web"},
+			Finding{CheckId: "T-1", ApiVersion: "apps/v1", Kind: "Deployment", Namespace: "app", Name: "web"}, true),
+		Entry("ignore the message",
+			Finding{CheckId: "T-1", Kind: "ConfigMap", Name: "a", Message: "one"},
+			Finding{CheckId: "T-1", Kind: "ConfigMap", Name: "a", Message: "two"}, true),
+		Entry("tell the groups apart",
+			Finding{CheckId: "T-1", ApiVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+			Finding{CheckId: "T-1", ApiVersion: "extensions/v1", Kind: "Deployment", Name: "web"}, false),
+		Entry("tell the checks apart",
+			Finding{CheckId: "T-1", Kind: "ConfigMap", Name: "a"},
+			Finding{CheckId: "T-2", Kind: "ConfigMap", Name: "a"}, false),
+		Entry("do not run fields together",
+			Finding{CheckId: "T-1", Namespace: "ab", Name: "c"},
+			Finding{CheckId: "T-1", Namespace: "a", Name: "bc"}, false),
+	)
+
+	DescribeTable("severity rank",
+		func(severity string, rank int) {
+			Expect(SeverityRank(severity)).To(Equal(rank))
+		},
+		Entry(nil, SeverityCritical, 0),
+		Entry(nil, SeverityInfo, 4),
+		Entry("unknown severities last", "urgent", 5),
+	)
+
+	It("sorts the findings by severity, check and object", func() {
+		results := []Result{
+			{Findings: []Finding{{CheckId: "B", Severity: SeverityLow, Name: "x"}, {CheckId: "B", Severity: SeverityCritical, Name: "y"}}},
+			{Findings: []Finding{{CheckId: "A", Severity: SeverityLow, Name: "z"}}},
+		}
+		names := []string{}
+		for _, f := range Findings(results) {
+			names = append(names, f.Name)
+		}
+		Expect(names).To(Equal([]string{"y", "z", "x"}))
+		Expect(CountBySeverity(Findings(results))).To(Equal(map[string]int{"critical": 1, "high": 0, "medium": 0, "low": 2, "info": 0}))
+	})
+})
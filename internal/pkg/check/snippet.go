@@ -0,0 +1,134 @@
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+const (
+	SnippetShell = "shell"
+	SnippetYaml  = "yaml"
+)
+
+// Snippet is a go template rendered for every finding of a check into a command (lang shell)
+// or a manifest fragment (lang yaml) fixing the object. The dot holds Check, Finding and
+// Object, the dumped object the finding points at when it can be found.
+type Snippet struct {
+	Lang     string `json:"lang" yaml:"lang"`
+	Template string `json:"template" yaml:"template"`
+}
+
+// Fix is a rendered snippet.
+type Fix struct {
+	Lang string `json:"lang" yaml:"lang"`
+	Text string `json:"text" yaml:"text"`
+}
+
+var snippetFuncs = template.FuncMap{
+	"toJson": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"toYaml": func(v any) (string, error) {
+		b, err := yaml.Marshal(v)
+		return strings.TrimSuffix(string(b), "\n"), err
+	},
+	"indent": func(n int, s string) string {
+		pad := strings.Repeat(" ", n)
+		return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+	// quote makes s a single shell word
+	"quote": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+	"default": func(d any, v any) any {
+		if v == nil || v == "" {
+			return d
+		}
+		return v
+	},
+	"lower":    strings.ToLower,
+	"group":    apiGroup,
+	"fromYaml": fromYamlText,
+	"merge":    merge,
+}
+
+func (s *Snippet) validate() error {
+	if s.Lang != SnippetShell && s.Lang != SnippetYaml {
+		return fmt.Errorf("snippet: lang must be %s or %s", SnippetShell, SnippetYaml)
+	}
+	_, err := template.New("snippet").Funcs(snippetFuncs).Option("missingkey=zero").Parse(s.Template)
+	return err
+}
+
+// Render executes the snippet for the finding f of check c.
+func (s *Snippet) Render(c Check, f Finding, d *dump.Dump) (string, error) {
+	return s.RenderObject(c, f, d.Find(f.ApiVersion, f.Kind, f.Namespace, f.Name))
+}
+
+// RenderObject renders the snippet with obj as .Object, the live object when the result is applied.
+func (s *Snippet) RenderObject(c Check, f Finding, obj any) (string, error) {
+	t, err := template.New(c.Id).Funcs(snippetFuncs).Option("missingkey=zero").Parse(s.Template)
+	if err != nil {
+		return "", err
+	}
+	data := map[string]any{
+		"Check":   c,
+		"Finding": f,
+		"Object":  obj,
+	}
+	var b bytes.Buffer
+	if err = t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// render fills f.Fix with the snippet rendered for f.
+func (s *Snippet) render(c Check, f *Finding, d *dump.Dump) error {
+	text, err := s.Render(c, *f, d)
+	if err != nil {
+		return err
+	}
+	f.Fix = &Fix{Lang: s.Lang, Text: text}
+	return nil
+}
+
+// fromYamlText decodes yaml text into json like values. Empty text is an empty map.
+func fromYamlText(s string) (map[string]any, error) {
+	m := map[string]any{}
+	if err := yaml.Unmarshal([]byte(s), &m); err != nil {
+		return nil, err
+	}
+	n, _ := dump.Normalize(m).(map[string]any)
+	if n == nil {
+		n = map[string]any{}
+	}
+	return n, nil
+}
+
+// merge deep merges the maps from left to right into a new map. Later values win and lists are replaced.
+func merge(maps ...map[string]any) map[string]any {
+	out := map[string]any{}
+	for _, m := range maps {
+		for k, v := range m {
+			if vm, ok := v.(map[string]any); ok {
+				if om, ok := out[k].(map[string]any); ok {
+					out[k] = merge(om, vm)
+					continue
+				}
+				out[k] = merge(vm)
+				continue
+			}
+			out[k] = v
+		}
+	}
+	return out
+}
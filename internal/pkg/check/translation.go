@@ -0,0 +1,84 @@
+package check
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Translation holds the texts of a check in one language. Empty texts keep the english ones.
+// Finding messages are built by the query so each Message is a regular expression matched
+// against the whole english message and its text may refer to the groups as $1 or ${name}.
+type Translation struct {
+	Title       string    `json:"title,omitempty" yaml:"title,omitempty"`
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Remediation string    `json:"remediation,omitempty" yaml:"remediation,omitempty"`
+	Messages    []Message `json:"messages,omitempty" yaml:"messages,omitempty"`
+}
+
+type Message struct {
+	Match string `json:"match" yaml:"match"`
+	Text  string `json:"text" yaml:"text"`
+}
+
+func (t Translation) validate() error {
+	for _, m := range t.Messages {
+		if _, err := regexp.Compile("^(?:" + m.Match + ")$"); err != nil {
+			return fmt.Errorf("message %s: %w", m.Match, err)
+		}
+	}
+	return nil
+}
+
+// Localized returns the check with its texts in lang. Checks without a translation stay in english.
+func (c Check) Localized(lang string) Check {
+	t, ok := c.Translations[lang]
+	if !ok {
+		return c
+	}
+	if t.Title != "" {
+		c.Title = t.Title
+	}
+	if t.Description != "" {
+		c.Description = t.Description
+	}
+	if t.Remediation != "" {
+		c.Remediation = t.Remediation
+	}
+	return c
+}
+
+// LocalizeFinding translates the title and message of f, a finding of c, to lang.
+func (c Check) LocalizeFinding(f Finding, lang string) Finding {
+	t, ok := c.Translations[lang]
+	if !ok {
+		return f
+	}
+	if t.Title != "" {
+		f.Title = t.Title
+	}
+	for _, m := range t.Messages {
+		re, err := regexp.Compile("^(?:" + m.Match + ")$")
+		if err != nil || !re.MatchString(f.Message) {
+			continue
+		}
+		f.Message = re.ReplaceAllString(f.Message, m.Text)
+		break
+	}
+	return f
+}
+
+// Localize returns a copy of results with the checks and findings in lang.
+func Localize(results []Result, lang string) []Result {
+	localized := make([]Result, len(results))
+	for i, r := range results {
+		localized[i] = r
+		localized[i].Check = r.Check.Localized(lang)
+		if r.Findings != nil {
+			localized[i].Findings = make([]Finding, len(r.Findings))
+			for j, f := range r.Findings {
+				localized[i].Findings[j] = r.Check.LocalizeFinding(f, lang)
+			}
+		}
+	}
+	return localized
+}
@@ -0,0 +1,128 @@
+package compliance
+
+import (
+	"sort"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+const (
+	StatusPass         = "pass"
+	StatusFail         = "fail"
+	StatusNotEvaluated = "notEvaluated"
+)
+
+// Titles names the frameworks checks are usually mapped to. Checks may use any other key.
+var Titles = map[string]string{
+	"cis-kubernetes": "CIS Kubernetes Benchmark",
+	"cis-openshift":  "CIS Red Hat OpenShift Container Platform Benchmark",
+	"nist-800-53":    "NIST SP 800-53",
+	"pci-dss":        "PCI DSS",
+}
+
+// Evidence is what a check contributed to a control.
+type Evidence struct {
+	CheckId   string          `json:"checkId"`
+	Title     string          `json:"title"`
+	Status    string          `json:"status"`
+	Evaluated int             `json:"evaluated"`
+	Findings  []check.Finding `json:"findings,omitempty"`
+}
+
+// Control fails when any of its checks failed, passes when at least one passed and the
+// others were not evaluated and is not evaluated when none of its checks could be.
+type Control struct {
+	Id       string     `json:"id"`
+	Status   string     `json:"status"`
+	Evidence []Evidence `json:"evidence"`
+}
+
+type Framework struct {
+	Name     string         `json:"name"`
+	Title    string         `json:"title"`
+	Summary  map[string]int `json:"summary"`
+	Controls []Control      `json:"controls"`
+}
+
+// Map groups the results by the framework controls their checks declare.
+func Map(results []check.Result) []Framework {
+	byFramework := map[string]map[string]*Control{}
+	for _, r := range results {
+		e := Evidence{CheckId: r.Check.Id, Title: r.Check.Title, Status: r.Status, Evaluated: r.Evaluated, Findings: r.Findings}
+		for fw, ids := range r.Check.Controls {
+			if byFramework[fw] == nil {
+				byFramework[fw] = map[string]*Control{}
+			}
+			for _, id := range ids {
+				c, ok := byFramework[fw][id]
+				if !ok {
+					c = &Control{Id: id}
+					byFramework[fw][id] = c
+				}
+				c.Evidence = append(c.Evidence, e)
+			}
+		}
+	}
+	frameworks := make([]Framework, 0, len(byFramework))
+	for name, controls := range byFramework {
+		fw := Framework{Name: name, Title: Titles[name], Controls: []Control{},
+			Summary: map[string]int{StatusPass: 0, StatusFail: 0, StatusNotEvaluated: 0}}
+		if fw.Title == "" {
+			fw.Title = name
+		}
+		for _, c := range controls {
+			c.Status = status(c.Evidence)
+			fw.Summary[c.Status]++
+			fw.Controls = append(fw.Controls, *c)
+		}
+		sort.Slice(fw.Controls, func(i, j int) bool { return controlLess(fw.Controls[i].Id, fw.Controls[j].Id) })
+		frameworks = append(frameworks, fw)
+	}
+	sort.Slice(frameworks, func(i, j int) bool { return frameworks[i].Name < frameworks[j].Name })
+	return frameworks
+}
+
+func status(evidence []Evidence) string {
+	s := StatusNotEvaluated
+	for _, e := range evidence {
+		switch e.Status {
+		case check.StatusFail:
+			return StatusFail
+		case check.StatusPass:
+			s = StatusPass
+		}
+	}
+	return s
+}
+
+// controlLess orders ids like AU-4 before AU-11 and 10.2.1 before 10.10 comparing digit runs as numbers.
+func controlLess(a string, b string) bool {
+	for a != "" && b != "" {
+		na, ra := leadingNumber(a)
+		nb, rb := leadingNumber(b)
+		switch {
+		case na >= 0 && nb >= 0:
+			if na != nb {
+				return na < nb
+			}
+			a, b = ra, rb
+		case a[0] != b[0]:
+			return a[0] < b[0]
+		default:
+			a, b = a[1:], b[1:]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// leadingNumber returns the number s starts with and the rest of s, or -1 when s starts otherwise.
+func leadingNumber(s string) (int, string) {
+	n, i := 0, 0
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		n = n*10 + int(s[i]-'0')
+	}
+	if i == 0 {
+		return -1, s
+	}
+	return n, s[i:]
+}
@@ -0,0 +1,14 @@
+package compliance
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCompliance(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Compliance Suite")
+}
@@ -0,0 +1,65 @@
+package compliance
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+func result(id string, status string, controls map[string][]string) check.Result {
+	return check.Result{Check: check.Check{Id: id, Title: id, Controls: controls}, Status: status}
+}
+
+var _ = Describe("Compliance", func() {
+	DescribeTable("ordering control ids",
+		func(a string, b string, less bool) {
+			Expect(controlLess(a, b)).To(Equal(less))
+			if a != b {
+				Expect(controlLess(b, a)).To(Equal(!less))
+			}
+		},
+		Entry("numbers by value", "AU-4", "AU-11", true),
+		Entry("dotted numbers by value", "10.2.1", "10.10", true),
+		Entry("a prefix first", "10.2", "10.2.1", true),
+		Entry("letters by code", "AC-2", "AU-2", true),
+		Entry("enhancements after their control", "AC-2", "AC-2(1)", true),
+		Entry("enhancements by value", "AC-2(4)", "AC-2(12)", true),
+		Entry("cis sections", "1.2.9", "1.2.10", true),
+		Entry("equal ids", "AU-4", "AU-4", false),
+		Entry("leading zeros by value", "AU-04", "AU-5", true),
+	)
+
+	DescribeTable("control status",
+		func(statuses []string, expected string) {
+			results := []check.Result{}
+			for i, s := range statuses {
+				results = append(results, result(string(rune('A'+i)), s, map[string][]string{"nist-800-53": {"AU-4"}}))
+			}
+			frameworks := Map(results)
+			Expect(frameworks).To(HaveLen(1))
+			Expect(frameworks[0].Controls).To(HaveLen(1))
+			Expect(frameworks[0].Controls[0].Status).To(Equal(expected))
+			Expect(frameworks[0].Controls[0].Evidence).To(HaveLen(len(statuses)))
+			Expect(frameworks[0].Summary[expected]).To(Equal(1))
+		},
+		Entry("fails when any check failed", []string{check.StatusPass, check.StatusFail, check.StatusNotEvaluated}, StatusFail),
+		Entry("passes when one passed and the others were not evaluated", []string{check.StatusNotEvaluated, check.StatusPass}, StatusPass),
+		Entry("is not evaluated when no check could be", []string{check.StatusNotEvaluated, check.StatusError}, StatusNotEvaluated),
+	)
+
+	It("groups the controls by framework in order", func() {
+		frameworks := Map([]check.Result{
+			result("A", check.StatusPass, map[string][]string{"pci-dss": {"10.10", "10.2.1"}, "internal": {"X-1"}}),
+			result("B", check.StatusFail, map[string][]string{"pci-dss": {"10.2.1"}}),
+		})
+		Expect(frameworks).To(HaveLen(2))
+		Expect(frameworks[0].Name).To(Equal("internal"))
+		Expect(frameworks[0].Title).To(Equal("internal"), "unknown frameworks are titled by name")
+		Expect(frameworks[1].Title).To(Equal(Titles["pci-dss"]))
+		Expect(frameworks[1].Controls[0].Id).To(Equal("10.2.1"))
+		Expect(frameworks[1].Controls[0].Status).To(Equal(StatusFail))
+		Expect(frameworks[1].Controls[1].Id).To(Equal("10.10"))
+		Expect(frameworks[1].Summary).To(Equal(map[string]int{StatusPass: 1, StatusFail: 1, StatusNotEvaluated: 0}))
+	})
+})
@@ -0,0 +1,204 @@
+// Package crypt encrypts files at rest with AES-256-GCM in 64KiB chunks so neither side holds
+// more than a chunk in memory. A file is the magic, the id of the key it was sealed with, a
+// random salt and the chunks. Every file gets its own key derived from the salt and the last
+// chunk is flagged in its nonce so a truncated file does not decrypt.
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	chunkSize = 64 * 1024
+	idSize    = 8
+	saltSize  = 16
+	// MinKeySize is the shortest key accepted.
+	MinKeySize = 16
+)
+
+var (
+	magic      = []byte("hcrenc1\n")
+	headerSize = len(magic) + idSize + saltSize
+)
+
+// KeyId identifies key in file headers and status without revealing it.
+func KeyId(key []byte) string {
+	return hex.EncodeToString(keyId(key))
+}
+
+func keyId(key []byte) []byte {
+	id, _ := hkdf.Key(sha256.New, key, nil, "hcr encryption key id", idSize)
+	return id
+}
+
+func fileCipher(key []byte, salt []byte) (cipher.AEAD, error) {
+	k, err := hkdf.Key(sha256.New, key, salt, "hcr encryption at rest", 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func nonce(counter uint64, last bool) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[3:11], counter)
+	if last {
+		n[11] = 1
+	}
+	return n
+}
+
+type writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+// NewWriter encrypts what is written to it into w. Close writes the last chunk and must be called.
+func NewWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	if len(key) < MinKeySize {
+		return nil, fmt.Errorf("encryption key must have at least %d bytes", MinKeySize)
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := fileCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	header := append(append(append([]byte{}, magic...), keyId(key)...), salt...)
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+	return &writer{w: w, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write on closed encryption writer")
+	}
+	n := len(p)
+	for len(p) > 0 {
+		// a full chunk is only sealed once more data shows up so the last one is always flagged
+		if len(w.buf) == chunkSize {
+			if err := w.seal(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		c := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+c]
+		p = p[c:]
+	}
+	return n, nil
+}
+
+func (w *writer) seal(last bool) error {
+	_, err := w.w.Write(w.aead.Seal(nil, nonce(w.counter, last), w.buf, nil))
+	w.counter++
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+type reader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	chunk   []byte
+	buf     []byte
+	counter uint64
+	done    bool
+}
+
+// NewReader decrypts r with the one of keys it was encrypted with.
+func NewReader(r io.Reader, keys ...[]byte) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, chunkSize)
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("encrypted header: %w", err)
+	}
+	if !bytes.Equal(header[:len(magic)], magic) {
+		return nil, errors.New("not an encrypted file")
+	}
+	id := header[len(magic) : len(magic)+idSize]
+	for _, k := range keys {
+		if bytes.Equal(keyId(k), id) {
+			aead, err := fileCipher(k, header[len(magic)+idSize:])
+			if err != nil {
+				return nil, err
+			}
+			return &reader{r: br, aead: aead, chunk: make([]byte, chunkSize+aead.Overhead())}, nil
+		}
+	}
+	return nil, fmt.Errorf("no key with id %s", hex.EncodeToString(id))
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *reader) open() error {
+	n, err := io.ReadFull(r.r, r.chunk)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		_, err = r.r.Peek(1)
+		last = err == io.EOF
+	}
+	if n < r.aead.Overhead() {
+		return errors.New("encrypted file is truncated")
+	}
+	r.buf, err = r.aead.Open(r.chunk[:0], nonce(r.counter, last), r.chunk[:n], nil)
+	if err != nil {
+		return fmt.Errorf("encrypted chunk %d: %w", r.counter, err)
+	}
+	r.counter++
+	r.done = last
+	return nil
+}
+
+// Encrypted tells if header, the first bytes of a file, is the one of an encrypted file and returns its key id.
+func Encrypted(header []byte) (string, bool) {
+	if len(header) < len(magic)+idSize || !bytes.Equal(header[:len(magic)], magic) {
+		return "", false
+	}
+	return hex.EncodeToString(header[len(magic) : len(magic)+idSize]), true
+}
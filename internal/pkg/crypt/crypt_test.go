@@ -0,0 +1,154 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	key     = []byte("0123456789abcdef0123456789abcdef")
+	nextKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+// seal encrypts size random bytes with key.
+func seal(size int) ([]byte, []byte) {
+	plain := make([]byte, size)
+	_, err := rand.Read(plain)
+	Expect(err).NotTo(HaveOccurred())
+	var out bytes.Buffer
+	w, err := NewWriter(&out, key)
+	Expect(err).NotTo(HaveOccurred())
+	_, err = w.Write(plain)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(w.Close()).To(Succeed())
+	return plain, out.Bytes()
+}
+
+func open(sealed []byte, keys ...[]byte) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(sealed), keys...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// chunk is the size of a sealed chunk.
+const chunk = chunkSize + 16
+
+var _ = Describe("Crypt", func() {
+	It("rejects short keys", func() {
+		_, err := NewWriter(io.Discard, []byte("short"))
+		Expect(err).To(MatchError(ContainSubstring("at least 16 bytes")))
+	})
+
+	DescribeTable("round trips",
+		func(size int) {
+			plain, sealed := seal(size)
+			id, ok := Encrypted(sealed)
+			Expect(ok).To(BeTrue())
+			Expect(id).To(Equal(KeyId(key)))
+			b, err := open(sealed, nextKey, key)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b).To(Equal(plain))
+		},
+		Entry("an empty file", 0),
+		Entry("a short file", 1),
+		Entry("exactly one chunk", chunkSize),
+		Entry("one byte past a chunk", chunkSize+1),
+		Entry("several chunks", 3*chunkSize+100),
+	)
+
+	DescribeTable("damaged files",
+		func(size int, damage func([]byte) []byte, expected string) {
+			_, sealed := seal(size)
+			_, err := open(damage(sealed), key)
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+		},
+		Entry("truncated at a chunk boundary", 2*chunkSize+10,
+			func(s []byte) []byte { return s[:headerSize+2*chunk] }, "encrypted chunk 1"),
+		Entry("truncated in the middle of a chunk", 2*chunkSize,
+			func(s []byte) []byte { return s[:headerSize+chunk+100] }, "encrypted chunk 1"),
+		Entry("truncated in the tag of the last chunk", 10,
+			func(s []byte) []byte { return s[:headerSize+8] }, "truncated"),
+		Entry("with the last chunk dropped", 10,
+			func(s []byte) []byte { return s[:headerSize] }, "truncated"),
+		Entry("truncated in the header", 10,
+			func(s []byte) []byte { return s[:10] }, "encrypted header"),
+		Entry("with a flipped bit", 10,
+			func(s []byte) []byte { s[headerSize+3] ^= 1; return s }, "encrypted chunk 0"),
+		Entry("with swapped chunks", 2*chunkSize+10, func(s []byte) []byte {
+			a := append([]byte{}, s[headerSize:headerSize+chunk]...)
+			copy(s[headerSize:], s[headerSize+chunk:headerSize+2*chunk])
+			copy(s[headerSize+chunk:], a)
+			return s
+		}, "encrypted chunk 0"),
+		Entry("with the key id of another key", 10,
+			func(s []byte) []byte { copy(s[len(magic):], keyId(nextKey)); return s }, "no key with id"),
+		Entry("in clear", 10,
+			func(s []byte) []byte { return []byte("kind: ConfigMapList, not encrypted") }, "not an encrypted file"),
+	)
+
+	It("does not decrypt without the key", func() {
+		_, sealed := seal(10)
+		_, err := open(sealed, nextKey)
+		Expect(err).To(MatchError(ContainSubstring("no key with id " + KeyId(key))))
+	})
+
+	It("rotates the files under a directory to a new key", func() {
+		DeferCleanup(func() { Register("crypt-test", Keyring{}) })
+		dir := GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(dir, "runs"), 0755)).To(Succeed())
+		old := Keyring{Key: key}
+		Expect(old.WriteFile(filepath.Join(dir, "runs", "sealed.json"), []byte("sealed"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "clear.json"), []byte("clear"), 0644)).To(Succeed())
+
+		Register("crypt-test", Keyring{Key: nextKey, Previous: [][]byte{key}})
+		s, err := Keyring{Key: nextKey}.Encrypt(dir, filepath.Join(dir, "missing"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s).To(Equal(Summary{KeyId: KeyId(nextKey), Encrypted: 1, Rotated: 1}))
+		s, err = Keyring{Key: nextKey}.Encrypt(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s).To(Equal(Summary{KeyId: KeyId(nextKey), Unchanged: 2}))
+
+		Register("crypt-test", Keyring{Key: nextKey})
+		for name, content := range map[string]string{"runs/sealed.json": "sealed", "clear.json": "clear"} {
+			b, err := os.ReadFile(filepath.Join(dir, name))
+			Expect(err).NotTo(HaveOccurred())
+			id, ok := Encrypted(b)
+			Expect(ok).To(BeTrue())
+			Expect(id).To(Equal(KeyId(nextKey)))
+			b, err = ReadFile(filepath.Join(dir, name))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(Equal(content))
+		}
+		info, err := os.Stat(filepath.Join(dir, "clear.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0644)), "the mode is kept")
+
+		out := GinkgoT().TempDir()
+		Expect(Decrypt(dir, out)).To(Succeed())
+		b, err := os.ReadFile(filepath.Join(out, "runs", "sealed.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("sealed"))
+
+		Register("crypt-test", Keyring{})
+		_, err = ReadFile(filepath.Join(dir, "clear.json"))
+		Expect(err).To(MatchError(ContainSubstring("no key with id")))
+	})
+
+	It("reads and writes in clear without a key", func() {
+		file := filepath.Join(GinkgoT().TempDir(), "clear.json")
+		Expect(Keyring{}.WriteFile(file, []byte("clear"), 0644)).To(Succeed())
+		b, err := ReadFile(file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("clear"))
+		_, err = Keyring{}.Encrypt(file)
+		Expect(err).To(MatchError("no encryption key in use"))
+	})
+})
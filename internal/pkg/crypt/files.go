@@ -0,0 +1,242 @@
+package crypt
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const tmpSuffix = ".hcrenc.tmp"
+
+// Keyring holds the keys of one owner, a Config. Key encrypts, a nil one turns encryption off,
+// and Key plus Previous decrypt.
+type Keyring struct {
+	Key      []byte
+	Previous [][]byte
+}
+
+// the keyrings set by Register by owner.
+var (
+	keyringsLock sync.RWMutex
+	keyrings     = map[string]Keyring{}
+)
+
+// Register sets the keyring of owner. Files decrypt with the keys of every registered owner
+// since the owners share the files they read.
+func Register(owner string, k Keyring) {
+	keyringsLock.Lock()
+	defer keyringsLock.Unlock()
+	keyrings[owner] = k
+}
+
+// decryptionKeys are the keys of every registered keyring.
+func decryptionKeys() [][]byte {
+	keyringsLock.RLock()
+	defer keyringsLock.RUnlock()
+	keys := [][]byte{}
+	for _, k := range keyrings {
+		if k.Key != nil {
+			keys = append(keys, k.Key)
+		}
+		keys = append(keys, k.Previous...)
+	}
+	return keys
+}
+
+// Enabled tells if k has a key to encrypt with.
+func (k Keyring) Enabled() bool {
+	return k.Key != nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Open opens file for reading decrypting it when it is encrypted.
+func Open(file string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	header, _ := br.Peek(headerSize)
+	if _, ok := Encrypted(header); !ok {
+		return readCloser{br, f}, nil
+	}
+	r, err := NewReader(br, decryptionKeys()...)
+	if err != nil {
+		f.Close()
+		return nil, &fs.PathError{Op: "decrypt", Path: file, Err: err}
+	}
+	return readCloser{r, f}, nil
+}
+
+// ReadFile is os.ReadFile decrypting encrypted files.
+func ReadFile(file string) ([]byte, error) {
+	f, err := Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// WriteFile is os.WriteFile encrypting with the key of k when there is one.
+func (k Keyring) WriteFile(file string, b []byte, perm fs.FileMode) error {
+	key := k.Key
+	if key == nil {
+		return os.WriteFile(file, b, perm)
+	}
+	return replace(file, perm, func(w io.Writer) error {
+		ew, err := NewWriter(w, key)
+		if err != nil {
+			return err
+		}
+		if _, err = ew.Write(b); err != nil {
+			return err
+		}
+		return ew.Close()
+	})
+}
+
+// replace writes file through a temporary file renamed over it once complete.
+func replace(file string, perm fs.FileMode, write func(w io.Writer) error) error {
+	tmp := file + tmpSuffix
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(f, chunkSize)
+	if err = write(bw); err == nil {
+		err = bw.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// Summary counts what Encrypt did.
+type Summary struct {
+	KeyId     string `json:"keyId"`
+	Encrypted int    `json:"encrypted"`
+	Rotated   int    `json:"rotated"`
+	Unchanged int    `json:"unchanged"`
+}
+
+// Encrypt encrypts in place with the key of k every file under paths, files or directories,
+// left in clear and re-encrypts the ones sealed with another key. Missing paths are skipped.
+func (k Keyring) Encrypt(paths ...string) (Summary, error) {
+	key := k.Key
+	if key == nil {
+		return Summary{}, errors.New("no encryption key in use")
+	}
+	s := Summary{KeyId: KeyId(key)}
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(file string, e fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if !e.Type().IsRegular() || strings.HasSuffix(file, tmpSuffix) {
+				return nil
+			}
+			return s.file(key, file)
+		})
+		if err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Summary) file(key []byte, file string) error {
+	header := make([]byte, headerSize)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	n, _ := io.ReadFull(f, header)
+	f.Close()
+	id, encrypted := Encrypted(header[:n])
+	if id == s.KeyId {
+		s.Unchanged++
+		return nil
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	in, err := Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	err = replace(file, info.Mode().Perm(), func(w io.Writer) error {
+		ew, err := NewWriter(w, key)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(ew, in); err != nil {
+			return err
+		}
+		return ew.Close()
+	})
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		s.Rotated++
+	} else {
+		s.Encrypted++
+	}
+	return nil
+}
+
+// Decrypt copies src, a file or a directory, to dst in clear.
+func Decrypt(src string, dst string) error {
+	return filepath.WalkDir(src, func(file string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if e.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !e.Type().IsRegular() {
+			return nil
+		}
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		in, err := Open(file)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,224 @@
+package diff
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+var (
+	// IgnoredPaths are json pointers left out of the comparison because they change on every write.
+	IgnoredPaths = []string{"/metadata/resourceVersion", "/metadata/managedFields", "/metadata/generation"}
+	// statusTimestamp matches any field under status named like a timestamp (lastTransitionTime, startTime...)
+	statusTimestamp = regexp.MustCompile(`^/status/(.*/)?[^/]*(Time|Timestamp)$`)
+)
+
+// Change is one field level difference addressed by a json pointer.
+type Change struct {
+	Op     string `json:"op"`
+	Path   string `json:"path"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+type Object struct {
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Changes   []Change `json:"changes,omitempty"`
+}
+
+// Resource is the drift of a single group version kind.
+type Resource struct {
+	Key      string   `json:"key"`
+	Kind     string   `json:"kind,omitempty"`
+	Added    []Object `json:"added"`
+	Removed  []Object `json:"removed"`
+	Modified []Object `json:"modified"`
+}
+
+type Drift struct {
+	Before    string     `json:"before"`
+	After     string     `json:"after"`
+	Resources []Resource `json:"resources"`
+}
+
+// Objects compares two dumps object by object. Only resources with changes are listed.
+func Objects(before *dump.Dump, after *dump.Dump) Drift {
+	d := Drift{Before: before.Path(), After: after.Path(), Resources: []Resource{}}
+	keys := map[string]bool{}
+	for _, k := range before.Keys() {
+		keys[k] = true
+	}
+	for _, k := range after.Keys() {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		r := compareItems(before.Items(k), after.Items(k))
+		if len(r.Added)+len(r.Removed)+len(r.Modified) == 0 {
+			continue
+		}
+		r.Key = k
+		if r.Kind = after.Kind(k); r.Kind == "" {
+			r.Kind = before.Kind(k)
+		}
+		d.Resources = append(d.Resources, r)
+	}
+	return d
+}
+
+func (d Drift) Counts() map[string]int {
+	c := map[string]int{"added": 0, "removed": 0, "modified": 0}
+	for _, r := range d.Resources {
+		c["added"] += len(r.Added)
+		c["removed"] += len(r.Removed)
+		c["modified"] += len(r.Modified)
+	}
+	return c
+}
+
+func compareItems(before []any, after []any) Resource {
+	r := Resource{Added: []Object{}, Removed: []Object{}, Modified: []Object{}}
+	old := index(before)
+	cur := index(after)
+	for _, id := range sortedIds(cur) {
+		o, ok := old[id]
+		if !ok {
+			r.Added = append(r.Added, object(id, nil))
+			continue
+		}
+		if changes := Compare(o, cur[id]); len(changes) > 0 {
+			r.Modified = append(r.Modified, object(id, changes))
+		}
+	}
+	for _, id := range sortedIds(old) {
+		if _, ok := cur[id]; !ok {
+			r.Removed = append(r.Removed, object(id, nil))
+		}
+	}
+	return r
+}
+
+// Compare returns the field level changes from before to after skipping the ignored paths.
+func Compare(before any, after any) []Change {
+	changes := []Change{}
+	compare("", before, after, &changes)
+	return changes
+}
+
+func compare(path string, before any, after any, changes *[]Change) {
+	if ignored(path) {
+		return
+	}
+	switch b := before.(type) {
+	case map[string]any:
+		a, ok := after.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := map[string]bool{}
+		for k := range b {
+			keys[k] = true
+		}
+		for k := range a {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			p := path + "/" + escapePointer(k)
+			bv, inBefore := b[k]
+			av, inAfter := a[k]
+			switch {
+			case !inBefore:
+				if !ignored(p) {
+					*changes = append(*changes, Change{Op: OpAdd, Path: p, After: av})
+				}
+			case !inAfter:
+				if !ignored(p) {
+					*changes = append(*changes, Change{Op: OpRemove, Path: p, Before: bv})
+				}
+			default:
+				compare(p, bv, av, changes)
+			}
+		}
+		return
+	case []any:
+		a, ok := after.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < len(b) || i < len(a); i++ {
+			p := path + "/" + strconv.Itoa(i)
+			switch {
+			case i >= len(b):
+				*changes = append(*changes, Change{Op: OpAdd, Path: p, After: a[i]})
+			case i >= len(a):
+				*changes = append(*changes, Change{Op: OpRemove, Path: p, Before: b[i]})
+			default:
+				compare(p, b[i], a[i], changes)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, Change{Op: OpReplace, Path: path, Before: before, After: after})
+	}
+}
+
+func ignored(path string) bool {
+	for _, p := range IgnoredPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return statusTimestamp.MatchString(path)
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// index keys the items by namespace/name.
+func index(items []any) map[string]any {
+	m := make(map[string]any, len(items))
+	for _, item := range items {
+		o, _ := item.(map[string]any)
+		md, _ := o["metadata"].(map[string]any)
+		ns, _ := md["namespace"].(string)
+		name, _ := md["name"].(string)
+		m[ns+"/"+name] = item
+	}
+	return m
+}
+
+func sortedIds(m map[string]any) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func object(id string, changes []Change) Object {
+	ns, name, _ := strings.Cut(id, "/")
+	return Object{Namespace: ns, Name: name, Changes: changes}
+}
@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+// loadDump writes the configmaps list of items as a kcdump directory and loads it.
+func loadDump(items string) *dump.Dump {
+	dir := GinkgoT().TempDir()
+	doc := "apiVersion: v1\nkind: ConfigMapList\nmetadata:\n  apiName: configmaps\nitems:\n" + items
+	Expect(os.WriteFile(filepath.Join(dir, "configmaps.yaml"), []byte(doc), 0644)).To(Succeed())
+	d, err := dump.Load(dir)
+	Expect(err).NotTo(HaveOccurred())
+	return d
+}
+
+var _ = Describe("Drift", func() {
+	DescribeTable("field changes",
+		func(before any, after any, expected []Change) {
+			Expect(Compare(before, after)).To(Equal(expected))
+		},
+		Entry("none between equal objects",
+			map[string]any{"a": 1.0}, map[string]any{"a": 1.0}, []Change{}),
+		Entry("of added, removed and replaced fields",
+			map[string]any{"a": 1.0, "b": "x"}, map[string]any{"a": 2.0, "c": true},
+			[]Change{{Op: OpReplace, Path: "/a", Before: 1.0, After: 2.0}, {Op: OpRemove, Path: "/b", Before: "x"}, {Op: OpAdd, Path: "/c", After: true}}),
+		Entry("of list items by position",
+			map[string]any{"l": []any{"a", "b"}}, map[string]any{"l": []any{"a", "c", "d"}},
+			[]Change{{Op: OpReplace, Path: "/l/1", Before: "b", After: "c"}, {Op: OpAdd, Path: "/l/2", After: "d"}}),
+		Entry("escaping keys as json pointers",
+			map[string]any{"a/b~c": 1.0}, map[string]any{"a/b~c": 2.0},
+			[]Change{{Op: OpReplace, Path: "/a~1b~0c", Before: 1.0, After: 2.0}}),
+		Entry("of a type change",
+			map[string]any{"a": map[string]any{"b": 1.0}}, map[string]any{"a": "b"},
+			[]Change{{Op: OpReplace, Path: "/a", Before: map[string]any{"b": 1.0}, After: "b"}}),
+		Entry("but not of the fields changing on every write",
+			map[string]any{"metadata": map[string]any{"resourceVersion": "1", "managedFields": []any{1.0}}, "status": map[string]any{"conditions": []any{map[string]any{"lastTransitionTime": "t1"}}}},
+			map[string]any{"metadata": map[string]any{"resourceVersion": "2"}, "status": map[string]any{"conditions": []any{map[string]any{"lastTransitionTime": "t2"}}}},
+			[]Change{}),
+	)
+
+	It("lists the added, removed and modified objects of the resources that changed", func() {
+		before := loadDump("- metadata: {namespace: a, name: kept}\n  data: {k: v}\n- metadata: {namespace: a, name: gone}\n- metadata: {namespace: a, name: same}\n")
+		after := loadDump("- metadata: {namespace: a, name: kept}\n  data: {k: w}\n- metadata: {namespace: a, name: new}\n- metadata: {namespace: a, name: same}\n")
+		d := Objects(before, after)
+		Expect(d.Resources).To(HaveLen(1))
+		r := d.Resources[0]
+		Expect(r.Key).To(Equal("configmaps.v1"))
+		Expect(r.Kind).To(Equal("ConfigMap"))
+		Expect(r.Added).To(Equal([]Object{{Namespace: "a", Name: "new"}}))
+		Expect(r.Removed).To(Equal([]Object{{Namespace: "a", Name: "gone"}}))
+		Expect(r.Modified).To(Equal([]Object{{Namespace: "a", Name: "kept", Changes: []Change{{Op: OpReplace, Path: "/data/k", Before: "v", After: "w"}}}}))
+		Expect(d.Counts()).To(Equal(map[string]int{"added": 1, "removed": 1, "modified": 1}))
+		Expect(Objects(before, before).Resources).To(BeEmpty())
+	})
+})
@@ -0,0 +1,56 @@
+package diff
+
+import (
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+// Findings classifies the findings of a run against the ones of a previous run.
+type Findings struct {
+	Previous   string          `json:"previous"`
+	New        []check.Finding `json:"new"`
+	Resolved   []check.Finding `json:"resolved"`
+	Persisting []check.Finding `json:"persisting"`
+}
+
+// CompareFindings matches before and after by fingerprint. New and persisting findings are
+// taken from after, so they carry the current message and severity, resolved ones from before.
+func CompareFindings(previous string, before []check.Finding, after []check.Finding) Findings {
+	d := Findings{Previous: previous, New: []check.Finding{}, Resolved: []check.Finding{}, Persisting: []check.Finding{}}
+	seen := make(map[string]bool, len(before))
+	for _, f := range before {
+		seen[fingerprint(f)] = true
+	}
+	current := make(map[string]bool, len(after))
+	for _, f := range after {
+		fp := fingerprint(f)
+		current[fp] = true
+		if seen[fp] {
+			d.Persisting = append(d.Persisting, f)
+		} else {
+			d.New = append(d.New, f)
+		}
+	}
+	for _, f := range before {
+		if !current[fingerprint(f)] {
+			d.Resolved = append(d.Resolved, f)
+		}
+	}
+	return d
+}
+
+func (d Findings) Counts() map[string]any {
+	return map[string]any{
+		"previous":   d.Previous,
+		"new":        len(d.New),
+		"resolved":   len(d.Resolved),
+		"persisting": len(d.Persisting),
+	}
+}
+
+// fingerprint falls back to computing it for findings read from runs older than fingerprints.
+func fingerprint(f check.Finding) string {
+	if f.Fingerprint != "" {
+		return f.Fingerprint
+	}
+	return check.Fingerprint(f)
+}
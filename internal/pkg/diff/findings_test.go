@@ -0,0 +1,34 @@
+package diff
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+var _ = Describe("Findings", func() {
+	DescribeTable("findings between runs",
+		func(before []check.Finding, after []check.Finding, new int, resolved int, persisting int) {
+			d := CompareFindings("previous", before, after)
+			Expect(d.New).To(HaveLen(new))
+			Expect(d.Resolved).To(HaveLen(resolved))
+			Expect(d.Persisting).To(HaveLen(persisting))
+		},
+		Entry("are new when there was none", nil, []check.Finding{{CheckId: "A", Name: "x"}}, 1, 0, 0),
+		Entry("are resolved when they are gone", []check.Finding{{CheckId: "A", Name: "x"}}, nil, 0, 1, 0),
+		Entry("persist by check and object whatever the message",
+			[]check.Finding{{CheckId: "A", Name: "x", Message: "old"}}, []check.Finding{{CheckId: "A", Name: "x", Message: "new"}}, 0, 0, 1),
+		Entry("persist across api versions",
+			[]check.Finding{{CheckId: "A", ApiVersion: "apps/v1beta1", Kind: "Deployment", Name: "x"}},
+			[]check.Finding{{CheckId: "A", ApiVersion: "apps/v1", Kind: "Deployment", Name: "x"}}, 0, 0, 1),
+		Entry("match the fingerprints of older runs",
+			[]check.Finding{{CheckId: "A", Name: "x"}}, []check.Finding{{CheckId: "A", Name: "x", Fingerprint: check.Fingerprint(check.Finding{CheckId: "A", Name: "x"})}}, 0, 0, 1),
+	)
+
+	It("keeps the current message of persisting findings", func() {
+		d := CompareFindings("p", []check.Finding{{CheckId: "A", Name: "x", Message: "old"}}, []check.Finding{{CheckId: "A", Name: "x", Message: "new"}})
+		Expect(d.Persisting[0].Message).To(Equal("new"))
+		Expect(d.Counts()).To(Equal(map[string]any{"previous": "p", "new": 0, "resolved": 0, "persisting": 1}))
+	})
+})
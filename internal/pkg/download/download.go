@@ -0,0 +1,289 @@
+// Package download serves the run bundles from the manager. Requests carry either a bearer token,
+// authenticated with a TokenReview and authorized with a SubjectAccessReview for get on the
+// configs/bundle subresource of the Config, or a download token handed out by the same server
+// to someone who passed that check.
+package download
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/util/cert"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"adoption.latam/hcr/internal/pkg/bundle"
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/util/log"
+)
+
+const (
+	Group       = "hcr.adoption.latam"
+	Resource    = "configs"
+	Subresource = "bundle"
+
+	DefaultTTL = 15 * time.Minute
+)
+
+var (
+	logger = log.Logger().Named("hcr.download")
+	nameRe = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	runRe  = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z$`)
+)
+
+// Server is a manager runnable serving
+//
+//	GET  /runs/{namespace}/{config}/{run}/bundle.tar.gz[?token=...]
+//	POST /runs/{namespace}/{config}/{run}/token[?ttl=10m]
+//
+// over TLS. Without CertDir a self signed certificate is made at start.
+type Server struct {
+	Addr     string
+	CertDir  string
+	CertName string
+	KeyName  string
+	TLSOpts  []func(*tls.Config)
+	// Root holds the bundles laid out by bundle.Path.
+	Root string
+	// MaxTTL caps the lifetime of download tokens.
+	MaxTTL time.Duration
+	// LoadKeys registers the keys of the Config namespace/config with crypt before a download of
+	// its bundles, they are otherwise only there once the Config reconciled.
+	LoadKeys func(ctx context.Context, namespace string, config string) error
+
+	client client.Client
+	tokens *tokens
+}
+
+func NewServer(c client.Client, addr string, root string) *Server {
+	return &Server{Addr: addr, Root: root, CertName: "tls.crt", KeyName: "tls.key", MaxTTL: time.Hour, client: c}
+}
+
+// NeedLeaderElection is true. The bundles are written by the leader to its own disk and the
+// download tokens are signed with a key of the process, so only the leader serves them.
+func (s *Server) NeedLeaderElection() bool {
+	return true
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	var err error
+	if s.tokens, err = newTokens(); err != nil {
+		return err
+	}
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /runs/{namespace}/{config}/{run}/bundle"+bundle.Ext, s.download)
+	mux.HandleFunc("POST /runs/{namespace}/{config}/{run}/token", s.token)
+	srv := &http.Server{Addr: s.Addr, Handler: mux, TLSConfig: tlsConfig, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdown); err != nil {
+			logger.Error("download server shutdown", zap.Error(err))
+		}
+	}()
+	logger.Info("serving run bundles", zap.String("addr", s.Addr))
+	if err = srv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	var (
+		crt tls.Certificate
+		err error
+	)
+	if s.CertDir != "" {
+		crt, err = tls.LoadX509KeyPair(filepath.Join(s.CertDir, s.CertName), filepath.Join(s.CertDir, s.KeyName))
+	} else {
+		logger.Warn("no certificate for the download endpoint, using a self signed one")
+		var c, k []byte
+		if c, k, err = cert.GenerateSelfSignedCertKey("hcr-download", nil, nil); err == nil {
+			crt, err = tls.X509KeyPair(c, k)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{crt}, MinVersion: tls.VersionTLS12}
+	for _, o := range s.TLSOpts {
+		o(cfg)
+	}
+	return cfg, nil
+}
+
+// target is the Config and run of a request.
+type target struct {
+	Namespace string `json:"namespace"`
+	Config    string `json:"config"`
+	Run       string `json:"run"`
+}
+
+func parseTarget(r *http.Request) (target, error) {
+	t := target{Namespace: r.PathValue("namespace"), Config: r.PathValue("config"), Run: r.PathValue("run")}
+	if !nameRe.MatchString(t.Namespace) || !nameRe.MatchString(t.Config) || !runRe.MatchString(t.Run) {
+		return t, errors.New("bad namespace, config or run")
+	}
+	return t, nil
+}
+
+func (s *Server) download(w http.ResponseWriter, r *http.Request) {
+	t, err := parseTarget(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user := "token"
+	if tok := r.URL.Query().Get("token"); tok != "" {
+		if err = s.tokens.check(tok, t); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	} else if user, err = s.authorize(r, t); err != nil {
+		s.deny(w, err)
+		return
+	}
+	if s.LoadKeys != nil {
+		if err = s.LoadKeys(r.Context(), t.Namespace, t.Config); err != nil {
+			logger.Warn("loading encryption keys", zap.Error(err))
+		}
+	}
+	f, err := crypt.Open(bundle.Path(s.Root, t.Namespace, t.Config, t.Run))
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "no bundle for run "+t.Run, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("opening bundle", zap.Error(err))
+		http.Error(w, "unreadable bundle", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s%s"`, t.Config, t.Run, bundle.Ext))
+	n, err := io.Copy(w, f)
+	if err != nil {
+		logger.Warn("bundle download interrupted", zap.Any("target", t), zap.Error(err))
+		return
+	}
+	logger.Info("bundle downloaded", zap.Any("target", t), zap.String("user", user), zap.Int64("bytes", n))
+}
+
+// token hands out a download token for the bundle to an authorized user.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	t, err := parseTarget(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := s.authorize(r, t)
+	if err != nil {
+		s.deny(w, err)
+		return
+	}
+	if !exists(bundle.Path(s.Root, t.Namespace, t.Config, t.Run)) {
+		http.Error(w, "no bundle for run "+t.Run, http.StatusNotFound)
+		return
+	}
+	ttl := DefaultTTL
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		if ttl, err = time.ParseDuration(v); err != nil || ttl <= 0 {
+			http.Error(w, "bad ttl", http.StatusBadRequest)
+			return
+		}
+	}
+	ttl = min(ttl, s.MaxTTL)
+	expires := time.Now().Add(ttl).UTC()
+	tok := s.tokens.issue(t, expires)
+	logger.Info("download token issued", zap.Any("target", t), zap.String("user", user), zap.Time("expires", expires))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"token":   tok,
+		"expires": expires.Format(time.RFC3339),
+		"path":    fmt.Sprintf("/runs/%s/%s/%s/bundle%s?token=%s", t.Namespace, t.Config, t.Run, bundle.Ext, tok),
+	})
+}
+
+// denied is an authentication or authorization failure worth its own status code.
+type denied struct {
+	status int
+	reason string
+}
+
+func (d denied) Error() string {
+	return d.reason
+}
+
+func (s *Server) deny(w http.ResponseWriter, err error) {
+	d := denied{}
+	if errors.As(err, &d) {
+		http.Error(w, d.reason, d.status)
+		return
+	}
+	logger.Error("reviewing access", zap.Error(err))
+	http.Error(w, "unable to review access", http.StatusInternalServerError)
+}
+
+// authorize reviews the bearer token of r and whether its user may get the bundle subresource of the Config.
+func (s *Server) authorize(r *http.Request, t target) (string, error) {
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || bearer == "" {
+		return "", denied{http.StatusUnauthorized, "a bearer token or a download token is required"}
+	}
+	tr := &authnv1.TokenReview{Spec: authnv1.TokenReviewSpec{Token: bearer}}
+	if err := s.client.Create(r.Context(), tr); err != nil {
+		return "", err
+	}
+	if !tr.Status.Authenticated {
+		return "", denied{http.StatusUnauthorized, "invalid bearer token"}
+	}
+	u := tr.Status.User
+	extra := make(map[string]authzv1.ExtraValue, len(u.Extra))
+	for k, v := range u.Extra {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+	sar := &authzv1.SubjectAccessReview{Spec: authzv1.SubjectAccessReviewSpec{
+		User:   u.Username,
+		UID:    u.UID,
+		Groups: u.Groups,
+		Extra:  extra,
+		ResourceAttributes: &authzv1.ResourceAttributes{
+			Namespace:   t.Namespace,
+			Verb:        "get",
+			Group:       Group,
+			Resource:    Resource,
+			Subresource: Subresource,
+			Name:        t.Config,
+		},
+	}}
+	if err := s.client.Create(r.Context(), sar); err != nil {
+		return "", err
+	}
+	if !sar.Status.Allowed {
+		return "", denied{http.StatusForbidden, fmt.Sprintf("%s may not get %s/%s of %s/%s", u.Username, Resource, Subresource, t.Namespace, t.Config)}
+	}
+	return u.Username, nil
+}
+
+// exists tells if file is there, used to refuse tokens for bundles that are not.
+func exists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
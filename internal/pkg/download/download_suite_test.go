@@ -0,0 +1,14 @@
+package download
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDownload(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Download Suite")
+}
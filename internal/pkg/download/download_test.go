@@ -0,0 +1,88 @@
+package download
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/bundle"
+)
+
+var run = target{Namespace: "ns", Config: "config", Run: "20250101T000000Z"}
+
+var _ = Describe("Download", func() {
+	DescribeTable("tokens",
+		func(tok func(k *tokens) string, t target, expected string) {
+			k, err := newTokens()
+			Expect(err).NotTo(HaveOccurred())
+			err = k.check(tok(k), t)
+			if expected == "" {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(expected))
+			}
+		},
+		Entry("a valid one", func(k *tokens) string { return k.issue(run, time.Now().Add(time.Minute)) }, run, ""),
+		Entry("an expired one", func(k *tokens) string { return k.issue(run, time.Now().Add(-time.Minute)) }, run,
+			"expired download token"),
+		Entry("one for another run", func(k *tokens) string { return k.issue(run, time.Now().Add(time.Minute)) },
+			target{Namespace: "ns", Config: "config", Run: "20250102T000000Z"}, "the download token is for another bundle"),
+		Entry("one for another Config", func(k *tokens) string { return k.issue(run, time.Now().Add(time.Minute)) },
+			target{Namespace: "other", Config: "config", Run: "20250101T000000Z"}, "the download token is for another bundle"),
+		Entry("one signed by another process", func(*tokens) string {
+			other, err := newTokens()
+			Expect(err).NotTo(HaveOccurred())
+			return other.issue(run, time.Now().Add(time.Minute))
+		}, run, "invalid download token"),
+		Entry("one with its claims changed", func(k *tokens) string {
+			tok := k.issue(run, time.Now().Add(time.Minute))
+			payload, sig, _ := strings.Cut(tok, ".")
+			return payload[:len(payload)-2] + "fQ." + sig
+		}, run, "invalid download token"),
+		Entry("one without signature", func(k *tokens) string {
+			tok, _, _ := strings.Cut(k.issue(run, time.Now().Add(time.Minute)), ".")
+			return tok
+		}, run, "invalid download token"),
+	)
+
+	DescribeTable("downloads",
+		func(path func(s *Server) string, status int, body string) {
+			root := GinkgoT().TempDir()
+			file := bundle.Path(root, run.Namespace, run.Config, run.Run)
+			Expect(os.MkdirAll(filepath.Dir(file), 0755)).To(Succeed())
+			Expect(os.WriteFile(file, []byte("bundle"), 0644)).To(Succeed())
+			s := NewServer(nil, "", root)
+			var err error
+			s.tokens, err = newTokens()
+			Expect(err).NotTo(HaveOccurred())
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /runs/{namespace}/{config}/{run}/bundle"+bundle.Ext, s.download)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path(s), nil))
+			Expect(w.Code).To(Equal(status))
+			Expect(w.Body.String()).To(ContainSubstring(body))
+		},
+		Entry("with a download token", func(s *Server) string {
+			return "/runs/ns/config/20250101T000000Z/bundle.tar.gz?token=" + s.tokens.issue(run, time.Now().Add(time.Minute))
+		}, http.StatusOK, "bundle"),
+		Entry("with the token of another run", func(s *Server) string {
+			return "/runs/ns/config/20250102T000000Z/bundle.tar.gz?token=" + s.tokens.issue(run, time.Now().Add(time.Minute))
+		}, http.StatusForbidden, "another bundle"),
+		Entry("of a missing bundle", func(s *Server) string {
+			t := target{Namespace: "ns", Config: "config", Run: "20250102T000000Z"}
+			return "/runs/ns/config/20250102T000000Z/bundle.tar.gz?token=" + s.tokens.issue(t, time.Now().Add(time.Minute))
+		}, http.StatusNotFound, "no bundle"),
+		Entry("without any token", func(*Server) string {
+			return "/runs/ns/config/20250101T000000Z/bundle.tar.gz"
+		}, http.StatusUnauthorized, "token is required"),
+		Entry("of a bad run", func(*Server) string {
+			return "/runs/ns/config/latest/bundle.tar.gz"
+		}, http.StatusBadRequest, "bad namespace, config or run"),
+	)
+})
@@ -0,0 +1,64 @@
+package download
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// tokens signs download tokens with a key made at start so they die with the process, which is
+// the leader as it is the only one serving the bundles.
+type tokens struct {
+	key []byte
+}
+
+type claims struct {
+	target
+	Expires int64 `json:"exp"`
+}
+
+func newTokens() (*tokens, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	return &tokens{key: key}, err
+}
+
+func (k *tokens) sign(payload string) string {
+	mac := hmac.New(sha256.New, k.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issue returns a token good for the bundle of t until expires.
+func (k *tokens) issue(t target, expires time.Time) string {
+	j, _ := json.Marshal(claims{target: t, Expires: expires.Unix()})
+	payload := base64.RawURLEncoding.EncodeToString(j)
+	return payload + "." + k.sign(payload)
+}
+
+func (k *tokens) check(tok string, t target) error {
+	payload, sig, ok := strings.Cut(tok, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(k.sign(payload))) {
+		return errors.New("invalid download token")
+	}
+	j, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return errors.New("invalid download token")
+	}
+	c := claims{}
+	if err = json.Unmarshal(j, &c); err != nil {
+		return errors.New("invalid download token")
+	}
+	if time.Now().Unix() > c.Expires {
+		return errors.New("expired download token")
+	}
+	if c.target != t {
+		return errors.New("the download token is for another bundle")
+	}
+	return nil
+}
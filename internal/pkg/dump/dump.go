@@ -0,0 +1,190 @@
+package dump
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/util/log"
+)
+
+var logger = log.Logger().Named("hcr.dump")
+
+// Dump is an in memory index of the resource lists written by kcdump.
+// Lists are keyed the same way kcdump names them in its chunk maps:
+// api name + "." + group version. ex: configmaps.v1, prometheusrules.monitoring.coreos.com/v1
+type Dump struct {
+	path      string
+	resources map[string][]any
+	kinds     map[string]string
+}
+
+// Load walks path reading every yaml or json file (gziped or not) produced by kcdump.
+// Both the split group version layout and the single big file layout are understood.
+func Load(path string) (*Dump, error) {
+	d := &Dump{path: path, resources: make(map[string][]any), kinds: make(map[string]string)}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		logger.Warn("dump path not found", zap.String("path", path))
+		return d, nil
+	}
+	err := filepath.WalkDir(path, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() || !isDumpFile(p) {
+			return nil
+		}
+		if err = d.loadFile(p); err != nil {
+			logger.Warn("skipping unreadable dump file", zap.String("file", p), zap.Error(err))
+		}
+		return nil
+	})
+	return d, err
+}
+
+func isDumpFile(p string) bool {
+	p = strings.TrimSuffix(p, ".gz")
+	return strings.HasSuffix(p, ".json") || strings.HasSuffix(p, ".yaml") || strings.HasSuffix(p, ".yml")
+}
+
+func (d *Dump) loadFile(p string) error {
+	f, err := crypt.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(p, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+		p = strings.TrimSuffix(p, ".gz")
+	}
+	if strings.HasSuffix(p, ".json") {
+		dec := json.NewDecoder(r)
+		for {
+			var doc map[string]any
+			if err = dec.Decode(&doc); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			d.add(doc)
+		}
+	}
+	dec := yaml.NewDecoder(r)
+	for {
+		var doc map[string]any
+		if err = dec.Decode(&doc); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		d.add(doc)
+	}
+}
+
+func (d *Dump) add(doc map[string]any) {
+	key := Key(doc)
+	if key == "" {
+		return
+	}
+	if kind, _ := doc["kind"].(string); kind != "" {
+		d.kinds[key] = strings.TrimSuffix(kind, "List")
+	}
+	items, _ := Normalize(doc["items"]).([]any)
+	d.resources[key] = append(d.resources[key], items...)
+}
+
+// Normalize turns decoded yaml into plain json values (timestamps become strings and so on)
+// so they can be handed over to jq as is.
+func Normalize(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var n any
+	if err = json.Unmarshal(b, &n); err != nil {
+		return nil
+	}
+	return n
+}
+
+// Key returns the kcdump key for a resource list or "" if doc is not one.
+func Key(doc map[string]any) string {
+	md, _ := doc["metadata"].(map[string]any)
+	name, _ := md["apiName"].(string)
+	gv, _ := doc["apiVersion"].(string)
+	if name == "" || gv == "" {
+		return ""
+	}
+	return name + "." + gv
+}
+
+func (d *Dump) Path() string {
+	return d.path
+}
+
+func (d *Dump) Has(key string) bool {
+	_, ok := d.resources[key]
+	return ok
+}
+
+func (d *Dump) Items(key string) []any {
+	if items, ok := d.resources[key]; ok {
+		return items
+	}
+	return []any{}
+}
+
+// Kind returns the kind of the items listed under key or "" if the list did not tell.
+func (d *Dump) Kind(key string) string {
+	return d.kinds[key]
+}
+
+// Find returns the object of kind in the group of apiVersion or nil when it is not in the dump.
+func (d *Dump) Find(apiVersion string, kind string, namespace string, name string) any {
+	group := ""
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		group = apiVersion[:i]
+	}
+	for key, k := range d.kinds {
+		if k != kind {
+			continue
+		}
+		gv := key[strings.Index(key, ".")+1:]
+		if g := gv[:max(strings.LastIndex(gv, "/"), 0)]; g != group {
+			continue
+		}
+		for _, item := range d.resources[key] {
+			o, _ := item.(map[string]any)
+			md, _ := o["metadata"].(map[string]any)
+			if md["name"] == name && (md["namespace"] == namespace || (namespace == "" && md["namespace"] == nil)) {
+				return item
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dump) Keys() []string {
+	keys := make([]string, 0, len(d.resources))
+	for k := range d.resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
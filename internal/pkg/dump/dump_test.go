@@ -0,0 +1,111 @@
+package dump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const configMaps = `apiVersion: v1
+kind: ConfigMapList
+metadata:
+  apiName: configmaps
+items:
+- metadata:
+    namespace: app
+    name: settings
+    creationTimestamp: 2026-10-19T00:00:00Z
+`
+
+const routes = `{"apiVersion": "route.openshift.io/v1", "kind": "RouteList", "metadata": {"apiName": "routes"},
+ "items": [{"metadata": {"namespace": "app", "name": "web"}}]}
+`
+
+// write writes the files, path relative to dir to content, gziping the .gz ones.
+func write(dir string, files map[string]string) {
+	for p, content := range files {
+		b := []byte(content)
+		if filepath.Ext(p) == ".gz" {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			_, err := gz.Write(b)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gz.Close()).To(Succeed())
+			b = buf.Bytes()
+		}
+		Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, p), b, 0644)).To(Succeed())
+	}
+}
+
+var _ = Describe("Dump", func() {
+	It("loads yaml and gziped json lists of the split group version layout", func() {
+		dir := GinkgoT().TempDir()
+		write(dir, map[string]string{"v1/configmaps.yaml": configMaps, "route.openshift.io_v1/routes.json.gz": routes})
+		d, err := Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Path()).To(Equal(dir))
+		Expect(d.Keys()).To(Equal([]string{"configmaps.v1", "routes.route.openshift.io/v1"}))
+		Expect(d.Kind("routes.route.openshift.io/v1")).To(Equal("Route"))
+		Expect(d.Items("routes.route.openshift.io/v1")).To(HaveLen(1))
+	})
+
+	It("loads the lists of a single big file and appends the lists of the same key", func() {
+		dir := GinkgoT().TempDir()
+		write(dir, map[string]string{"cluster.yaml": configMaps + "---\n" + configMaps + "---\napiVersion: v1\nkind: Config\n"})
+		d, err := Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Keys()).To(Equal([]string{"configmaps.v1"}), "documents without an api name are no list")
+		Expect(d.Items("configmaps.v1")).To(HaveLen(2))
+	})
+
+	It("normalizes the items to json values", func() {
+		dir := GinkgoT().TempDir()
+		write(dir, map[string]string{"configmaps.yaml": configMaps})
+		d, err := Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		item := d.Items("configmaps.v1")[0].(map[string]any)
+		Expect(item["metadata"]).To(HaveKeyWithValue("creationTimestamp", "2026-10-19T00:00:00Z"))
+	})
+
+	It("skips unreadable files and what is no dump file", func() {
+		dir := GinkgoT().TempDir()
+		write(dir, map[string]string{"configmaps.yaml": configMaps, "broken.yaml": "items: [", "notes.txt": routes})
+		d, err := Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Keys()).To(Equal([]string{"configmaps.v1"}))
+	})
+
+	It("is empty when the path does not exist", func() {
+		d, err := Load(filepath.Join(GinkgoT().TempDir(), "missing"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Keys()).To(BeEmpty())
+		Expect(d.Has("configmaps.v1")).To(BeFalse())
+		Expect(d.Items("configmaps.v1")).To(BeEmpty())
+	})
+
+	DescribeTable("finding objects",
+		func(apiVersion string, kind string, namespace string, name string, found bool) {
+			dir := GinkgoT().TempDir()
+			write(dir, map[string]string{"configmaps.yaml": configMaps, "routes.json": routes,
+				"nodes.yaml": "apiVersion: v1\nkind: NodeList\nmetadata:\n  apiName: nodes\nitems:\n- metadata:\n    name: worker-0\n"})
+			d, err := Load(dir)
+			Expect(err).NotTo(HaveOccurred())
+			if found {
+				Expect(d.Find(apiVersion, kind, namespace, name)).To(HaveKeyWithValue("metadata", HaveKeyWithValue("name", name)))
+			} else {
+				Expect(d.Find(apiVersion, kind, namespace, name)).To(BeNil())
+			}
+		},
+		Entry("in the core group", "v1", "ConfigMap", "app", "settings", true),
+		Entry("in a group whatever the version", "route.openshift.io/v2", "Route", "app", "web", true),
+		Entry("cluster scoped", "v1", "Node", "", "worker-0", true),
+		Entry("not in another group", "apps/v1", "ConfigMap", "app", "settings", false),
+		Entry("not in another namespace", "v1", "ConfigMap", "other", "settings", false),
+		Entry("not of another kind", "v1", "Secret", "app", "settings", false),
+	)
+})
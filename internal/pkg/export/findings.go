@@ -0,0 +1,167 @@
+package export
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mauricioscastro/kcdump/pkg/yjq"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/util"
+	"adoption.latam/hcr/internal/pkg/util/log"
+	"adoption.latam/hcr/internal/pkg/waiver"
+)
+
+const (
+	// FindingsSchemaVersion is bumped on the minor for additive changes. A new major gets a new schema file.
+	FindingsSchemaVersion = "1.5.0"
+	FindingsSchemaFile    = "findings-v1.yaml"
+	GeneratorName         = "hcreport"
+	GeneratorUri          = "https://github.com/mauricioscastro/hcreport"
+)
+
+var (
+	logger = log.Logger().Named("hcr.export")
+	//go:embed schema/*.yaml
+	schemaFS embed.FS
+)
+
+type Generator struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type ConfigRef struct {
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type Run struct {
+	Id     string     `json:"id"`
+	Date   string     `json:"date"`
+	Config *ConfigRef `json:"config,omitempty"`
+}
+
+type Summary struct {
+	Checks     int            `json:"checks"`
+	Findings   int            `json:"findings"`
+	ByStatus   map[string]int `json:"byStatus"`
+	BySeverity map[string]int `json:"bySeverity"`
+}
+
+type CheckSummary struct {
+	Id          string              `json:"id"`
+	Title       string              `json:"title"`
+	Category    string              `json:"category"`
+	Severity    string              `json:"severity"`
+	Status      string              `json:"status"`
+	Evaluated   int                 `json:"evaluated"`
+	Findings    int                 `json:"findings"`
+	Description string              `json:"description,omitempty"`
+	Remediation string              `json:"remediation,omitempty"`
+	Error       string              `json:"error,omitempty"`
+	Controls    map[string][]string `json:"controls,omitempty"`
+}
+
+// Findings is the findings.json document described by the published schema.
+type Findings struct {
+	SchemaVersion string          `json:"schemaVersion"`
+	Generator     Generator       `json:"generator"`
+	Run           Run             `json:"run"`
+	Summary       Summary         `json:"summary"`
+	Checks        []CheckSummary  `json:"checks"`
+	Findings      []check.Finding `json:"findings"`
+	Waived        []waiver.Waived `json:"waived,omitempty"`
+	Baseline      *Baseline       `json:"baseline,omitempty"`
+}
+
+// Baseline holds the findings already known in the baseline run. They are left out of Findings.
+type Baseline struct {
+	Run      string          `json:"run"`
+	Findings []check.Finding `json:"findings"`
+}
+
+func NewFindings(run Run, results []check.Result, findings []check.Finding) Findings {
+	doc := Findings{
+		SchemaVersion: FindingsSchemaVersion,
+		Generator:     Generator{Name: GeneratorName},
+		Run:           run,
+		Summary: Summary{
+			Checks:     len(results),
+			Findings:   len(findings),
+			ByStatus:   map[string]int{},
+			BySeverity: check.CountBySeverity(findings),
+		},
+		Checks:   make([]CheckSummary, 0, len(results)),
+		Findings: findings,
+	}
+	for _, r := range results {
+		doc.Summary.ByStatus[r.Status]++
+		doc.Checks = append(doc.Checks, CheckSummary{
+			Id:          r.Check.Id,
+			Title:       r.Check.Title,
+			Category:    r.Check.Category,
+			Severity:    r.Check.Severity,
+			Status:      r.Status,
+			Evaluated:   r.Evaluated,
+			Findings:    len(r.Findings),
+			Description: r.Check.Description,
+			Remediation: r.Check.Remediation,
+			Error:       r.Error,
+			Controls:    r.Check.Controls,
+		})
+	}
+	return doc
+}
+
+// ReadFindings reads path/findings.json as written by a previous run.
+func ReadFindings(path string) (Findings, error) {
+	doc := Findings{}
+	b, err := crypt.ReadFile(filepath.Join(path, "findings.json"))
+	if err != nil {
+		return doc, err
+	}
+	return doc, json.Unmarshal(b, &doc)
+}
+
+// All is every finding of the run, the ones known from a baseline included, but not the waived ones.
+func (doc Findings) All() []check.Finding {
+	if doc.Baseline == nil {
+		return doc.Findings
+	}
+	return append(slices.Clone(doc.Findings), doc.Baseline.Findings...)
+}
+
+func FindingsSchema() (string, error) {
+	b, err := schemaFS.ReadFile("schema/" + FindingsSchemaFile)
+	return string(b), err
+}
+
+// WriteFindings validates doc against the schema before writing it as path/findings.json
+// along with the schema itself so consumers get the exact version used.
+func WriteFindings(path string, doc Findings) error {
+	schema, err := FindingsSchema()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = util.ValidateJson(string(b), schema); err != nil {
+		return fmt.Errorf("findings.json does not follow its schema: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(path, "findings.json"), b, 0644); err != nil {
+		return err
+	}
+	schemaJson, err := yjq.Y2JP(schema)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(path, "findings.schema.json"), []byte(schemaJson), 0644)
+}
@@ -0,0 +1,43 @@
+package export
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+var _ = Describe("Findings", func() {
+	results := []check.Result{
+		{Check: check.Check{Id: "A-1", Title: "a", Category: "c", Severity: check.SeverityHigh}, Status: check.StatusFail, Evaluated: 2,
+			Findings: []check.Finding{{CheckId: "A-1", Title: "a", Category: "c", Severity: check.SeverityHigh, Kind: "Pod", Name: "p", Message: "m"}}},
+		{Check: check.Check{Id: "A-2", Title: "b", Category: "c", Severity: check.SeverityLow}, Status: check.StatusPass, Evaluated: 1},
+	}
+
+	It("writes a document that follows its schema and reads it back", func() {
+		dir := GinkgoT().TempDir()
+		doc := NewFindings(Run{Id: "20261019T000000Z", Date: "2026-10-19T00:00:00Z"}, results, check.Findings(results))
+		Expect(doc.Summary.ByStatus).To(Equal(map[string]int{check.StatusFail: 1, check.StatusPass: 1}))
+		Expect(doc.Summary.BySeverity[check.SeverityHigh]).To(Equal(1))
+		Expect(WriteFindings(dir, doc)).To(Succeed())
+		Expect(filepath.Join(dir, "findings.schema.json")).To(BeAnExistingFile())
+		read, err := ReadFindings(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read.Findings).To(Equal(doc.Findings))
+		Expect(read.Checks).To(Equal(doc.Checks))
+	})
+
+	It("refuses a document that does not follow its schema", func() {
+		doc := NewFindings(Run{}, results, check.Findings(results))
+		doc.SchemaVersion = ""
+		Expect(WriteFindings(GinkgoT().TempDir(), doc)).To(MatchError(ContainSubstring("does not follow its schema")))
+	})
+
+	It("counts the baseline findings in All", func() {
+		doc := Findings{Findings: []check.Finding{{Name: "a"}}, Baseline: &Baseline{Findings: []check.Finding{{Name: "b"}}}}
+		Expect(doc.All()).To(HaveLen(2))
+		Expect(doc.Findings).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,139 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Type    string `xml:"type,attr,omitempty"`
+	Message string `xml:"message,attr,omitempty"`
+	Body    string `xml:",cdata"`
+}
+
+type junitOutput struct {
+	Body string `xml:",cdata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+// Failing returns the findings at or above the failOn severity.
+func Failing(findings []check.Finding, failOn string) []check.Finding {
+	failing := []check.Finding{}
+	for _, f := range findings {
+		if check.SeverityRank(f.Severity) <= check.SeverityRank(failOn) {
+			failing = append(failing, f)
+		}
+	}
+	return failing
+}
+
+// WriteJunit writes path/junit.xml with a testsuite per category and a testcase per check.
+// A check fails when it raised findings at or above failOn. Findings below it go to system-out.
+func WriteJunit(path string, run Run, results []check.Result, failOn string) error {
+	suites := junitTestSuites{Name: GeneratorName}
+	index := map[string]int{}
+	for _, r := range results {
+		i, ok := index[r.Check.Category]
+		if !ok {
+			i = len(suites.TestSuites)
+			index[r.Check.Category] = i
+			suites.TestSuites = append(suites.TestSuites, junitTestSuite{
+				Name:       r.Check.Category,
+				Timestamp:  run.Date,
+				Properties: []junitProperty{{"runId", run.Id}, {"failOn", failOn}},
+			})
+		}
+		ts := &suites.TestSuites[i]
+		tc := junitTestCase{
+			Name:      r.Check.Id + " " + r.Check.Title,
+			ClassName: GeneratorName + "." + r.Check.Category,
+		}
+		switch r.Status {
+		case check.StatusError:
+			tc.Error = &junitMessage{Type: "QueryError", Message: r.Error}
+			ts.Errors++
+		case check.StatusNotEvaluated:
+			tc.Skipped = &junitMessage{Message: "resources required by the check were not found in the dump"}
+			ts.Skipped++
+		case check.StatusFail:
+			failing := Failing(r.Findings, failOn)
+			objects := objectLines(r.Findings)
+			if len(failing) > 0 {
+				tc.Failure = &junitMessage{
+					Type:    r.Check.Severity,
+					Message: fmt.Sprintf("%d object(s) affected", len(r.Findings)),
+					Body:    objects,
+				}
+				ts.Failures++
+			} else {
+				tc.SystemOut = &junitOutput{objects}
+			}
+		}
+		ts.TestCases = append(ts.TestCases, tc)
+		ts.Tests++
+	}
+	for _, ts := range suites.TestSuites {
+		suites.Tests += ts.Tests
+		suites.Failures += ts.Failures
+		suites.Errors += ts.Errors
+		suites.Skipped += ts.Skipped
+	}
+	b, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(path, "junit.xml"), append([]byte(xml.Header), b...), 0644)
+}
+
+func objectLines(findings []check.Finding) string {
+	var sb strings.Builder
+	for _, f := range findings {
+		obj := f.Kind + "/" + f.Name
+		if f.Namespace != "" {
+			obj = f.Namespace + "/" + obj
+		} else if f.Name == "" {
+			obj = "cluster"
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", obj, f.Message))
+	}
+	return sb.String()
+}
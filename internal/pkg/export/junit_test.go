@@ -0,0 +1,41 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+var _ = Describe("Junit", func() {
+	results := []check.Result{
+		{Check: check.Check{Id: "A-1", Title: "a", Category: "c", Severity: check.SeverityHigh}, Status: check.StatusFail, Evaluated: 2,
+			Findings: []check.Finding{{CheckId: "A-1", Title: "a", Category: "c", Severity: check.SeverityHigh, Kind: "Pod", Name: "p", Message: "m"}}},
+		{Check: check.Check{Id: "A-2", Title: "b", Category: "c", Severity: check.SeverityLow}, Status: check.StatusPass, Evaluated: 1},
+	}
+
+	DescribeTable("failing findings",
+		func(failOn string, expected int) {
+			findings := []check.Finding{{Severity: check.SeverityCritical}, {Severity: check.SeverityHigh}, {Severity: check.SeverityLow}}
+			Expect(Failing(findings, failOn)).To(HaveLen(expected))
+		},
+		Entry(nil, check.SeverityCritical, 1),
+		Entry(nil, check.SeverityHigh, 2),
+		Entry(nil, check.SeverityInfo, 3),
+	)
+
+	It("fails the junit test cases of the checks at or above failOn", func() {
+		dir := GinkgoT().TempDir()
+		Expect(WriteJunit(dir, Run{Id: "r"}, results, check.SeverityHigh)).To(Succeed())
+		b, err := os.ReadFile(filepath.Join(dir, "junit.xml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring(`failures="1"`))
+		Expect(WriteJunit(dir, Run{Id: "r"}, results, check.SeverityCritical)).To(Succeed())
+		b, err = os.ReadFile(filepath.Join(dir, "junit.xml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring(`failures="0"`))
+	})
+})
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+const (
+	SarifVersion = "2.1.0"
+	SarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	Id                   string         `json:"id"`
+	Name                 string         `json:"name"`
+	ShortDescription     sarifText      `json:"shortDescription"`
+	FullDescription      *sarifText     `json:"fullDescription,omitempty"`
+	Help                 *sarifText     `json:"help,omitempty"`
+	DefaultConfiguration map[string]any `json:"defaultConfiguration"`
+	Properties           map[string]any `json:"properties"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifResult struct {
+	RuleId              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifText         `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	Properties          map[string]any    `json:"properties,omitempty"`
+}
+
+type sarifRun struct {
+	Tool        map[string]any   `json:"tool"`
+	Invocations []map[string]any `json:"invocations"`
+	Results     []sarifResult    `json:"results"`
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// sarif levels and the github code scanning security-severity for each severity.
+var sarifLevels = map[string]struct {
+	level    string
+	security string
+}{
+	check.SeverityCritical: {"error", "9.5"},
+	check.SeverityHigh:     {"error", "8.0"},
+	check.SeverityMedium:   {"warning", "5.5"},
+	check.SeverityLow:      {"note", "3.0"},
+	check.SeverityInfo:     {"note", "0.0"},
+}
+
+// WriteSarif writes path/findings.sarif. Every check becomes a rule and every object a
+// logical location named namespace/kind/name (cluster scoped objects have no namespace part).
+func WriteSarif(path string, run Run, results []check.Result, findings []check.Finding) error {
+	rules := make([]sarifRule, 0, len(results))
+	ruleIndex := make(map[string]int, len(results))
+	for _, r := range results {
+		c := r.Check
+		ruleIndex[c.Id] = len(rules)
+		rule := sarifRule{
+			Id:                   c.Id,
+			Name:                 ruleName(c.Title),
+			ShortDescription:     sarifText{c.Title},
+			DefaultConfiguration: map[string]any{"level": sarifLevels[c.Severity].level},
+			Properties: map[string]any{
+				"category":          c.Category,
+				"severity":          c.Severity,
+				"security-severity": sarifLevels[c.Severity].security,
+				"tags":              []string{c.Category, c.Severity},
+			},
+		}
+		if len(c.Controls) > 0 {
+			rule.Properties["controls"] = c.Controls
+		}
+		if c.Description != "" {
+			rule.FullDescription = &sarifText{c.Description}
+		}
+		if c.Remediation != "" {
+			rule.Help = &sarifText{c.Remediation}
+		}
+		rules = append(rules, rule)
+	}
+	sarifResults := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		sr := sarifResult{
+			RuleId:    f.CheckId,
+			RuleIndex: ruleIndex[f.CheckId],
+			Level:     sarifLevels[f.Severity].level,
+			Message:   sarifText{f.Message},
+			Locations: []sarifLocation{{LogicalLocations: logicalLocations(f)}},
+		}
+		if f.Fingerprint != "" {
+			sr.PartialFingerprints = map[string]string{"hcreport/v1": f.Fingerprint}
+		}
+		if f.Evidence != nil {
+			sr.Properties = map[string]any{"evidence": f.Evidence}
+		}
+		sarifResults = append(sarifResults, sr)
+	}
+	log := sarifLog{
+		Schema:  SarifSchema,
+		Version: SarifVersion,
+		Runs: []sarifRun{{
+			Tool: map[string]any{"driver": map[string]any{
+				"name":           GeneratorName,
+				"informationUri": GeneratorUri,
+				"rules":          rules,
+			}},
+			Invocations: []map[string]any{{
+				"executionSuccessful": true,
+				"endTimeUtc":          run.Date,
+				"properties":          map[string]any{"runId": run.Id},
+			}},
+			Results: sarifResults,
+		}},
+	}
+	b, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(path, "findings.sarif"), b, 0644)
+}
+
+func logicalLocations(f check.Finding) []sarifLogicalLocation {
+	if f.Name == "" {
+		return []sarifLogicalLocation{{Name: "cluster", FullyQualifiedName: "cluster", Kind: "module"}}
+	}
+	fqn := strings.Join([]string{f.Kind, f.Name}, "/")
+	if f.Namespace != "" {
+		fqn = f.Namespace + "/" + fqn
+	}
+	return []sarifLogicalLocation{{Name: f.Name, FullyQualifiedName: fqn, Kind: "resource"}}
+}
+
+// ruleName is the title in PascalCase as sarif expects for rule names.
+func ruleName(title string) string {
+	var sb strings.Builder
+	for _, w := range strings.Fields(title) {
+		sb.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return sb.String()
+}
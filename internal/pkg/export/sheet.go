@@ -0,0 +1,237 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+const InventorySheet = "inventory"
+
+// FindingColumns is the stable column order of every findings sheet.
+var FindingColumns = []string{"Check ID", "Severity", "Namespace", "Kind", "Name", "Message", "Remediation"}
+
+var InventoryColumns = []string{"Resource", "Group Version", "Namespace", "Count"}
+
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]string
+	// Numeric are the columns holding counts, written as numbers to workbooks. Others are text.
+	Numeric []int
+}
+
+// FindingSheets returns one sheet per category sorted by name.
+func FindingSheets(results []check.Result, findings []check.Finding) []Sheet {
+	remediation := map[string]string{}
+	for _, r := range results {
+		remediation[r.Check.Id] = r.Check.Remediation
+	}
+	byCategory := map[string]*Sheet{}
+	for _, f := range findings {
+		s, ok := byCategory[f.Category]
+		if !ok {
+			s = &Sheet{Name: f.Category, Header: FindingColumns}
+			byCategory[f.Category] = s
+		}
+		s.Rows = append(s.Rows, []string{f.CheckId, f.Severity, f.Namespace, f.Kind, f.Name, f.Message, remediation[f.CheckId]})
+	}
+	sheets := make([]Sheet, 0, len(byCategory))
+	for _, s := range byCategory {
+		sheets = append(sheets, *s)
+	}
+	sort.Slice(sheets, func(i, j int) bool { return sheets[i].Name < sheets[j].Name })
+	return sheets
+}
+
+// NewInventorySheet counts the dumped objects per resource and namespace.
+func NewInventorySheet(d *dump.Dump) Sheet {
+	s := Sheet{Name: InventorySheet, Header: InventoryColumns, Numeric: []int{3}}
+	for _, key := range d.Keys() {
+		name, gv, _ := strings.Cut(key, ".")
+		count := map[string]int{}
+		for _, item := range d.Items(key) {
+			ns := ""
+			if m, ok := item.(map[string]any); ok {
+				md, _ := m["metadata"].(map[string]any)
+				ns, _ = md["namespace"].(string)
+			}
+			count[ns]++
+		}
+		namespaces := make([]string, 0, len(count))
+		for ns := range count {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+		for _, ns := range namespaces {
+			s.Rows = append(s.Rows, []string{name, gv, ns, strconv.Itoa(count[ns])})
+		}
+	}
+	return s
+}
+
+// WriteCsv writes every sheet as path/<sheet name>.csv
+func WriteCsv(path string, sheets []Sheet) error {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	for _, s := range sheets {
+		f, err := os.Create(filepath.Join(path, s.Name+".csv"))
+		if err != nil {
+			return err
+		}
+		w := csv.NewWriter(f)
+		_ = w.Write(s.Header)
+		_ = w.WriteAll(s.Rows)
+		if err = f.Close(); err != nil {
+			return err
+		}
+		if err = w.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteXlsx writes a minimal office open xml workbook with a sheet per Sheet. Header rows are
+// bold, frozen and have an auto filter so the findings can be filtered right away in Excel.
+func WriteXlsx(file string, sheets []Sheet) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	z := zip.NewWriter(f)
+	var workbook, rels, types strings.Builder
+	names := map[string]bool{}
+	for i, s := range sheets {
+		n := i + 1
+		name := sheetName(s.Name, names)
+		workbook.WriteString(fmt.Sprintf(`<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(name), n, n))
+		rels.WriteString(fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n))
+		types.WriteString(fmt.Sprintf(`<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n))
+		if err = zipFile(z, fmt.Sprintf("xl/worksheets/sheet%d.xml", n), worksheet(s)); err != nil {
+			return err
+		}
+	}
+	stylesRel := len(sheets) + 1
+	rels.WriteString(fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, stylesRel))
+	parts := map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`,
+		"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + workbook.String() + `</sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + rels.String() + `</Relationships>`,
+		"xl/styles.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+			`</styleSheet>`,
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if err = zipFile(z, name, parts[name]); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+func worksheet(s Sheet) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	sb.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	sb.WriteString(`<sheetData>`)
+	writeRow := func(r int, cells []string, style int) {
+		sb.WriteString(fmt.Sprintf(`<row r="%d">`, r))
+		for c, v := range cells {
+			ref := cellRef(c, r)
+			if _, err := strconv.ParseInt(v, 10, 64); err == nil && style == 0 && slices.Contains(s.Numeric, c) {
+				sb.WriteString(fmt.Sprintf(`<c r="%s"><v>%s</v></c>`, ref, v))
+			} else {
+				sb.WriteString(fmt.Sprintf(`<c r="%s" t="inlineStr" s="%d"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlEscape(v)))
+			}
+		}
+		sb.WriteString(`</row>`)
+	}
+	writeRow(1, s.Header, 1)
+	for i, row := range s.Rows {
+		writeRow(i+2, row, 0)
+	}
+	sb.WriteString(`</sheetData>`)
+	sb.WriteString(fmt.Sprintf(`<autoFilter ref="A1:%s"/>`, cellRef(len(s.Header)-1, len(s.Rows)+1)))
+	sb.WriteString(`</worksheet>`)
+	return sb.String()
+}
+
+// cellRef turns zero based column and one based row into A1 notation.
+func cellRef(col int, row int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name + strconv.Itoa(row)
+}
+
+// sheetName makes a unique excel sheet name: at most 31 chars and none of []:*?/\
+func sheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "sheet"
+	}
+	if len(name) > 31 {
+		name = name[:31]
+	}
+	base := name
+	for i := 2; used[name]; i++ {
+		suffix := "~" + strconv.Itoa(i)
+		name = base[:min(len(base), 31-len(suffix))] + suffix
+	}
+	used[name] = true
+	return name
+}
+
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+func zipFile(z *zip.Writer, name string, content string) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(content))
+	return err
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+var _ = Describe("Sheet", func() {
+	DescribeTable("cell references",
+		func(col int, row int, expected string) {
+			Expect(cellRef(col, row)).To(Equal(expected))
+		},
+		Entry(nil, 0, 1, "A1"),
+		Entry(nil, 25, 2, "Z2"),
+		Entry(nil, 26, 3, "AA3"),
+		Entry(nil, 701, 4, "ZZ4"),
+		Entry(nil, 702, 5, "AAA5"),
+	)
+
+	DescribeTable("sheet names",
+		func(names []string, expected []string) {
+			used := map[string]bool{}
+			got := []string{}
+			for _, n := range names {
+				got = append(got, sheetName(n, used))
+			}
+			Expect(got).To(Equal(expected))
+		},
+		Entry("replace what excel does not take", []string{"a/b:c"}, []string{"a_b_c"}),
+		Entry("name empty ones", []string{""}, []string{"sheet"}),
+		Entry("cut at 31 characters", []string{strings.Repeat("x", 40)}, []string{strings.Repeat("x", 31)}),
+		Entry("number the repeated ones", []string{"a/b", "a:b", "a_b"}, []string{"a_b", "a_b~2", "a_b~3"}),
+		Entry("number long repeated ones within 31 characters", []string{strings.Repeat("x", 40), strings.Repeat("x", 35)},
+			[]string{strings.Repeat("x", 31), strings.Repeat("x", 29) + "~2"}),
+	)
+
+	It("makes a sheet per category sorted by name", func() {
+		results := []check.Result{{Check: check.Check{Id: "A-1", Remediation: "fix it"}}}
+		findings := []check.Finding{
+			{CheckId: "A-1", Category: "security", Severity: "high", Name: "x"},
+			{CheckId: "A-1", Category: "observability", Severity: "low", Name: "y"},
+		}
+		sheets := FindingSheets(results, findings)
+		Expect(sheets).To(HaveLen(2))
+		Expect(sheets[0].Name).To(Equal("observability"))
+		Expect(sheets[0].Header).To(Equal(FindingColumns))
+		Expect(sheets[1].Rows).To(Equal([][]string{{"A-1", "high", "", "", "x", "", "fix it"}}))
+	})
+
+	DescribeTable("workbook cells",
+		func(numeric []int, value string, expected string) {
+			file := filepath.Join(GinkgoT().TempDir(), "findings.xlsx")
+			Expect(WriteXlsx(file, []Sheet{{Name: "s", Header: []string{"Value"}, Rows: [][]string{{value}}, Numeric: numeric}})).To(Succeed())
+			z, err := zip.OpenReader(file)
+			Expect(err).NotTo(HaveOccurred())
+			defer z.Close()
+			r, err := z.Open("xl/worksheets/sheet1.xml")
+			Expect(err).NotTo(HaveOccurred())
+			b, err := io.ReadAll(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(ContainSubstring(expected))
+		},
+		Entry("hold counts as numbers", []int{0}, "12", `<c r="A2"><v>12</v></c>`),
+		Entry("hold numbers out of count columns as text", nil, "12", `<t xml:space="preserve">12</t>`),
+		Entry("hold what is not an integer as text", []int{0}, "1e5", `<t xml:space="preserve">1e5</t>`),
+		Entry("hold nan as text", []int{0}, "nan", `<t xml:space="preserve">nan</t>`),
+		Entry("escape text", nil, "<a & b>", `&lt;a &amp; b&gt;`),
+	)
+
+	It("writes a csv per sheet", func() {
+		dir := GinkgoT().TempDir()
+		Expect(WriteCsv(dir, []Sheet{{Name: "s", Header: []string{"A", "B"}, Rows: [][]string{{"1", "x,y"}}}})).To(Succeed())
+		b, err := os.ReadFile(filepath.Join(dir, "s.csv"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("A,B\n1,\"x,y\"\n"))
+	})
+})
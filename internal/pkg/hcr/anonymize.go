@@ -0,0 +1,75 @@
+package hcr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/anonymize"
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+const (
+	// anonymizationDir keeps the sealed mappings out of the runs that get shared.
+	anonymizationDir = "anonymization"
+	// sharedDir holds the anonymized copies of the runs, the runs themselves stay as they are.
+	sharedDir = "shared"
+)
+
+// anonymizeSpec is spec.anonymize. The key Secret value derives the pseudonyms and seals the mapping.
+type anonymizeSpec struct {
+	Enabled   bool         `json:"enabled"`
+	KeySecret secretKeyRef `json:"keySecret"`
+	anonymize.Spec
+}
+
+// anonymizeRun writes a copy of the run with the cluster identifiers replaced to
+// reportPath/shared/<namespace>/<config>/<run> once all its files are written, the copy is what
+// gets signed, bundled and shared from then on. The mapping, encrypted with the same key, goes to
+// reportPath/anonymization/<namespace>/<config>/<run>.map.
+func (rec *reconciler) anonymizeRun(b *build) error {
+	spec := anonymizeSpec{}
+	if err := rec.specAs(".anonymize", &spec); err != nil {
+		return err
+	}
+	if !spec.Enabled {
+		return rec.updateStatus("del(.anonymization)")
+	}
+	key, err := rec.secretValue(spec.KeySecret)
+	if err != nil {
+		return fmt.Errorf("spec.anonymize.keySecret: %w", err)
+	}
+	a, err := anonymize.New(key, spec.Spec, b.dump)
+	if err != nil {
+		return err
+	}
+	a.Reserve(check.Severities...)
+	a.Reserve(check.StatusPass, check.StatusFail, check.StatusError, check.StatusNotEvaluated)
+	for _, r := range b.results {
+		a.Reserve(r.Check.Id, r.Check.Category)
+	}
+	shared := filepath.Join(rec.configPath(sharedDir), b.id)
+	if err = os.RemoveAll(shared); err != nil {
+		return err
+	}
+	if err = a.Copy(b.path, shared); err != nil {
+		return err
+	}
+	b.shared = shared
+	sealed, err := a.Seal()
+	if err != nil {
+		return err
+	}
+	dir := rec.configPath(anonymizationDir)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	mapping := filepath.Join(dir, b.id+".map")
+	if err = os.WriteFile(mapping, sealed, 0600); err != nil {
+		return err
+	}
+	logger.Info("run anonymized", zap.String("run", b.id), zap.Int("identifiers", len(a.Mapping())))
+	return rec.statusSet(".anonymization", map[string]any{"run": b.id, "path": shared, "mapping": mapping, "identifiers": len(a.Mapping())})
+}
@@ -0,0 +1,74 @@
+package hcr
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/diff"
+	"adoption.latam/hcr/internal/pkg/export"
+)
+
+// annotationBaseline on a Config names the baseline run when spec.baseline.run is not set.
+const annotationBaseline = "hcr.adoption.latam/baseline"
+
+// baselineSpec is spec.baseline. run is the id of the run whose findings are accepted as known.
+type baselineSpec struct {
+	Run string `json:"run"`
+}
+
+// applyBaseline keeps in the findings only the regressions from the baseline run. The known
+// findings are taken out of the results as well so thresholds, junit and sarif only report
+// regressions. The score is computed before and still accounts for every finding. The baseline
+// is one of the runs of the Config.
+func (rec *reconciler) applyBaseline(b *build) error {
+	spec := baselineSpec{}
+	if err := rec.specAs(".baseline", &spec); err != nil {
+		return err
+	}
+	run := spec.Run
+	if run == "" {
+		run = rec.cfg.Annotations[annotationBaseline]
+	}
+	if run == "" || run == b.id {
+		return rec.updateStatus("del(.baseline)")
+	}
+	if !runIdRe.MatchString(run) {
+		return fmt.Errorf("baseline: %s is not a run id", run)
+	}
+	doc, err := export.ReadFindings(filepath.Join(rec.configPath(runsDir), run))
+	if err != nil {
+		return fmt.Errorf("baseline run %s: %w", run, err)
+	}
+	d := diff.CompareFindings(run, doc.All(), b.findings)
+	b.baseline = &export.Baseline{Run: run, Findings: d.Persisting}
+	b.findings = d.New
+	known := make([]string, len(d.Persisting))
+	for i, f := range d.Persisting {
+		known[i] = f.Fingerprint
+	}
+	for i, r := range b.results {
+		if len(r.Findings) == 0 {
+			continue
+		}
+		b.results[i].Findings = slices.DeleteFunc(slices.Clone(r.Findings), func(f check.Finding) bool {
+			return slices.Contains(known, f.Fingerprint)
+		})
+		if r.Status == check.StatusFail && len(b.results[i].Findings) == 0 {
+			b.results[i].Status = check.StatusPass
+		}
+	}
+	logger.Info("baseline applied", zap.String("baseline", run), zap.Int("known", len(d.Persisting)), zap.Int("regressions", len(d.New)))
+	return rec.statusSet(".baseline", map[string]any{"run": run, "known": len(d.Persisting), "regressions": len(d.New)})
+}
+
+// allFindings are the findings of the run including the ones known from the baseline.
+func (b *build) allFindings() []check.Finding {
+	if b.baseline == nil {
+		return b.findings
+	}
+	return append(slices.Clone(b.findings), b.baseline.Findings...)
+}
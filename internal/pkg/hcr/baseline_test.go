@@ -0,0 +1,64 @@
+package hcr
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+var _ = Describe("Baseline", func() {
+	It("keeps only the regressions from the baseline run", func() {
+		rec := newReconciler("cfg", "baseline: {run: 20261018T000000Z}")
+		writeRun(rec, "20261018T000000Z", finding("A", "x"), finding("A", "gone"))
+		b := newBuild(rec, "20261019T000000Z")
+		b.findings = []check.Finding{finding("A", "x"), finding("A", "y")}
+		b.results = []check.Result{failed("A", finding("A", "x")), failed("A", finding("A", "x"), finding("A", "y"))}
+		Expect(rec.applyBaseline(b)).To(Succeed())
+		Expect(b.findings).To(Equal([]check.Finding{finding("A", "y")}))
+		Expect(b.baseline.Findings).To(Equal([]check.Finding{finding("A", "x")}))
+		Expect(b.allFindings()).To(HaveLen(2))
+		Expect(b.results[0].Status).To(Equal(check.StatusPass))
+		Expect(b.results[0].Findings).To(BeEmpty())
+		Expect(b.results[1].Findings).To(Equal([]check.Finding{finding("A", "y")}))
+		counts := map[string]any{}
+		status(rec, ".baseline", &counts)
+		Expect(counts).To(Equal(map[string]any{"run": "20261018T000000Z", "known": 1.0, "regressions": 1.0}))
+	})
+
+	It("takes the baseline run from the annotation", func() {
+		rec := newReconciler("cfg", "")
+		rec.cfg.Annotations = map[string]string{annotationBaseline: "20261018T000000Z"}
+		writeRun(rec, "20261018T000000Z", finding("A", "x"))
+		b := newBuild(rec, "20261019T000000Z")
+		b.findings = []check.Finding{finding("A", "x")}
+		Expect(rec.applyBaseline(b)).To(Succeed())
+		Expect(b.findings).To(BeEmpty())
+	})
+
+	It("is no baseline of itself", func() {
+		rec := newReconciler("cfg", "baseline: {run: 20261019T000000Z}")
+		b := newBuild(rec, "20261019T000000Z")
+		b.findings = []check.Finding{finding("A", "x")}
+		Expect(rec.applyBaseline(b)).To(Succeed())
+		Expect(b.baseline).To(BeNil())
+		Expect(b.allFindings()).To(HaveLen(1))
+	})
+
+	DescribeTable("refusing what is no run id",
+		func(spec string, annotation string) {
+			rec := newReconciler("cfg", spec)
+			rec.cfg.Annotations = map[string]string{annotationBaseline: annotation}
+			writeRun(newReconciler("other", ""), "20261018T000000Z", finding("A", "x"))
+			Expect(rec.applyBaseline(newBuild(rec, "20261019T000000Z"))).To(MatchError(ContainSubstring("is not a run id")))
+		},
+		Entry("in the spec", "baseline: {run: ../other/20261018T000000Z}", ""),
+		Entry("in the annotation", "", "../other/20261018T000000Z"),
+	)
+
+	It("only takes a run of the same Config", func() {
+		rec := newReconciler("cfg", "baseline: {run: 20261018T000000Z}")
+		writeRun(newReconciler("other", ""), "20261018T000000Z", finding("A", "x"))
+		Expect(rec.applyBaseline(newBuild(rec, "20261019T000000Z"))).To(MatchError(ContainSubstring("baseline run 20261018T000000Z")))
+	})
+})
@@ -0,0 +1,324 @@
+package hcr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mauricioscastro/kcdump/pkg/yjq"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/compliance"
+	"adoption.latam/hcr/internal/pkg/diff"
+	"adoption.latam/hcr/internal/pkg/dump"
+	"adoption.latam/hcr/internal/pkg/export"
+	"adoption.latam/hcr/internal/pkg/history"
+	"adoption.latam/hcr/internal/pkg/i18n"
+	"adoption.latam/hcr/internal/pkg/redact"
+	"adoption.latam/hcr/internal/pkg/render"
+	"adoption.latam/hcr/internal/pkg/score"
+	"adoption.latam/hcr/internal/pkg/transform"
+	"adoption.latam/hcr/internal/pkg/util"
+	"adoption.latam/hcr/internal/pkg/waiver"
+)
+
+// build holds everything produced along one run of the building phase.
+type build struct {
+	id   string
+	date string
+	path string
+	// shared is the directory signed, bundled and sent to the sinks, the run or its anonymized copy.
+	shared     string
+	dump       *dump.Dump
+	results    []check.Result
+	findings   []check.Finding
+	waived     []waiver.Waived
+	expired    []waiver.Waiver
+	baseline   *export.Baseline
+	diff       *diff.Findings
+	drift      *diff.Drift
+	history    []history.Point
+	score      *score.Card
+	compliance []compliance.Framework
+	redaction  *redact.Summary
+	locale     *i18n.Localizer
+	outputs    []string
+	site       render.Site
+	ctx        render.Context
+}
+
+// reportSpec is spec.report. outputs selects the formats to produce.
+type reportSpec struct {
+	Outputs   []string      `json:"outputs"`
+	Site      render.Site   `json:"site"`
+	Templates templatesSpec `json:"templates"`
+}
+
+type templatesSpec struct {
+	ConfigMap string `json:"configMap"`
+	Embedded  *bool  `json:"embedded"`
+}
+
+type checksSpec struct {
+	Packs []string `json:"packs"`
+	Skip  []string `json:"skip"`
+}
+
+// build runs the building phase. Whatever it got to write is encrypted, when it fails too.
+func (rec *reconciler) build() (err error) {
+	now := time.Now()
+	b := &build{id: now.UTC().Format("20060102T150405Z"), date: now.Format(time.RFC3339)}
+	b.path = filepath.Join(rec.configPath(runsDir), b.id)
+	b.shared = b.path
+	if err = os.MkdirAll(b.path, 0755); err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, rec.encryptAtRest())
+	}()
+	if err = rec.statusSet(".run", map[string]string{"id": b.id, "path": b.path}); err != nil {
+		return err
+	}
+	if b.dump, err = dump.Load(filepath.Join(reportPath, dumpDir)); err != nil {
+		return err
+	}
+	if err = loadRedaction(b); err != nil {
+		return err
+	}
+	if err = rec.runTransforms(b); err != nil {
+		return err
+	}
+	if err = rec.evaluateChecks(b); err != nil {
+		return err
+	}
+	if err = rec.scoreFindings(b); err != nil {
+		return err
+	}
+	if err = rec.mapCompliance(b); err != nil {
+		return err
+	}
+	if err = rec.applyBaseline(b); err != nil {
+		return err
+	}
+	if err = rec.diffFindings(b); err != nil {
+		return err
+	}
+	if err = rec.planRemediation(b); err != nil {
+		return err
+	}
+	if err = rec.driftObjects(b); err != nil {
+		return err
+	}
+	if err = rec.recordHistory(b); err != nil {
+		return err
+	}
+	if err = rec.renderReport(b); err != nil {
+		return err
+	}
+	if err = rec.writeFindings(b); err != nil {
+		return err
+	}
+	if err = rec.anonymizeRun(b); err != nil {
+		return err
+	}
+	if err = rec.writeManifest(b); err != nil {
+		return err
+	}
+	if err = rec.bundleRun(b); err != nil {
+		return err
+	}
+	if err = rec.uploadS3(b); err != nil {
+		return err
+	}
+	return rec.pushOCI(b)
+}
+
+// runTransforms builds the stage 2 documents out of spec.transforms. Failing steps are
+// recorded in status and do not stop the build.
+func (rec *reconciler) runTransforms(b *build) error {
+	steps := []transform.Step{}
+	if err := rec.specAs(".transforms", &steps); err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	results, err := transform.Run(b.dump, steps, filepath.Join(b.path, dataDir))
+	if err != nil {
+		return err
+	}
+	return rec.statusSet(".transforms", results)
+}
+
+// enabledChecks are the checks of spec.checks.packs not skipped.
+func (rec *reconciler) enabledChecks() ([]check.Check, error) {
+	spec := checksSpec{}
+	if err := rec.specAs(".checks", &spec); err != nil {
+		return nil, err
+	}
+	checks, err := check.LoadPacks(spec.Packs...)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(checks, func(c check.Check) bool { return slices.Contains(spec.Skip, c.Id) }), nil
+}
+
+func (rec *reconciler) evaluateChecks(b *build) error {
+	enabled, err := rec.enabledChecks()
+	if err != nil {
+		return err
+	}
+	b.results = check.Evaluate(b.dump, enabled)
+	if err = rec.applyWaivers(b); err != nil {
+		return err
+	}
+	b.findings = check.Findings(b.results)
+	logger.Info("checks evaluated", zap.Int("checks", len(enabled)), zap.Int("findings", len(b.findings)))
+	if err = util.WriteYaml(filepath.Join(b.path, "results.yaml"), map[string]any{"results": b.results}); err != nil {
+		return err
+	}
+	if err = util.WriteYaml(filepath.Join(b.path, "findings.yaml"), map[string]any{"findings": b.findings, "waived": b.waived}); err != nil {
+		return err
+	}
+	status := map[string]int{"total": len(b.results)}
+	for _, r := range b.results {
+		status[r.Status]++
+	}
+	if err = rec.statusSet(".checks", status); err != nil {
+		return err
+	}
+	findings := check.CountBySeverity(b.findings)
+	findings["total"] = len(b.findings)
+	return rec.statusSet(".findings", findings)
+}
+
+func (rec *reconciler) renderReport(b *build) error {
+	spec := reportSpec{Outputs: []string{outputMkDocs, outputHtml, outputJunit, outputCsv, outputXlsx}}
+	if err := rec.specAs(".report", &spec); err != nil {
+		return err
+	}
+	if len(spec.Site.Authors) == 0 {
+		if err := rec.specAs(".hcreport.authors", &spec.Site.Authors); err != nil {
+			return err
+		}
+	}
+	locale, err := rec.reportLanguage()
+	if err != nil {
+		return err
+	}
+	if spec.Site.Language == "" {
+		spec.Site.Language = locale.Lang
+	}
+	b.outputs = spec.Outputs
+	b.site = spec.Site
+	b.locale = locale
+	data, err := render.LoadData(filepath.Join(b.path, dataDir))
+	if err != nil {
+		return err
+	}
+	var cfgSpec any
+	if err = json.Unmarshal(rec.cfg.Spec, &cfgSpec); err != nil && len(rec.cfg.Spec) > 0 {
+		return err
+	}
+	b.ctx = render.Context{
+		Run:       render.Run{Id: b.id, Date: b.date},
+		Spec:      cfgSpec,
+		Data:      data,
+		Drift:     b.drift,
+		History:   b.history,
+		Score:     b.score,
+		Expired:   b.expired,
+		Redaction: b.redaction,
+	}
+	b.localize(&b.ctx)
+	if slices.Contains(b.outputs, outputMkDocs) {
+		if err = rec.renderMkDocs(b, spec.Templates); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(b.outputs, outputHtml) {
+		if err = render.WriteHtml(filepath.Join(b.path, "report.html"), b.ctx, b.site); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rec *reconciler) renderMkDocs(b *build, spec templatesSpec) error {
+	templates, err := rec.loadTemplates(spec)
+	if err != nil {
+		return err
+	}
+	r, err := render.New(templates)
+	if err != nil {
+		return err
+	}
+	sitePath := filepath.Join(b.path, siteDir)
+	pages, err := r.Render(filepath.Join(sitePath, render.DocsDir), b.ctx)
+	if err != nil {
+		return err
+	}
+	logger.Info("report rendered", zap.Int("pages", len(pages)))
+	return render.WriteMkDocs(sitePath, pages, b.site)
+}
+
+// loadTemplates merges the embedded templates with the ones found in spec.report.templates.configMap
+// which must live in the Config namespace. ConfigMap keys override embedded templates of the same name.
+func (rec *reconciler) loadTemplates(spec templatesSpec) (map[string]string, error) {
+	templates := map[string]string{}
+	if spec.Embedded == nil || *spec.Embedded {
+		var err error
+		if templates, err = render.DefaultTemplates(); err != nil {
+			return nil, err
+		}
+	}
+	if spec.ConfigMap != "" {
+		cm := corev1.ConfigMap{}
+		if err := rec.c.Get(rec.ctx, types.NamespacedName{Namespace: rec.cfg.Namespace, Name: spec.ConfigMap}, &cm); err != nil {
+			return nil, fmt.Errorf("templates configmap: %w", err)
+		}
+		for k, v := range cm.Data {
+			templates[k] = v
+		}
+	}
+	return templates, nil
+}
+
+// specAs decodes the result of a jq query over the spec into v. null results leave v untouched.
+func (rec *reconciler) specAs(jqExpr string, v any) error {
+	if err := jqAs(jqExpr, string(rec.cfg.Spec), v); err != nil {
+		return fmt.Errorf("spec %s: %w", jqExpr, err)
+	}
+	return nil
+}
+
+// jqAs decodes the json result of jqExpr into v. null or empty results leave v untouched.
+func jqAs(jqExpr string, input string, v any) error {
+	// JqEval prints string results raw. tojson keeps them quoted.
+	s, err := yjq.JqEval("("+jqExpr+") | tojson", input)
+	if err != nil {
+		return err
+	}
+	if s == "" || s == "null" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
+// statusSet assigns v encoded as json to the status path.
+func (rec *reconciler) statusSet(path string, v any) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// updateStatus formats the expression before evaluating it
+	return rec.updateStatus(path + " = " + strings.ReplaceAll(string(j), "%", "%%"))
+}
@@ -0,0 +1,60 @@
+package hcr
+
+import (
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/bundle"
+)
+
+const bundlesDir = "bundles"
+
+// BundlesPath is where the run bundles served by the download endpoint are kept.
+func BundlesPath() string {
+	return filepath.Join(reportPath, bundlesDir)
+}
+
+// bundleSpec is spec.bundle. limit is the number of bundles kept per Config.
+type bundleSpec struct {
+	Enabled bool `json:"enabled"`
+	Limit   int  `json:"limit"`
+}
+
+// bundleRun packages the shared copy of the run with its manifest for download under
+// bundles/<namespace>/<config> when spec.bundle is enabled or a sink sends the bundle.
+// The oldest bundles past the limit are removed.
+func (rec *reconciler) bundleRun(b *build) error {
+	spec := bundleSpec{Limit: 10}
+	if err := rec.specAs(".bundle", &spec); err != nil {
+		return err
+	}
+	sinks := false
+	if err := rec.specAs(`(.sinks.s3.endpoint // "") != "" or (.sinks.oci.repository // "") != ""`, &sinks); err != nil {
+		return err
+	}
+	if !spec.Enabled && !sinks {
+		return rec.updateStatus("del(.bundle)")
+	}
+	file := bundle.Path(BundlesPath(), rec.cfg.Namespace, rec.cfg.Name, b.id)
+	info, err := bundle.Write(b.shared, file)
+	if err != nil {
+		return err
+	}
+	logger.Info("run bundled", zap.String("file", info.File), zap.Int64("size", info.Size))
+	if spec.Limit > 0 {
+		removed, err := bundle.Prune(filepath.Dir(file), spec.Limit)
+		if err != nil {
+			return err
+		}
+		if len(removed) > 0 {
+			logger.Info("bundles removed", zap.Strings("runs", removed))
+		}
+	}
+	return rec.statusSet(".bundle", map[string]any{
+		"file":   info.File,
+		"size":   info.Size,
+		"sha256": info.Sha256,
+		"path":   "/" + filepath.ToSlash(filepath.Join("runs", rec.cfg.Namespace, rec.cfg.Name, b.id, "bundle"+bundle.Ext)),
+	})
+}
@@ -0,0 +1,34 @@
+package hcr
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/compliance"
+)
+
+// mapCompliance maps the results to the framework controls of their checks before the
+// baseline hides known findings so the evidence reflects the whole cluster.
+func (rec *reconciler) mapCompliance(b *build) error {
+	b.compliance = compliance.Map(b.results)
+	if len(b.compliance) == 0 {
+		return rec.updateStatus("del(.compliance)")
+	}
+	summary := map[string]map[string]int{}
+	for _, fw := range b.compliance {
+		summary[fw.Name] = fw.Summary
+		logger.Info("compliance mapped", zap.String("framework", fw.Name), zap.Int("controls", len(fw.Controls)),
+			zap.Int("fail", fw.Summary[compliance.StatusFail]))
+	}
+	j, err := json.MarshalIndent(b.compliance, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(b.path, "compliance.json"), j, 0644); err != nil {
+		return err
+	}
+	return rec.statusSet(".compliance", summary)
+}
@@ -0,0 +1,82 @@
+package hcr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/diff"
+	"adoption.latam/hcr/internal/pkg/export"
+)
+
+// diffSpec is spec.diff. previous is the id of the run to compare with.
+type diffSpec struct {
+	Previous string `json:"previous"`
+}
+
+// diffFindings compares the findings with the ones of spec.diff.previous or, when not set,
+// of the latest earlier run of the Config that got to write its findings.json. Findings known
+// from a baseline take part in the comparison on both sides.
+func (rec *reconciler) diffFindings(b *build) error {
+	spec := diffSpec{}
+	if err := rec.specAs(".diff", &spec); err != nil {
+		return err
+	}
+	runs := rec.configPath(runsDir)
+	previous, err := previousRun(runs, b.id, spec.Previous)
+	if err != nil {
+		return err
+	}
+	if previous == "" {
+		logger.Info("no previous run to diff with")
+		return rec.updateStatus("del(.diff)")
+	}
+	doc, err := export.ReadFindings(filepath.Join(runs, previous))
+	if err != nil {
+		return fmt.Errorf("previous run %s: %w", previous, err)
+	}
+	d := diff.CompareFindings(previous, doc.All(), b.allFindings())
+	b.diff = &d
+	logger.Info("findings diff", zap.String("previous", previous),
+		zap.Int("new", len(d.New)), zap.Int("resolved", len(d.Resolved)), zap.Int("persisting", len(d.Persisting)))
+	j, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(b.path, "diff.json"), j, 0644); err != nil {
+		return err
+	}
+	return rec.statusSet(".diff", d.Counts())
+}
+
+// previousRun returns wanted if it completed or else the latest completed run in runs before
+// current. A run is completed once it wrote its findings.json.
+func previousRun(runs string, current string, wanted string) (string, error) {
+	if wanted != "" {
+		if !runIdRe.MatchString(wanted) {
+			return "", fmt.Errorf("spec.diff.previous: %s is not a run id", wanted)
+		}
+		if _, err := os.Stat(filepath.Join(runs, wanted, "findings.json")); err != nil {
+			return "", fmt.Errorf("spec.diff.previous: run %s has no findings: %w", wanted, err)
+		}
+		return wanted, nil
+	}
+	entries, err := os.ReadDir(runs)
+	if err != nil {
+		return "", err
+	}
+	// run ids are timestamps and ReadDir sorts by name
+	for i := len(entries) - 1; i >= 0; i-- {
+		id := entries[i].Name()
+		if !entries[i].IsDir() || id >= current {
+			continue
+		}
+		if _, err = os.Stat(filepath.Join(runs, id, "findings.json")); err == nil {
+			return id, nil
+		}
+	}
+	return "", nil
+}
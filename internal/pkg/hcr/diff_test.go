@@ -0,0 +1,78 @@
+package hcr
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/export"
+)
+
+// finding is a low severity finding of check on the object name.
+func finding(checkId string, name string) check.Finding {
+	f := check.Finding{CheckId: checkId, Title: checkId, Category: "c", Severity: check.SeverityLow, Kind: "Pod", Name: name}
+	f.Fingerprint = check.Fingerprint(f)
+	return f
+}
+
+// writeRun writes the findings.json of run id of rec.
+func writeRun(rec *reconciler, id string, findings ...check.Finding) {
+	path := filepath.Join(rec.configPath(runsDir), id)
+	Expect(os.MkdirAll(path, 0755)).To(Succeed())
+	Expect(export.WriteFindings(path, export.NewFindings(export.Run{Id: id}, nil, append([]check.Finding{}, findings...)))).To(Succeed())
+}
+
+var _ = Describe("Diff", func() {
+	It("compares with the latest earlier run of the same Config", func() {
+		rec := newReconciler("cfg", "")
+		other := newReconciler("other", "")
+		writeRun(rec, "20261017T000000Z", finding("A", "old"))
+		writeRun(rec, "20261018T000000Z", finding("A", "x"))
+		writeRun(other, "20261018T120000Z", finding("A", "y"))
+		Expect(os.MkdirAll(filepath.Join(rec.configPath(runsDir), "20261018T180000Z"), 0755)).To(Succeed())
+		b := newBuild(rec, "20261019T000000Z")
+		b.findings = []check.Finding{finding("A", "x")}
+		Expect(rec.diffFindings(b)).To(Succeed())
+		Expect(b.diff.Previous).To(Equal("20261018T000000Z"))
+		Expect(b.diff.New).To(BeEmpty())
+		Expect(b.diff.Resolved).To(BeEmpty())
+		Expect(b.diff.Persisting).To(HaveLen(1))
+	})
+
+	It("has nothing to compare with when only other Configs ran", func() {
+		rec := newReconciler("cfg", "")
+		writeRun(newReconciler("other", ""), "20261018T000000Z", finding("A", "y"))
+		b := newBuild(rec, "20261019T000000Z")
+		Expect(rec.diffFindings(b)).To(Succeed())
+		Expect(b.diff).To(BeNil())
+	})
+
+	It("refuses a previous run of another Config", func() {
+		rec := newReconciler("cfg", "diff: {previous: 20261018T000000Z}")
+		writeRun(newReconciler("other", ""), "20261018T000000Z")
+		b := newBuild(rec, "20261019T000000Z")
+		Expect(rec.diffFindings(b)).To(MatchError(ContainSubstring("has no findings")))
+	})
+
+	It("refuses a previous run that is no run id", func() {
+		rec := newReconciler("cfg", "diff: {previous: ../other/20261018T000000Z}")
+		writeRun(newReconciler("other", ""), "20261018T000000Z", finding("A", "y"))
+		b := newBuild(rec, "20261019T000000Z")
+		Expect(rec.diffFindings(b)).To(MatchError(ContainSubstring("is not a run id")))
+	})
+
+	It("writes the diff to the run", func() {
+		rec := newReconciler("cfg", "")
+		writeRun(rec, "20261018T000000Z", finding("A", "x"))
+		b := newBuild(rec, "20261019T000000Z")
+		Expect(rec.diffFindings(b)).To(Succeed())
+		Expect(filepath.Join(b.path, "diff.json")).To(BeAnExistingFile())
+		counts := map[string]any{}
+		status(rec, ".diff", &counts)
+		Expect(counts).To(HaveKeyWithValue("resolved", 1.0))
+		Expect(b.diff.Resolved).To(Equal([]check.Finding{finding("A", "x")}))
+	})
+})
@@ -0,0 +1,45 @@
+package hcr
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/diff"
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+// driftSpec is spec.drift. reportPath is a directory laid out like reportPath, usually a copy
+// kept from an earlier visit, whose dump the current one is compared with.
+type driftSpec struct {
+	ReportPath string `json:"reportPath"`
+}
+
+func (rec *reconciler) driftObjects(b *build) error {
+	spec := driftSpec{}
+	if err := rec.specAs(".drift", &spec); err != nil {
+		return err
+	}
+	if spec.ReportPath == "" {
+		return rec.updateStatus("del(.drift)")
+	}
+	before, err := dump.Load(filepath.Join(spec.ReportPath, dumpDir))
+	if err != nil {
+		return err
+	}
+	d := diff.Objects(before, b.dump)
+	b.drift = &d
+	counts := d.Counts()
+	logger.Info("object drift", zap.String("before", before.Path()),
+		zap.Int("added", counts["added"]), zap.Int("removed", counts["removed"]), zap.Int("modified", counts["modified"]))
+	j, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(b.path, "drift.json"), j, 0644); err != nil {
+		return err
+	}
+	return rec.statusSet(".drift", counts)
+}
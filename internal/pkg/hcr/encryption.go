@@ -0,0 +1,108 @@
+package hcr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	hcrv1 "adoption.latam/hcr/api/v1"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+)
+
+// encryptionSpec is spec.encryption. Runs encrypted with the keys of previousKeySecrets stay
+// readable and get re-encrypted with the keySecret one on the next build.
+type encryptionSpec struct {
+	Enabled            bool           `json:"enabled"`
+	KeySecret          secretKeyRef   `json:"keySecret"`
+	PreviousKeySecrets []secretKeyRef `json:"previousKeySecrets"`
+}
+
+// useEncryption registers the keyring of spec.encryption with crypt so everything read from
+// reportPath is decrypted and keeps it to encrypt with. With encryption disabled the keys still
+// decrypt what was written before.
+func (rec *reconciler) useEncryption() error {
+	k, err := rec.keyring()
+	if err != nil {
+		return err
+	}
+	rec.keys = k
+	crypt.Register(rec.cfg.Namespace+"/"+rec.cfg.Name, k)
+	return nil
+}
+
+func (rec *reconciler) keyring() (crypt.Keyring, error) {
+	spec := encryptionSpec{}
+	k := crypt.Keyring{}
+	if err := rec.specAs(".encryption", &spec); err != nil {
+		return k, err
+	}
+	for _, ref := range spec.PreviousKeySecrets {
+		p, err := rec.secretValue(ref)
+		if err != nil {
+			return k, fmt.Errorf("spec.encryption.previousKeySecrets: %w", err)
+		}
+		k.Previous = append(k.Previous, p)
+	}
+	if spec.KeySecret.Name == "" {
+		if spec.Enabled {
+			return k, fmt.Errorf("spec.encryption.keySecret is mandatory")
+		}
+		return k, nil
+	}
+	key, err := rec.secretValue(spec.KeySecret)
+	if err != nil {
+		return k, fmt.Errorf("spec.encryption.keySecret: %w", err)
+	}
+	if len(key) < crypt.MinKeySize {
+		return k, fmt.Errorf("spec.encryption.keySecret: the key must have at least %d bytes", crypt.MinKeySize)
+	}
+	if !spec.Enabled {
+		k.Previous = append(k.Previous, key)
+		return k, nil
+	}
+	k.Key = key
+	return k, nil
+}
+
+// LoadKeys registers the keyring of the Config namespace/name with crypt so the files it
+// encrypted can be read by someone else than its reconciler, right after a restart too.
+func LoadKeys(ctx context.Context, c client.Client, namespace string, name string) error {
+	cfg := &hcrv1.Config{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, cfg); err != nil {
+		return err
+	}
+	if len(cfg.Status) == 0 {
+		cfg.Status = json.RawMessage("{}")
+	}
+	rec := &reconciler{c: c, ctx: ctx, cfg: cfg}
+	if err := rec.useEncryption(); err != nil {
+		return fmt.Errorf("%s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// encryptAtRest encrypts the runs of the Config, with its history and audit trail, their shared
+// copies and bundles. Files still under a previous key are re-encrypted with the current one. The
+// files of the other Configs are theirs to encrypt and the sealed anonymization mappings are left
+// alone. The dump is encrypted by redactDump as it is written.
+func (rec *reconciler) encryptAtRest() error {
+	if !rec.keys.Enabled() {
+		return rec.updateStatus("del(.encryption)")
+	}
+	s, err := rec.keys.Encrypt(
+		rec.configPath(runsDir),
+		rec.configPath(sharedDir),
+		rec.configPath(bundlesDir),
+	)
+	if err != nil {
+		return err
+	}
+	logger.Info("encrypted at rest", zap.String("keyId", s.KeyId),
+		zap.Int("encrypted", s.Encrypted), zap.Int("rotated", s.Rotated))
+	return rec.statusSet(".encryption", s)
+}
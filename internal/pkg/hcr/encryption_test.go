@@ -0,0 +1,132 @@
+package hcr
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+)
+
+// keyId is the id of the key file is encrypted with or "" when it is in clear.
+func keyId(file string) string {
+	f, err := os.Open(file)
+	Expect(err).NotTo(HaveOccurred())
+	defer f.Close()
+	header := make([]byte, 64)
+	n, _ := io.ReadFull(f, header)
+	id, _ := crypt.Encrypted(header[:n])
+	return id
+}
+
+// keySecret is the Secret hcr/name holding key.
+func keySecret(name string, key string) *corev1.Secret {
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "hcr", Name: name}, Data: map[string][]byte{"key": []byte(key)}}
+}
+
+// encrypted is the spec encrypting with the key of the Secret hcr/secret.
+func encrypted(secret string) string {
+	return "encryption: {enabled: true, keySecret: {name: " + secret + ", key: key}}"
+}
+
+// writeFile writes a clear file to path under the dir of rec.
+func writeFile(rec *reconciler, dir string, path string) string {
+	file := filepath.Join(rec.configPath(dir), path)
+	Expect(os.MkdirAll(filepath.Dir(file), 0755)).To(Succeed())
+	Expect(os.WriteFile(file, []byte("clear"), 0644)).To(Succeed())
+	return file
+}
+
+var _ = Describe("Encryption", func() {
+	const (
+		keyA = "0123456789abcdef0123456789abcdef"
+		keyB = "fedcba9876543210fedcba9876543210"
+	)
+
+	It("only encrypts the files of the Config", func() {
+		rec := newReconciler("cfg", encrypted("a"), keySecret("a", keyA))
+		other := newReconciler("other", "")
+		own := []string{
+			writeFile(rec, runsDir, "20261019T000000Z/findings.json"),
+			writeFile(rec, runsDir, remediationAuditFile),
+			writeFile(rec, sharedDir, "20261019T000000Z/findings.json"),
+			writeFile(rec, bundlesDir, "20261019T000000Z.tar.gz"),
+		}
+		others := writeFile(other, runsDir, "20261019T000000Z/findings.json")
+		dump := filepath.Join(reportPath, dumpDir, "configmaps.yaml")
+		writeDump(map[string]string{"configmaps": "[]\n"})
+		Expect(rec.encryptAtRest()).To(Succeed())
+		for _, f := range own {
+			Expect(keyId(f)).To(Equal(crypt.KeyId([]byte(keyA))), f)
+		}
+		Expect(keyId(others)).To(BeEmpty())
+		Expect(keyId(dump)).To(BeEmpty())
+	})
+
+	It("leaves the files of a Config with another key alone", func() {
+		rec := newReconciler("cfg", encrypted("a"), keySecret("a", keyA), keySecret("b", keyB))
+		other := newReconciler("other", encrypted("b"), keySecret("b", keyB))
+		file := writeFile(other, runsDir, "20261019T000000Z/findings.json")
+		Expect(other.encryptAtRest()).To(Succeed())
+		Expect(rec.encryptAtRest()).To(Succeed())
+		Expect(keyId(file)).To(Equal(crypt.KeyId([]byte(keyB))))
+		s := crypt.Summary{}
+		status(rec, ".encryption", &s)
+		Expect(s).To(Equal(crypt.Summary{KeyId: crypt.KeyId([]byte(keyA))}))
+	})
+
+	It("rotates the files of the Config still under a previous key", func() {
+		old := newReconciler("cfg", encrypted("b"), keySecret("b", keyB))
+		file := writeFile(old, runsDir, "20261019T000000Z/findings.json")
+		Expect(old.encryptAtRest()).To(Succeed())
+		rec := newReconciler("cfg", "encryption: {enabled: true, keySecret: {name: a, key: key}, previousKeySecrets: [{name: b, key: key}]}",
+			keySecret("a", keyA), keySecret("b", keyB))
+		Expect(rec.encryptAtRest()).To(Succeed())
+		Expect(keyId(file)).To(Equal(crypt.KeyId([]byte(keyA))))
+		b, err := crypt.ReadFile(file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("clear"))
+	})
+
+	It("encrypts what a failed build wrote", func() {
+		writeDump(map[string]string{"configmaps": "[]\n"})
+		rec := newReconciler("cfg", encrypted("a")+"\ndiff: {previous: 20200101T000000Z}", keySecret("a", keyA))
+		Expect(rec.build()).To(MatchError(ContainSubstring("spec.diff.previous")))
+		runs, err := filepath.Glob(filepath.Join(rec.configPath(runsDir), "*", "results.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runs).To(HaveLen(1))
+		Expect(keyId(runs[0])).To(Equal(crypt.KeyId([]byte(keyA))))
+	})
+
+	It("encrypts the dump it redacts", func() {
+		src := filepath.Join(GinkgoT().TempDir(), "extract")
+		Expect(os.MkdirAll(src, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(src, "configmaps.yaml"), []byte("apiVersion: v1\nkind: ConfigMapList\nmetadata: {apiName: configmaps}\nitems: []\n"), 0644)).To(Succeed())
+		rec := newReconciler("cfg", encrypted("a"), keySecret("a", keyA))
+		Expect(rec.redactDump(src)).To(Succeed())
+		Expect(keyId(filepath.Join(reportPath, dumpDir, "configmaps.yaml"))).To(Equal(crypt.KeyId([]byte(keyA))))
+		Expect(keyId(filepath.Join(reportPath, redactionFile))).To(Equal(crypt.KeyId([]byte(keyA))))
+	})
+
+	It("loads the keys of the Config asked for only", func() {
+		rec := newReconciler("cfg", encrypted("a"), keySecret("a", keyA))
+		file := writeFile(rec, runsDir, "20261019T000000Z/findings.json")
+		Expect(rec.encryptAtRest()).To(Succeed())
+		crypt.Register("hcr/cfg", crypt.Keyring{})
+		_, err := crypt.ReadFile(file)
+		Expect(err).To(HaveOccurred(), "the keys are gone as after a restart")
+		Expect(LoadKeys(rec.ctx, rec.c, "hcr", "other")).NotTo(Succeed())
+		_, err = crypt.ReadFile(file)
+		Expect(err).To(HaveOccurred())
+		Expect(LoadKeys(rec.ctx, rec.c, "hcr", "cfg")).To(Succeed())
+		b, err := crypt.ReadFile(file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("clear"))
+	})
+})
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 
@@ -11,6 +13,7 @@ import (
 	fsutil "github.com/coreybutler/go-fsutil"
 	"github.com/mauricioscastro/kcdump/pkg/yjq"
 
+	"adoption.latam/hcr/internal/pkg/crypt"
 	"adoption.latam/hcr/internal/pkg/util/log"
 	"go.uber.org/zap"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -20,23 +23,40 @@ import (
 )
 
 const (
-	reportPath = "/data/kcdump"
+	dumpDir = "dump"
+	runsDir = "runs"
+	dataDir = "data"
+	siteDir = "site"
+
+	outputMkDocs = "mkdocs"
+	outputHtml   = "html"
+	outputJunit  = "junit"
+	outputCsv    = "csv"
+	outputXlsx   = "xlsx"
 )
 
 var (
 	logger       = log.Logger().Named("hcr.reconciler")
 	progressLock *sync.Mutex
+	// reportPath is the report volume, a variable so tests can point it elsewhere.
+	reportPath = "/data/kcdump"
+	// runIdRe are the run ids, the UTC time a build started, as the download server accepts them.
+	runIdRe = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z$`)
 )
 
 type reconciler struct {
+	c   client.Client
 	srw client.SubResourceWriter
 	ctx context.Context
 	cfg *hcrv1.Config
+	// keys encrypt what the build writes, see useEncryption.
+	keys crypt.Keyring
 }
 
 type Reconciler interface {
 	Run() (ctrl.Result, error)
 	extract() error
+	build() error
 	// setLogLevel() error
 	statusAddPhase(phase string) error
 	statusAddDiskUsage() error
@@ -44,9 +64,9 @@ type Reconciler interface {
 	updateStatus(jqExpr string) error
 }
 
-func NewReconciler(srw client.SubResourceWriter, ctx context.Context, cfg *hcrv1.Config) Reconciler {
+func NewReconciler(c client.Client, ctx context.Context, cfg *hcrv1.Config) Reconciler {
 	progressLock = &sync.Mutex{}
-	return &reconciler{srw, ctx, cfg}
+	return &reconciler{c: c, srw: c.Status(), ctx: ctx, cfg: cfg}
 }
 
 func (rec *reconciler) Run() (ctrl.Result, error) {
@@ -55,6 +75,14 @@ func (rec *reconciler) Run() (ctrl.Result, error) {
 		rec.cfg.Status = json.RawMessage("{}")
 		firstRun = true
 	}
+	if err := rec.useEncryption(); err != nil {
+		logger.Error("encryption keys", zap.Error(err))
+		return ctrl.Result{}, err
+	}
+	if err := rec.applyApproved(); err != nil {
+		logger.Error("applying approved remediation", zap.Error(err))
+		return ctrl.Result{}, err
+	}
 	nextTimeDuration := rec.getNextBuildTime()
 	if firstRun || (!firstRun && nextTimeDuration != "never") {
 		logger.Debug("will rebuild every " + nextTimeDuration)
@@ -65,11 +93,11 @@ func (rec *reconciler) Run() (ctrl.Result, error) {
 		if err := rec.statusAddPhase("extracting"); err != nil {
 			return ctrl.Result{}, err
 		}
-		// if err := rec.extract(); err != nil {
-		// 	logger.Error("extracting", zap.Error(err))
-		// 	return ctrl.Result{}, err
-		// }
-		// logger.Info("finished extracting")
+		if err := rec.extract(); err != nil {
+			logger.Error("extracting", zap.Error(err))
+			return ctrl.Result{}, err
+		}
+		logger.Info("finished extracting")
 		if err := rec.statusAddDiskUsage(); err != nil {
 			return ctrl.Result{}, err
 		}
@@ -77,6 +105,10 @@ func (rec *reconciler) Run() (ctrl.Result, error) {
 			logger.Error("building", zap.Error(err))
 			return ctrl.Result{}, err
 		}
+		if err := rec.build(); err != nil {
+			logger.Error("building", zap.Error(err))
+			return ctrl.Result{}, err
+		}
 		if err := rec.statusAddPhase("finished"); err != nil {
 			logger.Error("finished", zap.Error(err))
 			return ctrl.Result{}, err
@@ -100,8 +132,10 @@ func (rec *reconciler) Run() (ctrl.Result, error) {
 	return ctrl.Result{RequeueAfter: duration}, nil
 }
 
+// extract dumps the cluster out of reportPath and redacts it into reportPath/dump. A dump put
+// straight into reportPath/dump is not redacted by the operator, run it through hcrctl redact.
 func (rec *reconciler) extract() error {
-	// reportHome := reportPath
+	// reportHome := extractPath()
 	// nslist := []string{}  //[]string{"open.*"}
 	// gvklist := []string{} //[]string{".*,CustomResourceDefinition", ".*,APIRequestCount"}
 	// nologs := true
@@ -113,12 +147,21 @@ func (rec *reconciler) extract() error {
 	// prune := false
 	// routines := 10
 	// chunkSize := 25
-	return nil
-	// return kc.NewKc().Dump(reportHome, nslist, gvklist, nologs, gz, tgz, prune, splitns, splitgv, format, routines, chunkSize, func() {
+	// err := kc.NewKc().Dump(reportHome, nslist, gvklist, nologs, gz, tgz, prune, splitns, splitgv, format, routines, chunkSize, func() {
 	// 	progressLock.Lock()
 	// 	rec.statusAddDiskUsage()
 	// 	progressLock.Unlock()
 	// })
+	// if err != nil {
+	// 	return err
+	// }
+	return rec.redactDump(extractPath())
+}
+
+// configPath is reportPath/dir/<namespace>/<config> where the files of the Config are kept apart
+// from the ones of the other Configs sharing reportPath.
+func (rec *reconciler) configPath(dir string) string {
+	return filepath.Join(reportPath, dir, rec.cfg.Namespace, rec.cfg.Name)
 }
 
 func (rec *reconciler) getNextBuildTime() string {
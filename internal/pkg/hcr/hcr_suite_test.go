@@ -0,0 +1,157 @@
+package hcr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	authzv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/yaml"
+
+	hcrv1 "adoption.latam/hcr/api/v1"
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+func TestHcr(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Hcr Suite")
+}
+
+var _ = BeforeEach(func() {
+	reportPath = GinkgoT().TempDir()
+	DeferCleanup(func() {
+		crypt.Register("hcr/cfg", crypt.Keyring{})
+		crypt.Register("hcr/other", crypt.Keyring{})
+	})
+})
+
+// newReconciler reconciles the Config hcr/name with spec, given as yaml, against a fake
+// client holding objs. The fake client server side applies by merging the manifest into
+// the object, which is enough for the autofixes, and only lets the group admins do anything.
+func newReconciler(name string, spec string, objs ...client.Object) *reconciler {
+	s := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+	Expect(hcrv1.AddToScheme(s)).To(Succeed())
+	j := json.RawMessage("{}")
+	if spec != "" {
+		var err error
+		j, err = yaml.YAMLToJSON([]byte(spec))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	cfg := &hcrv1.Config{ObjectMeta: metav1.ObjectMeta{Namespace: "hcr", Name: name}, Spec: j, Status: json.RawMessage("{}")}
+	c := fake.NewClientBuilder().WithScheme(s).WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(s)).
+		WithObjects(append(objs, cfg)...).WithStatusSubresource(cfg).
+		WithInterceptorFuncs(interceptor.Funcs{Patch: applyPatch, Create: reviewAccess}).Build()
+	rec := NewReconciler(c, context.Background(), cfg).(*reconciler)
+	Expect(rec.useEncryption()).To(Succeed())
+	return rec
+}
+
+// reviewAccess stands in for the authorizer of the SubjectAccessReviews: the group admins may
+// do anything, nobody else anything.
+func reviewAccess(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+	sar, ok := obj.(*authzv1.SubjectAccessReview)
+	if !ok {
+		return c.Create(ctx, obj, opts...)
+	}
+	sar.Status.Allowed = slices.Contains(sar.Spec.Groups, "admins")
+	return nil
+}
+
+// applyPatch stands in for the server side apply the fake client lacks.
+func applyPatch(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+	o := &client.PatchOptions{}
+	o.ApplyOptions(opts)
+	u := obj.(*unstructured.Unstructured)
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(u.GroupVersionKind())
+	err := c.Get(ctx, client.ObjectKeyFromObject(u), live)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil && u.GetResourceVersion() != "" && u.GetResourceVersion() != live.GetResourceVersion() {
+		return apierrors.NewConflict(schema.GroupResource{Resource: u.GetKind()}, u.GetName(), errors.New("the object has been modified"))
+	}
+	merged := &unstructured.Unstructured{Object: merge(live.Object, u.Object)}
+	if len(o.DryRun) == 0 {
+		if err == nil {
+			err = c.Update(ctx, merged)
+		} else {
+			merged.SetResourceVersion("")
+			err = c.Create(ctx, merged)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	u.Object = merged.Object
+	return nil
+}
+
+func merge(dst map[string]any, src map[string]any) map[string]any {
+	out := runtime.DeepCopyJSON(dst)
+	if out == nil {
+		out = map[string]any{}
+	}
+	for k, v := range src {
+		if m, ok := v.(map[string]any); ok {
+			if d, ok := out[k].(map[string]any); ok {
+				out[k] = merge(d, m)
+				continue
+			}
+		}
+		out[k] = runtime.DeepCopyJSONValue(v)
+	}
+	return out
+}
+
+// writeDump writes the resource lists, api name to the yaml list of its items, as the
+// dump of reportPath.
+func writeDump(lists map[string]string) {
+	dir := filepath.Join(reportPath, dumpDir)
+	Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+	for name, items := range lists {
+		kind := map[string]string{"configmaps": "ConfigMapList", "namespaces": "NamespaceList", "secrets": "SecretList"}[name]
+		doc := "apiVersion: v1\nkind: " + kind + "\nmetadata:\n  apiName: " + name + "\nitems:\n" + items
+		Expect(os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(doc), 0644)).To(Succeed())
+	}
+}
+
+// status decodes the result of the jq query over the status of rec.
+func status(rec *reconciler, jqExpr string, v any) {
+	ExpectWithOffset(1, jqAs(jqExpr, string(rec.cfg.Status), v)).To(Succeed())
+}
+
+// newBuild starts the run id of rec over the dump of reportPath like build does.
+func newBuild(rec *reconciler, id string) *build {
+	b := &build{id: id, date: "2026-10-19T00:00:00Z", path: filepath.Join(rec.configPath(runsDir), id)}
+	b.shared = b.path
+	Expect(os.MkdirAll(b.path, 0755)).To(Succeed())
+	var err error
+	b.dump, err = dump.Load(filepath.Join(reportPath, dumpDir))
+	Expect(err).NotTo(HaveOccurred())
+	return b
+}
@@ -0,0 +1,33 @@
+package hcr
+
+import (
+	"path/filepath"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/history"
+)
+
+// historySpec is spec.history. limit is the number of runs kept in the series.
+type historySpec struct {
+	Limit int `json:"limit"`
+}
+
+// recordHistory adds this run to the time series kept next to the runs of the Config and hands it
+// to the report.
+func (rec *reconciler) recordHistory(b *build) error {
+	spec := historySpec{Limit: 120}
+	if err := rec.specAs(".history", &spec); err != nil {
+		return err
+	}
+	p := history.Point{Run: b.id, Date: b.date, Findings: check.CountBySeverity(b.allFindings())}
+	if b.score != nil {
+		p.Score = &b.score.Overall.Score
+	}
+	history.Measure(b.dump, &p)
+	points, err := history.Append(filepath.Join(rec.configPath(runsDir), history.File), p, spec.Limit)
+	if err != nil {
+		return err
+	}
+	b.history = points
+	return nil
+}
@@ -0,0 +1,71 @@
+package hcr
+
+import (
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/compliance"
+	"adoption.latam/hcr/internal/pkg/export"
+	"adoption.latam/hcr/internal/pkg/i18n"
+	"adoption.latam/hcr/internal/pkg/render"
+	"adoption.latam/hcr/internal/pkg/waiver"
+)
+
+// reportLanguage is spec.language validated against the languages with a catalog.
+func (rec *reconciler) reportLanguage() (*i18n.Localizer, error) {
+	lang := i18n.Default
+	if err := rec.specAs(".language", &lang); err != nil {
+		return nil, err
+	}
+	return i18n.New(lang)
+}
+
+// localize fills the results and findings of ctx in the language of the report. Only the
+// human readable outputs are translated. findings.json, sarif and junit stay in english.
+func (b *build) localize(ctx *render.Context) {
+	lang := b.locale.Lang
+	checks := map[string]check.Check{}
+	for _, r := range b.results {
+		checks[r.Check.Id] = r.Check
+	}
+	findings := func(ff []check.Finding) []check.Finding {
+		if ff == nil || lang == i18n.Default {
+			return ff
+		}
+		localized := make([]check.Finding, len(ff))
+		for i, f := range ff {
+			localized[i] = checks[f.CheckId].LocalizeFinding(f, lang)
+		}
+		return localized
+	}
+	ctx.Language = lang
+	ctx.Results = check.Localize(b.results, lang)
+	ctx.Findings = findings(b.findings)
+	if b.diff != nil {
+		d := *b.diff
+		d.New, d.Resolved, d.Persisting = findings(d.New), findings(d.Resolved), findings(d.Persisting)
+		ctx.Diff = &d
+	}
+	if b.baseline != nil {
+		bl := export.Baseline{Run: b.baseline.Run, Findings: findings(b.baseline.Findings)}
+		ctx.Baseline = &bl
+	}
+	if b.waived != nil {
+		ctx.Waived = make([]waiver.Waived, len(b.waived))
+		for i, w := range b.waived {
+			ctx.Waived[i] = waiver.Waived{Finding: checks[w.CheckId].LocalizeFinding(w.Finding, lang), Waiver: w.Waiver}
+		}
+	}
+	ctx.Compliance = make([]compliance.Framework, len(b.compliance))
+	for i, fw := range b.compliance {
+		ctx.Compliance[i] = fw
+		ctx.Compliance[i].Controls = make([]compliance.Control, len(fw.Controls))
+		for j, c := range fw.Controls {
+			ctx.Compliance[i].Controls[j] = c
+			ctx.Compliance[i].Controls[j].Evidence = make([]compliance.Evidence, len(c.Evidence))
+			for k, e := range c.Evidence {
+				e.Title = checks[e.CheckId].Localized(lang).Title
+				e.Findings = findings(e.Findings)
+				ctx.Compliance[i].Controls[j].Evidence[k] = e
+			}
+		}
+	}
+}
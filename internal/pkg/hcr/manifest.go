@@ -0,0 +1,64 @@
+package hcr
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/export"
+	"adoption.latam/hcr/internal/pkg/manifest"
+)
+
+// signingSpec is spec.signing. The keySecret value is an ed25519 private key.
+type signingSpec struct {
+	KeySecret secretKeyRef `json:"keySecret"`
+}
+
+// writeManifest writes the manifest of the run once its artifacts are final and signs it when
+// spec.signing has a key. It lists the shared copy of the run and reads the run metadata back from
+// its findings.json so it is anonymized like the artifacts are. The public key goes to status for whoever has to verify the report.
+func (rec *reconciler) writeManifest(b *build) error {
+	spec := signingSpec{}
+	if err := rec.specAs(".signing", &spec); err != nil {
+		return err
+	}
+	doc, err := export.ReadFindings(b.shared)
+	if err != nil {
+		return err
+	}
+	m := manifest.Manifest{Run: doc.Run, Findings: check.CountBySeverity(b.allFindings())}
+	if b.score != nil {
+		m.Score = &b.score.Overall.Score
+	}
+	if spec.KeySecret.Name == "" {
+		if _, _, err = manifest.Write(b.shared, m); err != nil {
+			return err
+		}
+		return rec.updateStatus("del(.signature)")
+	}
+	k, err := rec.secretValue(spec.KeySecret)
+	if err != nil {
+		return fmt.Errorf("spec.signing.keySecret: %w", err)
+	}
+	key, err := manifest.ParsePrivateKey(k)
+	if err != nil {
+		return fmt.Errorf("spec.signing.keySecret: %w", err)
+	}
+	if m, err = manifest.Sign(b.shared, m, key); err != nil {
+		return err
+	}
+	pub, err := manifest.MarshalPublicKey(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		return err
+	}
+	logger.Info("run signed", zap.String("keyId", m.KeyId), zap.Int("artifacts", len(m.Artifacts)))
+	return rec.statusSet(".signature", map[string]any{
+		"manifest":  filepath.Join(b.shared, manifest.File),
+		"keyId":     m.KeyId,
+		"artifacts": len(m.Artifacts),
+		"publicKey": string(pub),
+	})
+}
@@ -0,0 +1,92 @@
+package hcr
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/export"
+)
+
+func (rec *reconciler) exportRun(b *build) export.Run {
+	return export.Run{
+		Id:     b.id,
+		Date:   b.ctx.Run.Date,
+		Config: &export.ConfigRef{Name: rec.cfg.Name, Namespace: rec.cfg.Namespace},
+	}
+}
+
+// writeFindings writes the machine readable outputs every run emits.
+func (rec *reconciler) writeFindings(b *build) error {
+	run := rec.exportRun(b)
+	doc := export.NewFindings(run, b.results, b.findings)
+	doc.Waived = b.waived
+	doc.Baseline = b.baseline
+	if err := export.WriteFindings(b.path, doc); err != nil {
+		return err
+	}
+	if err := export.WriteSarif(b.path, run, b.results, b.findings); err != nil {
+		return err
+	}
+	failOn := check.SeverityCritical
+	if err := rec.specAs(".failOn", &failOn); err != nil {
+		return err
+	}
+	if !slices.Contains(check.Severities, failOn) {
+		return fmt.Errorf("spec.failOn: unknown severity '%s'", failOn)
+	}
+	if slices.Contains(b.outputs, outputJunit) {
+		if err := export.WriteJunit(b.path, run, b.results, failOn); err != nil {
+			return err
+		}
+	}
+	if err := writeSheets(b); err != nil {
+		return err
+	}
+	return rec.statusThreshold(b, failOn)
+}
+
+// writeSheets writes the findings per category plus the dump inventory as csv files and/or a workbook.
+func writeSheets(b *build) error {
+	if !slices.Contains(b.outputs, outputCsv) && !slices.Contains(b.outputs, outputXlsx) {
+		return nil
+	}
+	sheets := append(export.FindingSheets(b.ctx.Results, b.ctx.Findings), export.NewInventorySheet(b.dump))
+	for i := range sheets {
+		// headers are shared with export.FindingColumns and InventoryColumns
+		header := make([]string, len(sheets[i].Header))
+		for j, h := range sheets[i].Header {
+			header[j] = b.locale.T(h)
+		}
+		sheets[i].Header = header
+	}
+	if slices.Contains(b.outputs, outputCsv) {
+		if err := export.WriteCsv(filepath.Join(b.path, outputCsv), sheets); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(b.outputs, outputXlsx) {
+		return export.WriteXlsx(filepath.Join(b.path, "findings.xlsx"), sheets)
+	}
+	return nil
+}
+
+// statusThreshold reflects in a condition whether the run has findings at or above spec.failOn.
+func (rec *reconciler) statusThreshold(b *build, failOn string) error {
+	failing := export.Failing(b.findings, failOn)
+	if len(failing) == 0 {
+		return rec.statusSetCondition(conditionFindingsWithinThreshold, metav1.ConditionTrue, "NoFindingsAtThreshold",
+			fmt.Sprintf("no findings with severity %s or above", failOn))
+	}
+	checks := []string{}
+	for _, f := range failing {
+		if !slices.Contains(checks, f.CheckId) {
+			checks = append(checks, f.CheckId)
+		}
+	}
+	return rec.statusSetCondition(conditionFindingsWithinThreshold, metav1.ConditionFalse, "FindingsAtThreshold",
+		fmt.Sprintf("%d findings with severity %s or above from checks %v", len(failing), failOn, checks))
+}
@@ -0,0 +1,91 @@
+package hcr
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/redact"
+)
+
+// redactionFile holds the summary of the last redaction next to the dump it describes.
+const redactionFile = "redaction.json"
+
+// extractPath is where kcdump writes before redaction. It must stay out of reportPath
+// so no raw object ever lands on the report volume.
+func extractPath() string {
+	return filepath.Join(os.TempDir(), "hcr-extract")
+}
+
+// redactDump redacts the raw dump in src with spec.redaction into reportPath/dump replacing the
+// previous one, encrypts it when encryption is enabled and removes src. Without src the current
+// dump is left alone.
+func (rec *reconciler) redactDump(src string) error {
+	if _, err := os.Stat(src); errors.Is(err, fs.ErrNotExist) {
+		logger.Debug("nothing extracted to redact", zap.String("path", src))
+		return nil
+	}
+	r, err := rec.redactor()
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(reportPath, dumpDir)
+	if err = os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err = r.Dir(src, dst); err != nil {
+		return err
+	}
+	if rec.keys.Enabled() {
+		if _, err = rec.keys.Encrypt(dst); err != nil {
+			return err
+		}
+	}
+	if err = os.RemoveAll(src); err != nil {
+		return err
+	}
+	j, err := json.MarshalIndent(r.Summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = rec.keys.WriteFile(filepath.Join(reportPath, redactionFile), j, 0644); err != nil {
+		return err
+	}
+	return rec.statusSet(".redaction", map[string]int{
+		"secrets":       r.Summary.Secrets,
+		"secretKeys":    r.Summary.SecretKeys,
+		"env":           r.Summary.Env,
+		"configMapKeys": r.Summary.ConfigMapKeys,
+		"sed":           r.Summary.Sed,
+	})
+}
+
+// redactor redacts with spec.redaction.
+func (rec *reconciler) redactor() (*redact.Redactor, error) {
+	spec := redact.DefaultSpec()
+	if err := rec.specAs(".redaction", &spec); err != nil {
+		return nil, err
+	}
+	return redact.New(spec)
+}
+
+// loadRedaction reads the summary of the redaction of the current dump into the run.
+func loadRedaction(b *build) error {
+	j, err := crypt.ReadFile(filepath.Join(reportPath, redactionFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	summary := redact.Summary{}
+	if err = json.Unmarshal(j, &summary); err != nil {
+		return err
+	}
+	b.redaction = &summary
+	return os.WriteFile(filepath.Join(b.path, redactionFile), j, 0644)
+}
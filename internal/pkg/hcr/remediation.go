@@ -0,0 +1,431 @@
+package hcr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/diff"
+	"adoption.latam/hcr/internal/pkg/dump"
+	"adoption.latam/hcr/internal/pkg/export"
+	"adoption.latam/hcr/internal/pkg/redact"
+)
+
+const (
+	// AnnotationApproveRemediation on a Config holds the run of the pending plan and the comma
+	// separated ids of its items to apply, or all for the whole plan: <run>:<id>,<id>. An approval
+	// for another run than the pending plan is ignored so it never carries over to a newer plan.
+	AnnotationApproveRemediation = "hcr.adoption.latam/approve-remediation"
+	// AnnotationApprovedBy on a Config is the Approver the Config webhook records whenever the
+	// approval annotation changes. Approvals without it are not applied.
+	AnnotationApprovedBy = "hcr.adoption.latam/approved-by"
+
+	remediationFieldOwner = "hcr"
+	remediationPlanFile   = "remediation-plan.json"
+	remediationAuditFile  = "remediation-audit.jsonl"
+)
+
+// remediationSpec is spec.remediation. Only the checks listed, which must declare an autofix,
+// are planned. Nothing is ever applied without the approval annotation. ForceOwnership takes
+// over the fields other managers own instead of failing on the conflict.
+type remediationSpec struct {
+	Enabled        bool     `json:"enabled"`
+	Checks         []string `json:"checks"`
+	ForceOwnership bool     `json:"forceOwnership"`
+}
+
+// planItem is the server side apply of an autofix manifest for one finding.
+type planItem struct {
+	Id          string `json:"id"`
+	Run         string `json:"run"`
+	CheckId     string `json:"checkId"`
+	Fingerprint string `json:"fingerprint"`
+	ApiVersion  string `json:"apiVersion,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	// ResourceVersion is the version of the live object the manifest was rendered from, the
+	// apply fails when the object changed since.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// Manifest and Changes are redacted like the dump and only there for review. The manifest
+	// applied is rendered again from the live object and must hash to Id, see planId.
+	Manifest string        `json:"manifest,omitempty"`
+	Changes  []diff.Change `json:"changes,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// pendingItem is the summary of a plan item kept in status.
+type pendingItem struct {
+	Id          string `json:"id"`
+	CheckId     string `json:"checkId"`
+	Fingerprint string `json:"fingerprint"`
+	Object      string `json:"object"`
+	Changes     int    `json:"changes"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Approver is the user who set an approval, as the apiserver authenticated them.
+type Approver struct {
+	Approval string           `json:"approval"`
+	User     authnv1.UserInfo `json:"user"`
+}
+
+type auditEntry struct {
+	Time       string `json:"time"`
+	Action     string `json:"action"`
+	Approval   string `json:"approval"`
+	ApprovedBy string `json:"approvedBy"`
+	planItem
+}
+
+func (rec *reconciler) remediationSpec() (remediationSpec, error) {
+	spec := remediationSpec{}
+	err := rec.specAs(".remediation", &spec)
+	return spec, err
+}
+
+// planRemediation renders the autofix of every finding of an opted in check from the live
+// object, not the possibly redacted dump, and evaluates it with a server side apply dry-run.
+// The plan, with the manifests and changes redacted with spec.redaction, is written to the run
+// and summarized in status.
+func (rec *reconciler) planRemediation(b *build) error {
+	spec, err := rec.remediationSpec()
+	if err != nil {
+		return err
+	}
+	if !spec.Enabled {
+		return rec.updateStatus("del(.remediation)")
+	}
+	r, err := rec.redactor()
+	if err != nil {
+		return err
+	}
+	checks := map[string]check.Check{}
+	for _, r := range b.results {
+		if r.Check.Autofix != nil && slices.Contains(spec.Checks, r.Check.Id) {
+			checks[r.Check.Id] = r.Check
+		}
+	}
+	plan := []planItem{}
+	pending := []pendingItem{}
+	for _, f := range b.allFindings() {
+		c, ok := checks[f.CheckId]
+		if !ok {
+			continue
+		}
+		item := planItem{Run: b.id, CheckId: f.CheckId, Fingerprint: f.Fingerprint,
+			ApiVersion: f.ApiVersion, Kind: f.Kind, Namespace: f.Namespace, Name: f.Name}
+		var manifest string
+		if manifest, item.ResourceVersion, err = rec.renderLive(c, f); err == nil {
+			item.Changes, err = rec.dryRun(r, manifest, item.ResourceVersion, spec.ForceOwnership)
+		}
+		if err == nil {
+			item.Manifest, err = redactManifest(r, manifest)
+		}
+		if err != nil {
+			item.Error = err.Error()
+		}
+		item.Id = planId(b.id, f.Fingerprint, manifest)
+		plan = append(plan, item)
+		pending = append(pending, item.pending())
+	}
+	logger.Info("remediation planned", zap.Int("items", len(plan)))
+	j, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(b.path, remediationPlanFile), j, 0644); err != nil {
+		return err
+	}
+	return rec.statusSet(".remediation", map[string]any{"run": b.id, "pending": pending})
+}
+
+// planId identifies the autofix manifest rendered for the finding of fingerprint in run.
+func planId(run string, fingerprint string, manifest string) string {
+	sum := sha256.Sum256([]byte(run + fingerprint + manifest))
+	return hex.EncodeToString(sum[:6])
+}
+
+// redactManifest redacts manifest like the dump.
+func redactManifest(r *redact.Redactor, manifest string) (string, error) {
+	obj := map[string]any{}
+	if err := yaml.Unmarshal([]byte(manifest), &obj); err != nil {
+		return "", fmt.Errorf("autofix manifest: %w", err)
+	}
+	redacted, err := r.Object(obj)
+	if err != nil {
+		return "", err
+	}
+	b, err := yaml.Marshal(redacted)
+	return string(b), err
+}
+
+func (p planItem) pending() pendingItem {
+	return pendingItem{
+		Id:          p.Id,
+		CheckId:     p.CheckId,
+		Fingerprint: p.Fingerprint,
+		Object:      strings.TrimPrefix(p.Namespace+"/"+p.Kind+"/"+p.Name, "/"),
+		Changes:     len(p.Changes),
+		Error:       p.Error,
+	}
+}
+
+// renderLive renders the autofix of f from the live object and returns its resourceVersion.
+func (rec *reconciler) renderLive(c check.Check, f check.Finding) (string, string, error) {
+	live := &unstructured.Unstructured{}
+	live.SetAPIVersion(f.ApiVersion)
+	live.SetKind(f.Kind)
+	var obj any
+	err := rec.c.Get(rec.ctx, client.ObjectKey{Namespace: f.Namespace, Name: f.Name}, live)
+	if err == nil {
+		obj = dump.Normalize(live.Object)
+	} else if client.IgnoreNotFound(err) != nil {
+		return "", "", err
+	}
+	manifest, err := c.Autofix.RenderObject(c, f, obj)
+	return manifest, live.GetResourceVersion(), err
+}
+
+// applyApproved applies the pending plan items named by the approval annotation when it is for
+// the run of the pending plan and the user who approved may apply each of them themselves. Every
+// attempt goes to the audit trail and leaves the pending plan. The manifests are rendered again
+// from the live objects since the plan only keeps redacted ones.
+func (rec *reconciler) applyApproved() error {
+	approval := rec.cfg.Annotations[AnnotationApproveRemediation]
+	if approval == "" {
+		return nil
+	}
+	approvedRun, ids, ok := strings.Cut(approval, ":")
+	if !ok {
+		logger.Warn("remediation approval must be <run>:<ids>", zap.String("approval", approval))
+		return nil
+	}
+	spec, err := rec.remediationSpec()
+	if err != nil || !spec.Enabled {
+		return err
+	}
+	var run string
+	pending := []pendingItem{}
+	if err = jqAs(".remediation.run // null", string(rec.cfg.Status), &run); err != nil {
+		return err
+	}
+	if err = jqAs(".remediation.pending // null", string(rec.cfg.Status), &pending); err != nil {
+		return err
+	}
+	if run == "" || len(pending) == 0 {
+		return nil
+	}
+	if approvedRun != run {
+		logger.Info("remediation approval is not for the pending plan", zap.String("approved", approvedRun), zap.String("pending", run))
+		return nil
+	}
+	approver, ok := rec.approver(approval)
+	if !ok {
+		logger.Warn("remediation approval without its approver, is the Config webhook enabled?", zap.String("approval", approval))
+		return nil
+	}
+	runPath := filepath.Join(rec.configPath(runsDir), run)
+	b, err := crypt.ReadFile(filepath.Join(runPath, remediationPlanFile))
+	if err != nil {
+		return err
+	}
+	plan := []planItem{}
+	if err = json.Unmarshal(b, &plan); err != nil {
+		return err
+	}
+	checks, err := rec.enabledChecks()
+	if err != nil {
+		return err
+	}
+	doc, err := export.ReadFindings(runPath)
+	if err != nil {
+		return err
+	}
+	findings := doc.All()
+	approved := strings.Split(ids, ",")
+	for i := range approved {
+		approved[i] = strings.TrimSpace(approved[i])
+	}
+	remaining := []pendingItem{}
+	for _, p := range pending {
+		if !slices.Contains(approved, "all") && !slices.Contains(approved, p.Id) {
+			remaining = append(remaining, p)
+			continue
+		}
+		i := slices.IndexFunc(plan, func(item planItem) bool { return item.Id == p.Id })
+		if i < 0 || plan[i].Error != "" {
+			remaining = append(remaining, p)
+			continue
+		}
+		entry := auditEntry{Time: time.Now().Format(time.RFC3339), Action: "applied", Approval: approval,
+			ApprovedBy: approver.Username, planItem: plan[i]}
+		if err = rec.authorizeItem(approver, plan[i]); err != nil {
+			entry.Action = "denied"
+			entry.Error = err.Error()
+			logger.Warn("remediation", zap.String("id", p.Id), zap.String("object", p.Object), zap.Error(err))
+		} else if err = rec.applyItem(plan[i], checks, findings, spec.ForceOwnership); err != nil {
+			entry.Action = "failed"
+			entry.Error = err.Error()
+			logger.Error("remediation", zap.String("id", p.Id), zap.String("object", p.Object), zap.Error(err))
+		} else {
+			logger.Info("remediation applied", zap.String("id", p.Id), zap.String("object", p.Object))
+		}
+		if err = rec.audit(entry); err != nil {
+			return err
+		}
+	}
+	return rec.statusSet(".remediation.pending", remaining)
+}
+
+// approver is the user the webhook recorded for approval.
+func (rec *reconciler) approver(approval string) (authnv1.UserInfo, bool) {
+	a := Approver{}
+	if err := json.Unmarshal([]byte(rec.cfg.Annotations[AnnotationApprovedBy]), &a); err != nil {
+		return a.User, false
+	}
+	return a.User, a.Approval == approval && a.User.Username != ""
+}
+
+// authorizeItem reviews whether u may apply item, patch the object or create it when it was
+// not there at plan time, so an approval never applies what the approver could not.
+func (rec *reconciler) authorizeItem(u authnv1.UserInfo, item planItem) error {
+	gv, err := schema.ParseGroupVersion(item.ApiVersion)
+	if err != nil {
+		return err
+	}
+	m, err := rec.c.RESTMapper().RESTMapping(gv.WithKind(item.Kind).GroupKind(), gv.Version)
+	if err != nil {
+		return err
+	}
+	verb := "patch"
+	if item.ResourceVersion == "" {
+		verb = "create"
+	}
+	extra := make(map[string]authzv1.ExtraValue, len(u.Extra))
+	for k, v := range u.Extra {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+	sar := &authzv1.SubjectAccessReview{Spec: authzv1.SubjectAccessReviewSpec{
+		User:   u.Username,
+		UID:    u.UID,
+		Groups: u.Groups,
+		Extra:  extra,
+		ResourceAttributes: &authzv1.ResourceAttributes{
+			Namespace: item.Namespace,
+			Verb:      verb,
+			Group:     m.Resource.Group,
+			Resource:  m.Resource.Resource,
+			Name:      item.Name,
+		},
+	}}
+	if err = rec.c.Create(rec.ctx, sar); err != nil {
+		return err
+	}
+	if !sar.Status.Allowed {
+		return fmt.Errorf("%s may not %s %s %s", u.Username, verb, m.Resource.Resource, strings.TrimPrefix(item.Namespace+"/"+item.Name, "/"))
+	}
+	return nil
+}
+
+// applyItem renders the autofix of item again from the live object and applies it when it is
+// still the manifest planned.
+func (rec *reconciler) applyItem(item planItem, checks []check.Check, findings []check.Finding, force bool) error {
+	c := slices.IndexFunc(checks, func(c check.Check) bool { return c.Id == item.CheckId && c.Autofix != nil })
+	f := slices.IndexFunc(findings, func(f check.Finding) bool { return f.Fingerprint == item.Fingerprint })
+	if c < 0 || f < 0 {
+		return fmt.Errorf("check %s or its finding is no longer there", item.CheckId)
+	}
+	manifest, _, err := rec.renderLive(checks[c], findings[f])
+	if err != nil {
+		return err
+	}
+	if planId(item.Run, item.Fingerprint, manifest) != item.Id {
+		return fmt.Errorf("the object changed since the plan, approve the next one")
+	}
+	return rec.serverSideApply(manifest, item.ResourceVersion, force, false, nil)
+}
+
+// dryRun returns the changes a server side apply of manifest would make to the live object,
+// both sides redacted with r.
+func (rec *reconciler) dryRun(r *redact.Redactor, manifest string, resourceVersion string, force bool) ([]diff.Change, error) {
+	result := &unstructured.Unstructured{}
+	if err := rec.serverSideApply(manifest, resourceVersion, force, true, result); err != nil {
+		return nil, err
+	}
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(result.GroupVersionKind())
+	var before any
+	if err := rec.c.Get(rec.ctx, client.ObjectKeyFromObject(result), live); err == nil {
+		if before, err = r.Object(live.Object); err != nil {
+			return nil, err
+		}
+	} else if client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	after, err := r.Object(result.Object)
+	if err != nil {
+		return nil, err
+	}
+	return diff.Compare(before, after), nil
+}
+
+// serverSideApply applies manifest as the hcr field owner. A resourceVersion makes the apiserver
+// refuse the apply with a conflict when the object is at another version. The server answer
+// lands in result when given.
+func (rec *reconciler) serverSideApply(manifest string, resourceVersion string, force bool, dryRun bool, result *unstructured.Unstructured) error {
+	obj := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(manifest), &obj.Object); err != nil {
+		return fmt.Errorf("autofix manifest: %w", err)
+	}
+	if resourceVersion != "" {
+		obj.SetResourceVersion(resourceVersion)
+	}
+	opts := []client.PatchOption{client.FieldOwner(remediationFieldOwner)}
+	if force {
+		opts = append(opts, client.ForceOwnership)
+	}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	if err := rec.c.Patch(rec.ctx, obj, client.Apply, opts...); err != nil {
+		return err
+	}
+	if result != nil {
+		obj.DeepCopyInto(result)
+	}
+	return nil
+}
+
+// audit appends entry to the audit trail kept next to the runs of the Config. An encrypted trail
+// cannot be appended to so it is read and written back whole.
+func (rec *reconciler) audit(entry auditEntry) error {
+	j, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(rec.configPath(runsDir), remediationAuditFile)
+	trail, err := crypt.ReadFile(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return rec.keys.WriteFile(file, append(trail, append(j, '\n')...), 0644)
+}
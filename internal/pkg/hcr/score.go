@@ -0,0 +1,30 @@
+package hcr
+
+import (
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/score"
+)
+
+// scoreFindings grades the run with the weights in spec.score.
+func (rec *reconciler) scoreFindings(b *build) error {
+	spec := score.DefaultSpec()
+	if err := rec.specAs(".score", &spec); err != nil {
+		return err
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	card := score.Compute(spec, b.results)
+	b.score = &card
+	logger.Info("run scored", zap.Float64("score", card.Overall.Score), zap.String("grade", card.Overall.Grade))
+	categories := map[string]any{}
+	for _, c := range card.Categories {
+		categories[c.Name] = map[string]any{"score": c.Score.Score, "grade": c.Grade}
+	}
+	return rec.statusSet(".score", map[string]any{
+		"score":      card.Overall.Score,
+		"grade":      card.Overall.Grade,
+		"categories": categories,
+	})
+}
@@ -0,0 +1,56 @@
+package hcr
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// secretKeyRef points at a key of a Secret in the Config namespace.
+type secretKeyRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+func (rec *reconciler) secretValue(ref secretKeyRef) ([]byte, error) {
+	if ref.Name == "" || ref.Key == "" {
+		return nil, fmt.Errorf("secret name and key are mandatory")
+	}
+	data, err := rec.secretData(ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("secret %s has no key %s", ref.Name, ref.Key)
+	}
+	return v, nil
+}
+
+// secretData is the data of the Secret name in the Config namespace.
+func (rec *reconciler) secretData(name string) (map[string][]byte, error) {
+	s := corev1.Secret{}
+	if err := rec.c.Get(rec.ctx, types.NamespacedName{Namespace: rec.cfg.Namespace, Name: name}, &s); err != nil {
+		return nil, fmt.Errorf("secret %s: %w", name, err)
+	}
+	return s.Data, nil
+}
+
+// configMapKeyRef points at a key of a ConfigMap in the Config namespace.
+type configMapKeyRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+func (rec *reconciler) configMapValue(ref configMapKeyRef) (string, error) {
+	cm := corev1.ConfigMap{}
+	if err := rec.c.Get(rec.ctx, types.NamespacedName{Namespace: rec.cfg.Namespace, Name: ref.Name}, &cm); err != nil {
+		return "", fmt.Errorf("configmap %s: %w", ref.Name, err)
+	}
+	v, ok := cm.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("configmap %s has no key %s", ref.Name, ref.Key)
+	}
+	return v, nil
+}
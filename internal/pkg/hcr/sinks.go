@@ -0,0 +1,275 @@
+package hcr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/bundle"
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/oci"
+	"adoption.latam/hcr/internal/pkg/s3"
+)
+
+const defaultPrefix = "{{.Namespace}}/{{.Config}}"
+
+// s3Spec is spec.sinks.s3. credentialsSecret holds AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// optionally AWS_SESSION_TOKEN, the keys an ObjectBucketClaim secret has. prefix is a go template
+// over Namespace, Config and Run. The bundle goes to <prefix>/<run>.tar.gz and the artifacts under <prefix>/<run>/.
+type s3Spec struct {
+	Endpoint          string           `json:"endpoint"`
+	Region            string           `json:"region"`
+	Bucket            string           `json:"bucket"`
+	Prefix            string           `json:"prefix"`
+	VirtualHosted     bool             `json:"virtualHosted"`
+	CredentialsSecret string           `json:"credentialsSecret"`
+	CA                *configMapKeyRef `json:"ca"`
+	SSE               string           `json:"sse"`
+	KMSKeyId          string           `json:"kmsKeyId"`
+	PartSizeMiB       int64            `json:"partSizeMiB"`
+	// Artifacts uploads every file of the run besides the bundle.
+	Artifacts bool `json:"artifacts"`
+}
+
+// sinkPrefix renders the object name prefix of run.
+func (rec *reconciler) sinkPrefix(run string, tmpl string) (string, error) {
+	if tmpl == "" {
+		tmpl = defaultPrefix
+	}
+	t, err := template.New("prefix").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	err = t.Execute(&out, map[string]string{"Namespace": rec.cfg.Namespace, "Config": rec.cfg.Name, "Run": run})
+	return path.Clean(out.String()), err
+}
+
+// uploadS3 sends the bundle and, when asked, every artifact of the run to spec.sinks.s3.
+// A failed upload is recorded in status and does not fail the build. The runs whose bundle
+// failed are kept in status.sinks.s3.pending and sent again by the next builds, resuming the
+// parts already uploaded, as long as their bundle is on disk.
+func (rec *reconciler) uploadS3(b *build) error {
+	spec := s3Spec{}
+	if err := rec.specAs(".sinks.s3", &spec); err != nil {
+		return err
+	}
+	if spec.Endpoint == "" {
+		return rec.updateStatus("del(.sinks.s3)")
+	}
+	pending := []string{}
+	if err := jqAs(".sinks.s3.pending // null", string(rec.cfg.Status), &pending); err != nil {
+		return err
+	}
+	pending = append(slices.DeleteFunc(pending, func(run string) bool { return run == b.id }), b.id)
+	status, err := rec.s3Upload(b, spec, pending)
+	if err != nil {
+		logger.Error("s3 upload", zap.Error(err))
+		status["error"] = err.Error()
+	}
+	return rec.statusSet(".sinks.s3", status)
+}
+
+// s3Upload uploads the bundles of pending, the run last, and the artifacts of the run. The
+// runs left in pending are those failed or not tried.
+func (rec *reconciler) s3Upload(b *build, spec s3Spec, pending []string) (map[string]any, error) {
+	status := map[string]any{"run": b.id, "pending": pending}
+	cfg := s3.Config{
+		Endpoint:      spec.Endpoint,
+		Region:        spec.Region,
+		Bucket:        spec.Bucket,
+		VirtualHosted: spec.VirtualHosted,
+		SSE:           spec.SSE,
+		KMSKeyId:      spec.KMSKeyId,
+		PartSize:      spec.PartSizeMiB << 20,
+	}
+	creds, err := rec.secretData(spec.CredentialsSecret)
+	if err != nil {
+		return status, fmt.Errorf("spec.sinks.s3.credentialsSecret: %w", err)
+	}
+	cfg.Credentials = s3.Credentials{
+		AccessKeyId:     string(creds["AWS_ACCESS_KEY_ID"]),
+		SecretAccessKey: string(creds["AWS_SECRET_ACCESS_KEY"]),
+		SessionToken:    string(creds["AWS_SESSION_TOKEN"]),
+	}
+	if cfg.Credentials.AccessKeyId == "" || cfg.Credentials.SecretAccessKey == "" {
+		return status, fmt.Errorf("spec.sinks.s3.credentialsSecret: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are mandatory")
+	}
+	if spec.CA != nil {
+		ca, err := rec.configMapValue(*spec.CA)
+		if err != nil {
+			return status, fmt.Errorf("spec.sinks.s3.ca: %w", err)
+		}
+		cfg.CA = []byte(ca)
+	}
+	client, err := s3.New(cfg)
+	if err != nil {
+		return status, err
+	}
+	left := []string{}
+	for _, run := range pending[:len(pending)-1] {
+		file := bundle.Path(BundlesPath(), rec.cfg.Namespace, rec.cfg.Name, run)
+		if _, err := os.Stat(file); err != nil {
+			logger.Info("pending bundle is gone", zap.String("run", run), zap.Error(err))
+			continue
+		}
+		if _, err := rec.uploadBundle(client, spec, run); err != nil {
+			logger.Error("pending bundle upload", zap.String("run", run), zap.Error(err))
+			left = append(left, run)
+		}
+	}
+	status["pending"] = append(left, b.id)
+	res, err := rec.uploadBundle(client, spec, b.id)
+	if err != nil {
+		return status, err
+	}
+	if len(left) > 0 {
+		status["pending"] = left
+	} else {
+		delete(status, "pending")
+	}
+	status["url"] = res.Url
+	if !spec.Artifacts {
+		return status, nil
+	}
+	prefix, err := rec.sinkPrefix(b.id, spec.Prefix)
+	if err != nil {
+		return status, fmt.Errorf("spec.sinks.s3.prefix: %w", err)
+	}
+	artifacts := 0
+	err = filepath.WalkDir(b.shared, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || !e.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(b.shared, p)
+		if err != nil {
+			return err
+		}
+		if _, err = uploadFile(rec.ctx, client, path.Join(prefix, b.id, filepath.ToSlash(rel)), p); err != nil {
+			return err
+		}
+		artifacts++
+		return nil
+	})
+	status["artifacts"] = artifacts
+	status["artifactsUrl"] = client.Url(path.Join(prefix, b.id) + "/")
+	return status, err
+}
+
+// uploadBundle uploads the bundle of run to <prefix>/<run>.tar.gz.
+func (rec *reconciler) uploadBundle(client *s3.Client, spec s3Spec, run string) (s3.Result, error) {
+	prefix, err := rec.sinkPrefix(run, spec.Prefix)
+	if err != nil {
+		return s3.Result{}, fmt.Errorf("spec.sinks.s3.prefix: %w", err)
+	}
+	res, err := uploadFile(rec.ctx, client, path.Join(prefix, run+bundle.Ext), bundle.Path(BundlesPath(), rec.cfg.Namespace, rec.cfg.Name, run))
+	if err == nil {
+		logger.Info("bundle uploaded", zap.String("run", run), zap.String("url", res.Url), zap.Int64("size", res.Size), zap.Int("resumedParts", res.Resumed))
+	}
+	return res, err
+}
+
+// uploadFile uploads file in clear.
+func uploadFile(ctx context.Context, client *s3.Client, key string, file string) (s3.Result, error) {
+	f, err := crypt.Open(file)
+	if err != nil {
+		return s3.Result{}, err
+	}
+	defer f.Close()
+	return client.Upload(ctx, key, f)
+}
+
+// ociSpec is spec.sinks.oci. The bundle is pushed to repository tagged <config>-<run>.
+// authSecret is a kubernetes.io/dockerconfigjson Secret, a pull secret with push rights.
+type ociSpec struct {
+	Repository string           `json:"repository"`
+	AuthSecret string           `json:"authSecret"`
+	CA         *configMapKeyRef `json:"ca"`
+	PlainHTTP  bool             `json:"plainHTTP"`
+}
+
+// pushOCI pushes the bundle to spec.sinks.oci as an artifact annotated with the score and the
+// findings by severity. Like uploadS3 a failure is recorded in status and does not fail the build.
+func (rec *reconciler) pushOCI(b *build) error {
+	spec := ociSpec{}
+	if err := rec.specAs(".sinks.oci", &spec); err != nil {
+		return err
+	}
+	if spec.Repository == "" {
+		return rec.updateStatus("del(.sinks.oci)")
+	}
+	status, err := rec.ociPush(b, spec)
+	if err != nil {
+		logger.Error("oci push", zap.Error(err))
+		status["error"] = err.Error()
+	}
+	return rec.statusSet(".sinks.oci", status)
+}
+
+func (rec *reconciler) ociPush(b *build, spec ociSpec) (map[string]any, error) {
+	status := map[string]any{"run": b.id}
+	ref, err := oci.ParseReference(spec.Repository)
+	if err != nil {
+		return status, fmt.Errorf("spec.sinks.oci.repository: %w", err)
+	}
+	// tags hold 128 characters, the config name is cut so the run id is kept whole
+	name := rec.cfg.Name[:min(len(rec.cfg.Name), 127-len(b.id))]
+	ref.Tag = oci.Tag(name + "-" + b.id)
+	cfg := oci.Config{PlainHTTP: spec.PlainHTTP}
+	if spec.AuthSecret != "" {
+		data, err := rec.secretData(spec.AuthSecret)
+		if err != nil {
+			return status, fmt.Errorf("spec.sinks.oci.authSecret: %w", err)
+		}
+		if cfg.Auth = data[".dockerconfigjson"]; cfg.Auth == nil {
+			cfg.Auth = data[".dockercfg"]
+		}
+		if cfg.Auth == nil {
+			return status, fmt.Errorf("spec.sinks.oci.authSecret: secret %s has no .dockerconfigjson", spec.AuthSecret)
+		}
+	}
+	if spec.CA != nil {
+		ca, err := rec.configMapValue(*spec.CA)
+		if err != nil {
+			return status, fmt.Errorf("spec.sinks.oci.ca: %w", err)
+		}
+		cfg.CA = []byte(ca)
+	}
+	client, err := oci.New(cfg)
+	if err != nil {
+		return status, err
+	}
+	annotations := map[string]string{
+		oci.AnnotationCreated: time.Now().UTC().Format(time.RFC3339),
+		oci.AnnotationConfig:  rec.cfg.Namespace + "/" + rec.cfg.Name,
+		oci.AnnotationRun:     b.id,
+	}
+	if b.score != nil {
+		annotations[oci.AnnotationScore] = strconv.FormatFloat(b.score.Overall.Score, 'f', -1, 64)
+	}
+	for severity, n := range check.CountBySeverity(b.allFindings()) {
+		annotations[oci.AnnotationFindings+severity] = strconv.Itoa(n)
+	}
+	file := bundle.Path(BundlesPath(), rec.cfg.Namespace, rec.cfg.Name, b.id)
+	open := func() (io.ReadCloser, error) { return crypt.Open(file) }
+	digest, err := client.Push(rec.ctx, ref, ref.Tag+bundle.Ext, open, annotations)
+	if err != nil {
+		return status, err
+	}
+	logger.Info("bundle pushed", zap.String("reference", ref.String()), zap.String("digest", digest))
+	status["reference"] = ref.String()
+	status["digest"] = digest
+	return status, nil
+}
@@ -0,0 +1,193 @@
+package hcr
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"adoption.latam/hcr/internal/pkg/bundle"
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/oci"
+	"adoption.latam/hcr/internal/pkg/score"
+)
+
+// bucket is an S3 endpoint keeping the objects put, or failing every request while down.
+type bucket struct {
+	*httptest.Server
+	lock    sync.Mutex
+	objects map[string][]byte
+	down    bool
+}
+
+func newBucket() *bucket {
+	b := &bucket{objects: map[string][]byte{}}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		if b.down || req.Method != http.MethodPut {
+			http.Error(w, "<Error><Code>ServiceUnavailable</Code><Message>down</Message></Error>", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		b.objects[req.URL.Path] = body
+	}))
+	DeferCleanup(b.Close)
+	return b
+}
+
+func (b *bucket) keys() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	keys := []string{}
+	for k := range b.objects {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// registry is a distribution API without authentication keeping the manifests pushed by tag.
+type registry struct {
+	*httptest.Server
+	lock      sync.Mutex
+	manifests map[string][]byte
+}
+
+func newRegistry() *registry {
+	r := &registry{manifests: map[string][]byte{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("HEAD /v2/reports/blobs/{digest}", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("POST /v2/reports/blobs/uploads/", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Location", "/v2/reports/blobs/uploads/1")
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("PUT /v2/reports/blobs/uploads/{id}", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("PUT /v2/reports/manifests/{tag}", func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		r.manifests[req.PathValue("tag")] = b
+		w.WriteHeader(http.StatusCreated)
+	})
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		mux.ServeHTTP(w, req)
+	}))
+	DeferCleanup(r.Close)
+	return r
+}
+
+// credentials is the Secret of the s3 sink.
+var credentials = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "hcr", Name: "s3"},
+	Data: map[string][]byte{"AWS_ACCESS_KEY_ID": []byte("id"), "AWS_SECRET_ACCESS_KEY": []byte("secret")}}
+
+// bundled starts run id of rec with a report in its shared copy and its bundle on disk.
+func bundled(rec *reconciler, id string) *build {
+	b := newBuild(rec, id)
+	Expect(os.WriteFile(filepath.Join(b.shared, "index.md"), []byte("# report\n"), 0644)).To(Succeed())
+	file := bundle.Path(BundlesPath(), rec.cfg.Namespace, rec.cfg.Name, id)
+	Expect(os.MkdirAll(filepath.Dir(file), 0755)).To(Succeed())
+	Expect(os.WriteFile(file, []byte(id), 0644)).To(Succeed())
+	return b
+}
+
+var _ = Describe("Sinks", func() {
+	Describe("s3", func() {
+		It("uploads the bundle and the artifacts of the run under the prefix", func() {
+			s3 := newBucket()
+			rec := newReconciler("cfg", "sinks: {s3: {endpoint: '"+s3.URL+"', bucket: reports, credentialsSecret: s3, artifacts: true}}", credentials)
+			Expect(rec.uploadS3(bundled(rec, "20261019T000000Z"))).To(Succeed())
+			Expect(s3.keys()).To(ConsistOf("/reports/hcr/cfg/20261019T000000Z.tar.gz", "/reports/hcr/cfg/20261019T000000Z/index.md"))
+			result := map[string]any{}
+			status(rec, ".sinks.s3", &result)
+			Expect(result).To(HaveKeyWithValue("url", s3.URL+"/reports/hcr/cfg/20261019T000000Z.tar.gz"))
+			Expect(result).To(HaveKeyWithValue("artifacts", 1.0))
+			Expect(result).NotTo(HaveKey("pending"))
+			Expect(result).NotTo(HaveKey("error"))
+		})
+
+		It("records a failed upload without failing and sends it with the next run", func() {
+			s3 := newBucket()
+			s3.down = true
+			rec := newReconciler("cfg", "sinks: {s3: {endpoint: '"+s3.URL+"', bucket: reports, prefix: '{{.Config}}', credentialsSecret: s3}}", credentials)
+			Expect(rec.uploadS3(bundled(rec, "20261018T000000Z"))).To(Succeed())
+			var pending []string
+			status(rec, ".sinks.s3.pending", &pending)
+			Expect(pending).To(Equal([]string{"20261018T000000Z"}))
+			var failure string
+			status(rec, ".sinks.s3.error", &failure)
+			Expect(failure).To(ContainSubstring("ServiceUnavailable"))
+
+			s3.down = false
+			Expect(rec.uploadS3(bundled(rec, "20261019T000000Z"))).To(Succeed())
+			Expect(s3.keys()).To(ConsistOf("/reports/cfg/20261018T000000Z.tar.gz", "/reports/cfg/20261019T000000Z.tar.gz"))
+			result := map[string]any{}
+			status(rec, ".sinks.s3", &result)
+			Expect(result).NotTo(HaveKey("pending"))
+			Expect(result).NotTo(HaveKey("error"))
+		})
+
+		It("records missing credentials", func() {
+			rec := newReconciler("cfg", "sinks: {s3: {endpoint: 'http://localhost:1', bucket: reports, credentialsSecret: missing}}")
+			Expect(rec.uploadS3(bundled(rec, "20261019T000000Z"))).To(Succeed())
+			var failure string
+			status(rec, ".sinks.s3.error", &failure)
+			Expect(failure).To(ContainSubstring("spec.sinks.s3.credentialsSecret: secret missing"))
+		})
+
+		It("clears the status without a sink", func() {
+			rec := newReconciler("cfg", "")
+			rec.cfg.Status = []byte(`{"sinks": {"s3": {"run": "20261018T000000Z"}}}`)
+			Expect(rec.uploadS3(bundled(rec, "20261019T000000Z"))).To(Succeed())
+			var sinks map[string]any
+			status(rec, ".sinks", &sinks)
+			Expect(sinks).NotTo(HaveKey("s3"))
+		})
+	})
+
+	Describe("oci", func() {
+		It("pushes the bundle tagged with the Config and the run and annotated with the score and findings", func() {
+			registry := newRegistry()
+			repository := strings.TrimPrefix(registry.URL, "http://") + "/reports"
+			rec := newReconciler("cfg", "sinks: {oci: {repository: '"+repository+"', plainHTTP: true}}")
+			b := bundled(rec, "20261019T000000Z")
+			b.score = &score.Card{Overall: score.Score{Score: 87.5}}
+			b.findings = []check.Finding{finding("A", "x"), finding("A", "y")}
+			Expect(rec.pushOCI(b)).To(Succeed())
+			Expect(registry.manifests).To(HaveKey("cfg-20261019T000000Z"))
+			m := oci.Manifest{}
+			Expect(json.Unmarshal(registry.manifests["cfg-20261019T000000Z"], &m)).To(Succeed())
+			Expect(m.Annotations).To(HaveKeyWithValue(oci.AnnotationConfig, "hcr/cfg"))
+			Expect(m.Annotations).To(HaveKeyWithValue(oci.AnnotationRun, "20261019T000000Z"))
+			Expect(m.Annotations).To(HaveKeyWithValue(oci.AnnotationScore, "87.5"))
+			Expect(m.Annotations).To(HaveKeyWithValue(oci.AnnotationFindings+check.SeverityLow, "2"))
+			Expect(m.Layers).To(HaveLen(1))
+			Expect(m.Layers[0].Size).To(BeEquivalentTo(len("20261019T000000Z")))
+			result := map[string]any{}
+			status(rec, ".sinks.oci", &result)
+			Expect(result).To(HaveKeyWithValue("reference", repository+":cfg-20261019T000000Z"))
+			Expect(result).To(HaveKey("digest"))
+		})
+
+		It("records a missing pull secret without failing", func() {
+			rec := newReconciler("cfg", "sinks: {oci: {repository: 'registry.example.com/reports', authSecret: missing}}")
+			Expect(rec.pushOCI(bundled(rec, "20261019T000000Z"))).To(Succeed())
+			var failure string
+			status(rec, ".sinks.oci.error", &failure)
+			Expect(failure).To(ContainSubstring("spec.sinks.oci.authSecret: secret missing"))
+		})
+	})
+})
@@ -0,0 +1,28 @@
+package hcr
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	conditionFindingsWithinThreshold = "FindingsWithinThreshold"
+	conditionWaiversCurrent          = "WaiversCurrent"
+)
+
+// statusSetCondition adds or replaces a condition in status.conditions keeping
+// its lastTransitionTime when the condition status did not change.
+func (rec *reconciler) statusSetCondition(cType string, status metav1.ConditionStatus, reason string, message string) error {
+	conditions := []metav1.Condition{}
+	if err := jqAs(`.conditions`, string(rec.cfg.Status), &conditions); err != nil {
+		return err
+	}
+	meta.SetStatusCondition(&conditions, metav1.Condition{
+		Type:               cType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: rec.cfg.Generation,
+	})
+	return rec.statusSet(".conditions", conditions)
+}
@@ -0,0 +1,46 @@
+package hcr
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"adoption.latam/hcr/internal/pkg/waiver"
+)
+
+// applyWaivers takes the findings accepted by spec.waivers out of the results and flags the expired
+// waivers. Invalid waivers fail the build and are flagged too.
+func (rec *reconciler) applyWaivers(b *build) error {
+	waivers := []waiver.Waiver{}
+	if err := rec.specAs(".waivers", &waivers); err != nil {
+		return err
+	}
+	if err := waiver.Validate(waivers); err != nil {
+		err = fmt.Errorf("spec.waivers: %w", err)
+		if serr := rec.statusSetCondition(conditionWaiversCurrent, metav1.ConditionFalse, "InvalidWaivers", err.Error()); serr != nil {
+			return serr
+		}
+		return err
+	}
+	b.results, b.waived, b.expired = waiver.Apply(waivers, b.results, time.Now())
+	if err := rec.statusSet(".waivers", map[string]int{
+		"total":   len(waivers),
+		"expired": len(b.expired),
+		"waived":  len(b.waived),
+	}); err != nil {
+		return err
+	}
+	if len(b.expired) == 0 {
+		return rec.statusSetCondition(conditionWaiversCurrent, metav1.ConditionTrue, "NoExpiredWaivers",
+			fmt.Sprintf("%d waivers accepted %d findings", len(waivers), len(b.waived)))
+	}
+	names := make([]string, len(b.expired))
+	for i, w := range b.expired {
+		names[i] = w.Name
+		logger.Warn("waiver expired", zap.String("waiver", w.Name), zap.String("expires", w.Expires), zap.Strings("checks", w.Checks))
+	}
+	return rec.statusSetCondition(conditionWaiversCurrent, metav1.ConditionFalse, "WaiversExpired",
+		fmt.Sprintf("expired waivers no longer accept findings: %v", names))
+}
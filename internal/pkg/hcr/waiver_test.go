@@ -0,0 +1,75 @@
+package hcr
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+// failed is a failed result of check with its findings.
+func failed(checkId string, findings ...check.Finding) check.Result {
+	return check.Result{Check: check.Check{Id: checkId}, Status: check.StatusFail, Findings: findings}
+}
+
+const waiversSpec = `waivers:
+- name: legacy
+  checks: [A]
+  objects: [{name: x}]
+  justification: replaced next quarter
+  approver: sre
+  expires: "2999-01-01"
+- name: stale
+  checks: [B]
+  justification: was replaced
+  approver: sre
+  expires: "2020-01-01"
+`
+
+var _ = Describe("Waivers", func() {
+	It("takes the accepted findings out of the results and flags the expired waivers", func() {
+		rec := newReconciler("cfg", waiversSpec)
+		b := newBuild(rec, "20261019T000000Z")
+		b.results = []check.Result{failed("A", finding("A", "x")), failed("B", finding("B", "y")), failed("A", finding("A", "x"), finding("A", "z"))}
+		Expect(rec.applyWaivers(b)).To(Succeed())
+		Expect(b.results[0].Status).To(Equal(check.StatusPass))
+		Expect(b.results[0].Findings).To(BeEmpty())
+		Expect(b.results[1].Findings).To(HaveLen(1), "expired waivers accept nothing")
+		Expect(b.results[2].Findings).To(Equal([]check.Finding{finding("A", "z")}))
+		Expect(b.waived).To(HaveLen(2))
+		Expect(b.waived[0].Waiver.Name).To(Equal("legacy"))
+		Expect(b.expired).To(HaveLen(1))
+		counts := map[string]int{}
+		status(rec, ".waivers", &counts)
+		Expect(counts).To(Equal(map[string]int{"total": 2, "expired": 1, "waived": 2}))
+		var reason string
+		status(rec, `.conditions[] | select(.type == "WaiversCurrent") | .reason`, &reason)
+		Expect(reason).To(Equal("WaiversExpired"))
+	})
+
+	It("is current without expired waivers", func() {
+		rec := newReconciler("cfg", "")
+		b := newBuild(rec, "20261019T000000Z")
+		b.results = []check.Result{failed("A", finding("A", "x"))}
+		Expect(rec.applyWaivers(b)).To(Succeed())
+		Expect(b.results[0].Findings).To(HaveLen(1))
+		var condition string
+		status(rec, `.conditions[] | select(.type == "WaiversCurrent") | .status`, &condition)
+		Expect(condition).To(Equal("True"))
+	})
+
+	It("refuses waivers without approver", func() {
+		rec := newReconciler("cfg", "waivers: [{name: w, checks: [A], justification: j, expires: '2999-01-01'}]")
+		Expect(rec.applyWaivers(newBuild(rec, "20261019T000000Z"))).To(MatchError(ContainSubstring("spec.waivers: waiver w: justification and approver are mandatory")))
+	})
+
+	It("flags waivers with bad object patterns", func() {
+		rec := newReconciler("cfg", "waivers: [{name: w, checks: [A], objects: [{name: 'legacy-['}], justification: j, approver: a, expires: '2999-01-01'}]")
+		Expect(rec.applyWaivers(newBuild(rec, "20261019T000000Z"))).To(MatchError(ContainSubstring("spec.waivers: waiver w: pattern legacy-[")))
+		var condition map[string]string
+		status(rec, `.conditions[] | select(.type == "WaiversCurrent") | {status, reason, message}`, &condition)
+		Expect(condition["status"]).To(Equal("False"))
+		Expect(condition["reason"]).To(Equal("InvalidWaivers"))
+		Expect(condition["message"]).To(ContainSubstring("pattern legacy-["))
+	})
+})
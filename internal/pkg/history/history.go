@@ -0,0 +1,156 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+// File is the time series kept next to the runs of a Config.
+const File = "history.json"
+
+// Usage is the sum of the pod requests against the sum of the node allocatable.
+// cpu is in cores and memory in bytes.
+type Usage struct {
+	Allocatable float64 `json:"allocatable"`
+	Requested   float64 `json:"requested"`
+	Percent     float64 `json:"percent"`
+}
+
+// Point is what one run contributes to the trends.
+type Point struct {
+	Run      string         `json:"run"`
+	Date     string         `json:"date"`
+	Findings map[string]int `json:"findings"`
+	Score    *float64       `json:"score,omitempty"`
+	Nodes    int            `json:"nodes"`
+	Pods     int            `json:"pods"`
+	Cpu      Usage          `json:"cpu"`
+	Memory   Usage          `json:"memory"`
+}
+
+// Measure fills the cluster metrics of p out of the nodes and pods in the dump.
+// Pods that already finished are neither counted nor summed.
+func Measure(d *dump.Dump, p *Point) {
+	for _, n := range d.Items("nodes.v1") {
+		p.Nodes++
+		allocatable, _ := lookup(n, "status", "allocatable").(map[string]any)
+		p.Cpu.Allocatable += cores(allocatable["cpu"])
+		p.Memory.Allocatable += bytes(allocatable["memory"])
+	}
+	for _, pod := range d.Items("pods.v1") {
+		if phase, _ := lookup(pod, "status", "phase").(string); phase == "Succeeded" || phase == "Failed" {
+			continue
+		}
+		p.Pods++
+		cpu, memory := podRequests(pod)
+		p.Cpu.Requested += cpu
+		p.Memory.Requested += memory
+	}
+	p.Cpu.Percent = percent(p.Cpu.Requested, p.Cpu.Allocatable)
+	p.Memory.Percent = percent(p.Memory.Requested, p.Memory.Allocatable)
+}
+
+// podRequests is the effective request of a pod: the sum of its containers or the
+// biggest init container, whichever is higher.
+func podRequests(pod any) (float64, float64) {
+	var cpu, memory, initCpu, initMemory float64
+	containers, _ := lookup(pod, "spec", "containers").([]any)
+	for _, c := range containers {
+		requests, _ := lookup(c, "resources", "requests").(map[string]any)
+		cpu += cores(requests["cpu"])
+		memory += bytes(requests["memory"])
+	}
+	initContainers, _ := lookup(pod, "spec", "initContainers").([]any)
+	for _, c := range initContainers {
+		requests, _ := lookup(c, "resources", "requests").(map[string]any)
+		initCpu = max(initCpu, cores(requests["cpu"]))
+		initMemory = max(initMemory, bytes(requests["memory"]))
+	}
+	return max(cpu, initCpu), max(memory, initMemory)
+}
+
+// Load reads the series from file. A missing file is an empty series.
+func Load(file string) ([]Point, error) {
+	points := []Point{}
+	b, err := crypt.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return points, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return points, json.Unmarshal(b, &points)
+}
+
+// Append adds p to the series in file replacing a point of the same run and keeping
+// only the latest limit points. The resulting series is returned oldest first. An unreadable
+// series, encrypted with a key no longer given for instance, is an error and stays untouched.
+func Append(file string, p Point, limit int) ([]Point, error) {
+	points, err := Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("history is unreadable and left as it is: %w", err)
+	}
+	kept := points[:0]
+	for _, o := range points {
+		if o.Run != p.Run {
+			kept = append(kept, o)
+		}
+	}
+	points = append(kept, p)
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Run < points[j].Run })
+	if limit > 0 && len(points) > limit {
+		points = points[len(points)-limit:]
+	}
+	b, err := json.Marshal(points)
+	if err != nil {
+		return nil, err
+	}
+	return points, os.WriteFile(file, b, 0644)
+}
+
+func lookup(v any, keys ...string) any {
+	for _, k := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+func quantity(v any) resource.Quantity {
+	if v == nil {
+		return resource.Quantity{}
+	}
+	q, err := resource.ParseQuantity(fmt.Sprint(v))
+	if err != nil {
+		return resource.Quantity{}
+	}
+	return q
+}
+
+func cores(v any) float64 {
+	q := quantity(v)
+	return float64(q.MilliValue()) / 1000
+}
+
+func bytes(v any) float64 {
+	q := quantity(v)
+	return float64(q.Value())
+}
+
+func percent(part float64, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return part / total * 100
+}
@@ -0,0 +1,92 @@
+package history_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/dump"
+	"adoption.latam/hcr/internal/pkg/history"
+)
+
+// loadDump writes the nodes and pods lists as a kcdump directory and loads it.
+func loadDump(nodes string, pods string) *dump.Dump {
+	dir := GinkgoT().TempDir()
+	Expect(os.WriteFile(filepath.Join(dir, "nodes.yaml"),
+		[]byte("apiVersion: v1\nkind: NodeList\nmetadata:\n  apiName: nodes\nitems:\n"+nodes), 0644)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, "pods.yaml"),
+		[]byte("apiVersion: v1\nkind: PodList\nmetadata:\n  apiName: pods\nitems:\n"+pods), 0644)).To(Succeed())
+	d, err := dump.Load(dir)
+	Expect(err).NotTo(HaveOccurred())
+	return d
+}
+
+var _ = Describe("History", func() {
+	It("measures the requests of the running pods against the node allocatable", func() {
+		d := loadDump(
+			"- status: {allocatable: {cpu: '2', memory: 4Gi}}\n- status: {allocatable: {cpu: 2000m, memory: 4Gi}}\n",
+			"- spec: {containers: [{resources: {requests: {cpu: 500m, memory: 1Gi}}}, {resources: {requests: {cpu: 500m}}}]}\n  status: {phase: Running}\n"+
+				"- spec: {containers: [{resources: {requests: {cpu: 100m}}}], initContainers: [{resources: {requests: {cpu: '1', memory: 2Gi}}}]}\n  status: {phase: Pending}\n"+
+				"- spec: {containers: [{resources: {requests: {cpu: '3'}}}]}\n  status: {phase: Succeeded}\n")
+		p := history.Point{}
+		history.Measure(d, &p)
+		Expect(p.Nodes).To(Equal(2))
+		Expect(p.Pods).To(Equal(2))
+		Expect(p.Cpu).To(Equal(history.Usage{Allocatable: 4, Requested: 2, Percent: 50}))
+		Expect(p.Memory).To(Equal(history.Usage{Allocatable: 8 << 30, Requested: 3 << 30, Percent: 37.5}))
+	})
+
+	It("does not divide by a cluster without nodes", func() {
+		p := history.Point{}
+		history.Measure(loadDump("[]\n", "[]\n"), &p)
+		Expect(p.Cpu.Percent).To(BeZero())
+		Expect(p.Memory.Percent).To(BeZero())
+	})
+
+	It("loads a missing series as an empty one", func() {
+		points, err := history.Load(filepath.Join(GinkgoT().TempDir(), history.File))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(points).To(BeEmpty())
+	})
+
+	DescribeTable("appending",
+		func(runs []string, limit int, expected []string) {
+			file := filepath.Join(GinkgoT().TempDir(), history.File)
+			var points []history.Point
+			var err error
+			for i, run := range runs {
+				score := float64(i)
+				points, err = history.Append(file, history.Point{Run: run, Score: &score}, limit)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			read, err := history.Load(file)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read).To(Equal(points))
+			got := []string{}
+			for _, p := range points {
+				got = append(got, p.Run)
+			}
+			Expect(got).To(Equal(expected))
+		},
+		Entry("keeps the runs oldest first", []string{"2", "1", "3"}, 0, []string{"1", "2", "3"}),
+		Entry("keeps only the latest limit runs", []string{"1", "2", "3", "4"}, 2, []string{"3", "4"}),
+		Entry("replaces the point of the same run", []string{"1", "2", "1"}, 0, []string{"1", "2"}),
+	)
+
+	It("refuses to overwrite a series it cannot read", func() {
+		dir := GinkgoT().TempDir()
+		file := filepath.Join(dir, history.File)
+		k := crypt.Keyring{Key: []byte("0123456789abcdef0123456789abcdef")}
+		Expect(k.WriteFile(file, []byte(`[{"run":"1"}]`), 0644)).To(Succeed())
+		before, err := os.ReadFile(file)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = history.Append(file, history.Point{Run: "2"}, 0)
+		Expect(err).To(MatchError(ContainSubstring("history is unreadable")))
+		after, err := os.ReadFile(file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(after).To(Equal(before))
+	})
+})
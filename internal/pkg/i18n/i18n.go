@@ -0,0 +1,140 @@
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+	"gopkg.in/yaml.v3"
+)
+
+// Default is the language templates and checks are written in and the fallback of every other one.
+const Default = "en"
+
+var (
+	//go:embed catalogs/*.yaml
+	catalogsFS embed.FS
+	Languages  = []string{Default, "pt-BR", "es"}
+
+	// dateLayouts hold the month as January to be replaced by its translation.
+	dateLayouts = map[string]string{
+		"en":    "January 2, 2006",
+		"pt-BR": "2 de January de 2006",
+		"es":    "2 de January de 2006",
+	}
+	timeLayouts = map[string]string{
+		"en":    "3:04 PM MST",
+		"pt-BR": "15:04 MST",
+		"es":    "15:04 MST",
+	}
+)
+
+// Catalog maps english strings to their translation. Missing entries fall back to english.
+type Catalog map[string]string
+
+type Localizer struct {
+	Lang    string
+	catalog Catalog
+	printer *message.Printer
+}
+
+// Supported tells if lang is one of Languages.
+func Supported(lang string) bool {
+	for _, l := range Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns a localizer for lang merging extra over the embedded catalog. An empty lang is english.
+func New(lang string, extra ...Catalog) (*Localizer, error) {
+	if lang == "" {
+		lang = Default
+	}
+	if !Supported(lang) {
+		return nil, fmt.Errorf("language %s is not supported. use one of %s", lang, strings.Join(Languages, ", "))
+	}
+	l := &Localizer{Lang: lang, catalog: Catalog{}, printer: message.NewPrinter(language.MustParse(lang))}
+	if lang != Default {
+		b, err := catalogsFS.ReadFile("catalogs/" + lang + ".yaml")
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.Unmarshal(b, &l.catalog); err != nil {
+			return nil, fmt.Errorf("catalog %s: %w", lang, err)
+		}
+	}
+	for _, c := range extra {
+		for k, v := range c {
+			l.catalog[k] = v
+		}
+	}
+	return l, nil
+}
+
+// MustNew is New for languages known to be supported.
+func MustNew(lang string) *Localizer {
+	l, err := New(lang)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// T translates s. With args s is a format whose numbers are printed the locale way.
+func (l *Localizer) T(s string, args ...any) string {
+	if t, ok := l.catalog[s]; ok && t != "" {
+		s = t
+	}
+	if len(args) == 0 {
+		return s
+	}
+	return l.printer.Sprintf(s, args...)
+}
+
+// Number formats ints and floats, with up to 2 decimals, with the locale separators.
+// Anything else is printed as is.
+func (l *Localizer) Number(v any) string {
+	switch v.(type) {
+	case int, int32, int64, uint, uint32, uint64:
+		return l.printer.Sprint(number.Decimal(v))
+	case float32, float64:
+		return l.printer.Sprint(number.Decimal(v, number.MaxFractionDigits(2)))
+	}
+	return fmt.Sprint(v)
+}
+
+// Date formats a time or an RFC3339 string as a long date. Unparseable strings are returned as is.
+func (l *Localizer) Date(v any) string {
+	return l.format(v, dateLayouts[l.Lang])
+}
+
+// DateTime is Date followed by the time of day.
+func (l *Localizer) DateTime(v any) string {
+	return l.format(v, dateLayouts[l.Lang]+" "+timeLayouts[l.Lang])
+}
+
+func (l *Localizer) format(v any, layout string) string {
+	var t time.Time
+	switch d := v.(type) {
+	case time.Time:
+		t = d
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339, d); err != nil {
+			return d
+		}
+	default:
+		return fmt.Sprint(v)
+	}
+	month := t.Month().String()
+	// the month goes through a placeholder so a translated month never meets the layout
+	s := t.Format(strings.Replace(layout, "January", "\x00", 1))
+	return strings.Replace(s, "\x00", l.T(month), 1)
+}
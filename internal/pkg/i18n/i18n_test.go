@@ -0,0 +1,86 @@
+package i18n_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+
+	"adoption.latam/hcr/internal/pkg/i18n"
+)
+
+var verbRe = regexp.MustCompile(`%[a-z]`)
+
+var _ = Describe("I18n", func() {
+	It("rejects the languages it does not support", func() {
+		_, err := i18n.New("fr")
+		Expect(err).To(MatchError(ContainSubstring("language fr is not supported")))
+		Expect(i18n.Supported("pt-BR")).To(BeTrue())
+		Expect(i18n.Supported("pt")).To(BeFalse())
+	})
+
+	It("is english without a language", func() {
+		l, err := i18n.New("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(l.Lang).To(Equal(i18n.Default))
+		Expect(l.T("Summary")).To(Equal("Summary"))
+	})
+
+	DescribeTable("embedded catalogs keep the verbs of every english string",
+		func(lang string) {
+			b, err := os.ReadFile(filepath.Join("catalogs", lang+".yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			c := i18n.Catalog{}
+			Expect(yaml.Unmarshal(b, &c)).To(Succeed())
+			Expect(c).NotTo(BeEmpty())
+			for en, t := range c {
+				Expect(verbRe.FindAllString(t, -1)).To(Equal(verbRe.FindAllString(en, -1)), en)
+			}
+		},
+		Entry(nil, "pt-BR"),
+		Entry(nil, "es"),
+	)
+
+	DescribeTable("translating",
+		func(lang string, s string, args []any, expected string) {
+			l, err := i18n.New(lang, i18n.Catalog{"Cover": "Folha de rosto", "Findings": ""})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(l.T(s, args...)).To(Equal(expected))
+		},
+		Entry("from the catalog", "es", "Summary", nil, "Resumen"),
+		Entry("with extra catalogs over the embedded one", "pt-BR", "Cover", nil, "Folha de rosto"),
+		Entry("falling back to english without a translation", "pt-BR", "Not in any catalog", nil, "Not in any catalog"),
+		Entry("falling back to english with an empty translation", "pt-BR", "Findings", nil, "Findings"),
+		Entry("formatting the numbers of the arguments the locale way", "pt-BR", "%d findings", []any{1234}, "1.234 achados"),
+		Entry("formatting the numbers of english", "", "%d findings", []any{1234}, "1,234 findings"),
+	)
+
+	DescribeTable("numbers",
+		func(lang string, v any, expected string) {
+			Expect(i18n.MustNew(lang).Number(v)).To(Equal(expected))
+		},
+		Entry("of ints", "en", 1234567, "1,234,567"),
+		Entry("of floats up to 2 decimals", "pt-BR", 1234.567, "1.234,57"),
+		Entry("of anything else as is", "es", "n/a", "n/a"),
+	)
+
+	DescribeTable("dates",
+		func(lang string, v any, withTime bool, expected string) {
+			l := i18n.MustNew(lang)
+			if withTime {
+				Expect(l.DateTime(v)).To(Equal(expected))
+			} else {
+				Expect(l.Date(v)).To(Equal(expected))
+			}
+		},
+		Entry("in english", "en", "2026-10-19T14:05:00Z", false, "October 19, 2026"),
+		Entry("with the month translated", "pt-BR", "2026-10-19T14:05:00Z", false, "19 de outubro de 2026"),
+		Entry("of a time with the time of day", "es", time.Date(2026, 10, 19, 14, 5, 0, 0, time.UTC), true, "19 de octubre de 2026 14:05 UTC"),
+		Entry("with the english time of day", "en", "2026-10-19T14:05:00Z", true, "October 19, 2026 2:05 PM UTC"),
+		Entry("of unparseable strings as is", "es", "yesterday", false, "yesterday"),
+	)
+})
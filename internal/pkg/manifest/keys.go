@@ -0,0 +1,69 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ParsePrivateKey reads a PKCS #8 PEM key, as made by openssl genpkey -algorithm ed25519, or a
+// raw 32 byte seed or 64 byte key, those two possibly base64 encoded.
+func ParsePrivateKey(b []byte) (ed25519.PrivateKey, error) {
+	if block, _ := pem.Decode(b); block != nil {
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if pk, ok := k.(ed25519.PrivateKey); ok {
+			return pk, nil
+		}
+		return nil, fmt.Errorf("%T is not an ed25519 key", k)
+	}
+	raw := decode(b)
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, errors.New("not a PEM, seed or raw ed25519 private key")
+}
+
+// ParsePublicKey reads a PKIX PEM key, as made by openssl pkey -pubout, or a raw 32 byte key possibly base64 encoded.
+func ParsePublicKey(b []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(b); block != nil {
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if pk, ok := k.(ed25519.PublicKey); ok {
+			return pk, nil
+		}
+		return nil, fmt.Errorf("%T is not an ed25519 key", k)
+	}
+	if raw := decode(b); len(raw) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(raw), nil
+	}
+	return nil, errors.New("not a PEM or raw ed25519 public key")
+}
+
+// MarshalPublicKey encodes pub as a PKIX PEM block.
+func MarshalPublicKey(pub ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// decode returns b base64 decoded when it is base64 text and b itself otherwise.
+func decode(b []byte) []byte {
+	if d, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b))); err == nil {
+		return d
+	}
+	return b
+}
@@ -0,0 +1,183 @@
+// Package manifest lists the sha256 of every artifact of a run with the run metadata and signs
+// the list with ed25519 so a delivered report can be checked against the operator public key.
+package manifest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/export"
+)
+
+const (
+	File          = "manifest.json"
+	SignatureFile = "manifest.sig"
+	Version       = "1.0.0"
+)
+
+type Artifact struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Manifest is written to File and its exact bytes are what SignatureFile signs.
+type Manifest struct {
+	Version string     `json:"version"`
+	Run     export.Run `json:"run"`
+	Created string     `json:"created"`
+	Score   *float64   `json:"score,omitempty"`
+	// Findings counts the findings by severity.
+	Findings  map[string]int `json:"findings"`
+	Artifacts []Artifact     `json:"artifacts"`
+	// KeyId is the KeyId of the public key the manifest is signed for, empty when it is not signed.
+	// It tells keys apart and proves nothing.
+	KeyId string `json:"keyId,omitempty"`
+}
+
+// Report is the outcome of Verify. Paths are relative to the verified directory.
+type Report struct {
+	Run      export.Run `json:"run"`
+	KeyId    string     `json:"keyId"`
+	Verified int        `json:"verified"`
+	Modified []string   `json:"modified,omitempty"`
+	Missing  []string   `json:"missing,omitempty"`
+	Unlisted []string   `json:"unlisted,omitempty"`
+}
+
+// Ok tells if every artifact matched and nothing was added.
+func (r Report) Ok() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Unlisted) == 0
+}
+
+// KeyId is the first 8 bytes of the sha256 of the public key in hex.
+func KeyId(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Artifacts hashes every file under dir but the manifest and its signature. Encrypted files
+// are hashed in clear so the manifest holds for a decrypted copy.
+func Artifacts(dir string) ([]Artifact, error) {
+	artifacts := []Artifact{}
+	err := filepath.WalkDir(dir, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || !e.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == File || rel == SignatureFile {
+			return nil
+		}
+		a, err := hash(p)
+		if err != nil {
+			return err
+		}
+		a.Path = rel
+		artifacts = append(artifacts, a)
+		return nil
+	})
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Path < artifacts[j].Path })
+	return artifacts, err
+}
+
+func hash(file string) (Artifact, error) {
+	f, err := crypt.Open(file)
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	return Artifact{Size: n, Sha256: hex.EncodeToString(h.Sum(nil))}, err
+}
+
+// Write lists the artifacts of dir into m and writes it to dir/File unsigned.
+func Write(dir string, m Manifest) (Manifest, []byte, error) {
+	var err error
+	if m.Artifacts, err = Artifacts(dir); err != nil {
+		return m, nil, err
+	}
+	m.Version = Version
+	m.Created = time.Now().UTC().Format(time.RFC3339)
+	j, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return m, nil, err
+	}
+	return m, j, os.WriteFile(filepath.Join(dir, File), j, 0644)
+}
+
+// Sign writes m like Write does and its signature by key to dir/SignatureFile.
+func Sign(dir string, m Manifest, key ed25519.PrivateKey) (Manifest, error) {
+	m.KeyId = KeyId(key.Public().(ed25519.PublicKey))
+	m, j, err := Write(dir, m)
+	if err != nil {
+		return m, err
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, j))
+	return m, os.WriteFile(filepath.Join(dir, SignatureFile), []byte(sig+"\n"), 0644)
+}
+
+// Verify checks the signature of the manifest of dir with pub and then every artifact against it.
+// A bad signature is an error, artifacts that do not match are in the report.
+func Verify(dir string, pub ed25519.PublicKey) (Report, error) {
+	r := Report{}
+	j, err := crypt.ReadFile(filepath.Join(dir, File))
+	if err != nil {
+		return r, err
+	}
+	b, err := crypt.ReadFile(filepath.Join(dir, SignatureFile))
+	if err != nil {
+		return r, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b)))
+	if err != nil {
+		return r, fmt.Errorf("%s: %w", SignatureFile, err)
+	}
+	if !ed25519.Verify(pub, j, sig) {
+		return r, errors.New("the manifest signature does not match the public key")
+	}
+	m := Manifest{}
+	if err = json.Unmarshal(j, &m); err != nil {
+		return r, err
+	}
+	r.Run, r.KeyId = m.Run, m.KeyId
+	actual, err := Artifacts(dir)
+	if err != nil {
+		return r, err
+	}
+	for _, a := range m.Artifacts {
+		i := slices.IndexFunc(actual, func(o Artifact) bool { return o.Path == a.Path })
+		switch {
+		case i < 0:
+			r.Missing = append(r.Missing, a.Path)
+		case actual[i].Sha256 != a.Sha256 || actual[i].Size != a.Size:
+			r.Modified = append(r.Modified, a.Path)
+		default:
+			r.Verified++
+		}
+	}
+	for _, a := range actual {
+		if !slices.ContainsFunc(m.Artifacts, func(o Artifact) bool { return o.Path == a.Path }) {
+			r.Unlisted = append(r.Unlisted, a.Path)
+		}
+	}
+	return r, nil
+}
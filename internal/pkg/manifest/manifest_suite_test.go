@@ -0,0 +1,14 @@
+package manifest_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestManifest(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Manifest Suite")
+}
@@ -0,0 +1,126 @@
+package manifest_test
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/export"
+	"adoption.latam/hcr/internal/pkg/manifest"
+)
+
+var (
+	key      = newKey("key")
+	otherKey = newKey("other key")
+)
+
+// newKey derives a key from seed, hashed so the raw seed is not base64 text.
+func newKey(seed string) ed25519.PrivateKey {
+	sum := sha256.Sum256([]byte(seed))
+	return ed25519.NewKeyFromSeed(sum[:])
+}
+
+// signedRun writes a run directory and signs its manifest with key.
+func signedRun() string {
+	dir := GinkgoT().TempDir()
+	Expect(os.MkdirAll(filepath.Join(dir, "docs"), 0755)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, "findings.json"), []byte(`[]`), 0644)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, "docs", "index.md"), []byte("# Report\n"), 0644)).To(Succeed())
+	m, err := manifest.Sign(dir, manifest.Manifest{Run: export.Run{Id: "20250101T000000Z"}}, key)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(m.Artifacts).To(HaveLen(2))
+	return dir
+}
+
+func write(file string, content string) {
+	Expect(os.WriteFile(file, []byte(content), 0644)).To(Succeed())
+}
+
+var _ = Describe("Manifest", func() {
+	DescribeTable("verifying",
+		func(tamper func(dir string), pub ed25519.PublicKey, expected manifest.Report, failure string) {
+			dir := signedRun()
+			tamper(dir)
+			r, err := manifest.Verify(dir, pub)
+			if failure != "" {
+				Expect(err).To(MatchError(ContainSubstring(failure)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			expected.Run = export.Run{Id: "20250101T000000Z"}
+			expected.KeyId = manifest.KeyId(key.Public().(ed25519.PublicKey))
+			Expect(r).To(Equal(expected))
+			Expect(r.Ok()).To(Equal(expected.Modified == nil && expected.Missing == nil && expected.Unlisted == nil))
+		},
+		Entry("an untouched run", func(string) {}, key.Public(),
+			manifest.Report{Verified: 2}, ""),
+		Entry("a modified artifact", func(dir string) { write(filepath.Join(dir, "findings.json"), `[{}]`) }, key.Public(),
+			manifest.Report{Verified: 1, Modified: []string{"findings.json"}}, ""),
+		Entry("a removed artifact", func(dir string) { Expect(os.Remove(filepath.Join(dir, "docs", "index.md"))).To(Succeed()) }, key.Public(),
+			manifest.Report{Verified: 1, Missing: []string{"docs/index.md"}}, ""),
+		Entry("an added artifact", func(dir string) { write(filepath.Join(dir, "docs", "extra.md"), "") }, key.Public(),
+			manifest.Report{Verified: 2, Unlisted: []string{"docs/extra.md"}}, ""),
+		Entry("a modified manifest", func(dir string) {
+			b, err := os.ReadFile(filepath.Join(dir, manifest.File))
+			Expect(err).NotTo(HaveOccurred())
+			write(filepath.Join(dir, manifest.File), string(b)+" ")
+		}, key.Public(), manifest.Report{}, "signature does not match"),
+		Entry("another public key", func(string) {}, otherKey.Public(),
+			manifest.Report{}, "signature does not match"),
+		Entry("a garbled signature", func(dir string) { write(filepath.Join(dir, manifest.SignatureFile), "not base64!") }, key.Public(),
+			manifest.Report{}, manifest.SignatureFile),
+		Entry("a missing signature", func(dir string) { Expect(os.Remove(filepath.Join(dir, manifest.SignatureFile))).To(Succeed()) }, key.Public(),
+			manifest.Report{}, manifest.SignatureFile),
+	)
+
+	DescribeTable("private keys",
+		func(b []byte, ok bool) {
+			k, err := manifest.ParsePrivateKey(b)
+			if ok {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k).To(Equal(key))
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		Entry("PKCS #8 PEM", func() []byte {
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			Expect(err).NotTo(HaveOccurred())
+			return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		}(), true),
+		Entry("a raw seed", key.Seed(), true),
+		Entry("a base64 seed", []byte(base64.StdEncoding.EncodeToString(key.Seed())+"\n"), true),
+		Entry("a raw key", []byte(key), true),
+		Entry("a short key", []byte("short"), false),
+		Entry("a bad PEM", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("der")}), false),
+	)
+
+	DescribeTable("public keys",
+		func(b func() []byte, ok bool) {
+			k, err := manifest.ParsePublicKey(b())
+			if ok {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k).To(Equal(key.Public()))
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		Entry("PKIX PEM", func() []byte {
+			b, err := manifest.MarshalPublicKey(key.Public().(ed25519.PublicKey))
+			Expect(err).NotTo(HaveOccurred())
+			return b
+		}, true),
+		Entry("a raw key", func() []byte { return key.Public().(ed25519.PublicKey) }, true),
+		Entry("a base64 key", func() []byte {
+			return []byte(base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+		}, true),
+		Entry("a private key", func() []byte { return key }, false),
+	)
+})
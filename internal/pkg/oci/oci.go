@@ -0,0 +1,314 @@
+// Package oci pushes run bundles to OCI registries as artifacts and pulls them back, speaking the
+// distribution API directly: a blob upload per bundle and an image manifest with the bundle media
+// type as artifactType.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"adoption.latam/hcr/internal/pkg/util/log"
+)
+
+const (
+	ArtifactType      = "application/vnd.hcr.adoption.latam.bundle.v1"
+	LayerMediaType    = "application/vnd.hcr.adoption.latam.bundle.v1.tar+gzip"
+	ManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	EmptyMediaType    = "application/vnd.oci.empty.v1+json"
+
+	AnnotationTitle   = "org.opencontainers.image.title"
+	AnnotationCreated = "org.opencontainers.image.created"
+	// AnnotationPrefix starts the hcr annotations: config, run, score and findings.<severity>.
+	AnnotationPrefix   = "latam.adoption.hcr."
+	AnnotationConfig   = AnnotationPrefix + "config"
+	AnnotationRun      = AnnotationPrefix + "run"
+	AnnotationScore    = AnnotationPrefix + "score"
+	AnnotationFindings = AnnotationPrefix + "findings."
+
+	dockerHub    = "docker.io"
+	dockerHubApi = "registry-1.docker.io"
+)
+
+var (
+	logger = log.Logger().Named("hcr.oci")
+	// emptyConfig is the config blob of artifacts without one.
+	emptyConfig = []byte("{}")
+	tagRe       = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,127}$`)
+	repoRe      = regexp.MustCompile(`^[a-z0-9]+([._-]+[a-z0-9]+)*(/[a-z0-9]+([._-]+[a-z0-9]+)*)*$`)
+	digestRe    = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Reference is registry/repository[:tag|@digest].
+type Reference struct {
+	Registry   string
+	Repository string
+	// Tag is a tag or a digest.
+	Tag string
+}
+
+// ParseReference reads a reference the way docker does: the first path segment is the registry
+// when it has a dot, a port or is localhost, docker.io otherwise where single names are under library.
+func ParseReference(s string) (Reference, error) {
+	r := Reference{}
+	name, digest, ok := strings.Cut(s, "@")
+	if ok {
+		if !digestRe.MatchString(digest) {
+			return r, fmt.Errorf("%s: bad digest", s)
+		}
+		r.Tag = digest
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		if r.Tag == "" {
+			r.Tag = name[i+1:]
+		}
+		name = name[:i]
+	}
+	r.Registry, r.Repository = dockerHub, name
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.Registry, r.Repository = first, rest
+	} else if !ok {
+		r.Repository = "library/" + name
+	}
+	if !repoRe.MatchString(r.Repository) {
+		return r, fmt.Errorf("%s: bad repository %s", s, r.Repository)
+	}
+	if r.Tag != "" && !digestRe.MatchString(r.Tag) && !tagRe.MatchString(r.Tag) {
+		return r, fmt.Errorf("%s: bad tag %s", s, r.Tag)
+	}
+	return r, nil
+}
+
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if digestRe.MatchString(r.Tag) {
+		return s + "@" + r.Tag
+	}
+	if r.Tag != "" {
+		return s + ":" + r.Tag
+	}
+	return s
+}
+
+// Tag makes a valid tag out of s, replacing what a tag cannot hold with dashes.
+func Tag(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '_' || c == '.' || c == '-') {
+			b[i] = '-'
+		}
+	}
+	if len(b) > 0 && (b[0] == '.' || b[0] == '-') {
+		b[0] = '_'
+	}
+	return string(b[:min(len(b), 128)])
+}
+
+type Config struct {
+	// Auth is a docker config json, the .dockerconfigjson of a pull secret.
+	Auth []byte
+	// CA is PEM trusted besides the system roots.
+	CA []byte
+	// PlainHTTP talks http to the registry, for local registries only.
+	PlainHTTP bool
+}
+
+type Client struct {
+	cfg    Config
+	http   *http.Client
+	tokens map[string]string
+}
+
+func New(cfg Config) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(cfg.CA) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(cfg.CA) {
+			return nil, errors.New("no certificate found in the ca")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	if len(cfg.Auth) > 0 {
+		if _, err := parseAuths(cfg.Auth); err != nil {
+			return nil, err
+		}
+	}
+	return &Client{cfg: cfg, http: &http.Client{Transport: transport}, tokens: map[string]string{}}, nil
+}
+
+// Push uploads what open reads as the bundle layer, titled title, of an artifact tagged ref.Tag
+// and returns the digest of its manifest. Blobs the repository already has are not sent again.
+func (c *Client) Push(ctx context.Context, ref Reference, title string, open Opener, annotations map[string]string) (string, error) {
+	if ref.Tag == "" || digestRe.MatchString(ref.Tag) {
+		return "", fmt.Errorf("%s: a tag is needed to push", ref)
+	}
+	s := c.session(ref, "pull,push")
+	layer, err := describe(open)
+	if err != nil {
+		return "", err
+	}
+	layer.MediaType = LayerMediaType
+	layer.Annotations = map[string]string{AnnotationTitle: title}
+	config := Descriptor{MediaType: EmptyMediaType, Digest: digest(emptyConfig), Size: int64(len(emptyConfig))}
+	if err = s.pushBlob(ctx, config, bytesOpener(emptyConfig)); err != nil {
+		return "", fmt.Errorf("config blob: %w", err)
+	}
+	if err = s.pushBlob(ctx, layer, open); err != nil {
+		return "", fmt.Errorf("bundle blob: %w", err)
+	}
+	m := Manifest{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+		ArtifactType:  ArtifactType,
+		Config:        config,
+		Layers:        []Descriptor{layer},
+		Annotations:   annotations,
+	}
+	j, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	h := http.Header{"Content-Type": {ManifestMediaType}}
+	resp, err := s.do(ctx, http.MethodPut, "/manifests/"+ref.Tag, h, bytesOpener(j), int64(len(j)))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return digest(j), nil
+}
+
+// Pull fetches the manifest of ref, checks it is a bundle and writes the bundle into dir under
+// its title. The file is checked against its digest before it is given its name.
+func (c *Client) Pull(ctx context.Context, ref Reference, dir string) (Manifest, string, error) {
+	s := c.session(ref, "pull")
+	m, err := s.manifest(ctx, ref.Tag)
+	if err != nil {
+		return m, "", err
+	}
+	if m.ArtifactType != ArtifactType || len(m.Layers) != 1 || m.Layers[0].MediaType != LayerMediaType {
+		return m, "", fmt.Errorf("%s is not a report bundle", ref)
+	}
+	layer := m.Layers[0]
+	name := filepath.Base(layer.Annotations[AnnotationTitle])
+	if name == "." || name == ".." || name == "/" {
+		name = Tag(ref.Repository+"-"+ref.Tag) + ".tar.gz"
+	}
+	file := filepath.Join(dir, name)
+	resp, err := s.do(ctx, http.MethodGet, "/blobs/"+layer.Digest, nil, nil, 0)
+	if err != nil {
+		return m, "", err
+	}
+	defer resp.Body.Close()
+	tmp, err := os.CreateTemp(dir, "."+name+".*")
+	if err != nil {
+		return m, "", err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return m, "", err
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != layer.Digest || n != layer.Size {
+		return m, "", fmt.Errorf("bundle blob is %s of %d bytes, expected %s of %d", got, n, layer.Digest, layer.Size)
+	}
+	return m, file, os.Rename(tmp.Name(), file)
+}
+
+// Tags lists the tags of the repository of ref.
+func (c *Client) Tags(ctx context.Context, ref Reference) ([]string, error) {
+	s := c.session(ref, "pull")
+	tags := []string{}
+	path := "/tags/list"
+	for path != "" {
+		resp, err := s.do(ctx, http.MethodGet, path, nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		list := struct {
+			Tags []string `json:"tags"`
+		}{}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, list.Tags...)
+		path = nextPage(resp.Header.Get("Link"), s.base())
+	}
+	return tags, nil
+}
+
+// nextPage is the path, relative to base, of the rel="next" Link of a paginated list.
+func nextPage(link string, base string) string {
+	target, rel, ok := strings.Cut(link, ";")
+	if !ok || !strings.Contains(rel, `rel="next"`) {
+		return ""
+	}
+	u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+	if err != nil {
+		return ""
+	}
+	b, _ := url.Parse(base)
+	return strings.TrimPrefix(u.RequestURI(), b.Path)
+}
+
+// Opener opens the content of a blob, once to hash it and again for every upload attempt.
+type Opener func() (io.ReadCloser, error)
+
+func bytesOpener(b []byte) Opener {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func describe(open Opener) (Descriptor, error) {
+	r, err := open()
+	if err != nil {
+		return Descriptor{}, err
+	}
+	defer r.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	return Descriptor{Digest: "sha256:" + hex.EncodeToString(h.Sum(nil)), Size: n}, err
+}
@@ -0,0 +1,14 @@
+package oci
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestOci(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Oci Suite")
+}
@@ -0,0 +1,300 @@
+package oci
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// registry is a distribution API serving one repository behind bearer tokens given to user:password.
+type registry struct {
+	*httptest.Server
+	lock      sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int
+}
+
+func newRegistry(repository string) *registry {
+	r := &registry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /token", func(w http.ResponseWriter, req *http.Request) {
+		if user, password, _ := req.BasicAuth(); user != "user" || password != "password" ||
+			req.URL.Query().Get("scope") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"t"}`)
+	})
+	base := "/v2/" + repository
+	mux.HandleFunc("HEAD "+base+"/blobs/{digest}", r.blob)
+	mux.HandleFunc("GET "+base+"/blobs/{digest}", r.blob)
+	mux.HandleFunc("POST "+base+"/blobs/uploads/", func(w http.ResponseWriter, req *http.Request) {
+		r.uploads++
+		w.Header().Set("Location", fmt.Sprintf("%s/blobs/uploads/%d?state=x", base, r.uploads))
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("PUT "+base+"/blobs/uploads/{id}", func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		if d := req.URL.Query().Get("digest"); d != digest(b) || req.URL.Query().Get("state") != "x" {
+			http.Error(w, `{"errors":[{"code":"DIGEST_INVALID","message":"digest mismatch"}]}`, http.StatusBadRequest)
+			return
+		}
+		r.blobs[digest(b)] = b
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("PUT "+base+"/manifests/{tag}", func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		r.manifests[req.PathValue("tag")] = b
+		r.manifests[digest(b)] = b
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("GET "+base+"/manifests/{tag}", func(w http.ResponseWriter, req *http.Request) {
+		b, ok := r.manifests[req.PathValue("tag")]
+		if !ok {
+			http.Error(w, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`, http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	})
+	// two tags a page
+	mux.HandleFunc("GET "+base+"/tags/list", func(w http.ResponseWriter, req *http.Request) {
+		tags := []string{}
+		for t := range r.manifests {
+			if !strings.HasPrefix(t, "sha256:") {
+				tags = append(tags, t)
+			}
+		}
+		slices.Sort(tags)
+		last := req.URL.Query().Get("last")
+		i, _ := slices.BinarySearch(tags, last)
+		if last != "" {
+			i++
+		}
+		page := tags[min(i, len(tags)):min(i+2, len(tags))]
+		if i+2 < len(tags) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/tags/list?last=%s&n=2>; rel="next"`, base, page[len(page)-1]))
+		}
+		fmt.Fprintf(w, `{"tags":["%s"]}`, strings.Join(page, `","`))
+	})
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, "/v2/") && req.Header.Get("Authorization") != "Bearer t" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, r.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		mux.ServeHTTP(w, req)
+	}))
+	return r
+}
+
+func (r *registry) blob(w http.ResponseWriter, req *http.Request) {
+	b, ok := r.blobs[req.PathValue("digest")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write(b)
+}
+
+func (r *registry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func auth(host string) []byte {
+	basic := base64.StdEncoding.EncodeToString([]byte("user:password"))
+	return []byte(fmt.Sprintf(`{"auths":{"%s":{"auth":"%s"}}}`, host, basic))
+}
+
+var _ = Describe("Oci", func() {
+	DescribeTable("parsing references",
+		func(s string, expected Reference, failure string) {
+			r, err := ParseReference(s)
+			if failure != "" {
+				Expect(err).To(MatchError(ContainSubstring(failure)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r).To(Equal(expected))
+		},
+		Entry("a registry with a domain", "registry.example.com/hcr/reports:config-20250101T000000Z",
+			Reference{Registry: "registry.example.com", Repository: "hcr/reports", Tag: "config-20250101T000000Z"}, ""),
+		Entry("a registry with a port", "localhost:5000/reports",
+			Reference{Registry: "localhost:5000", Repository: "reports"}, ""),
+		Entry("localhost", "localhost/reports:latest",
+			Reference{Registry: "localhost", Repository: "reports", Tag: "latest"}, ""),
+		Entry("docker hub", "acme/reports:v1",
+			Reference{Registry: "docker.io", Repository: "acme/reports", Tag: "v1"}, ""),
+		Entry("docker hub library", "reports",
+			Reference{Registry: "docker.io", Repository: "library/reports"}, ""),
+		Entry("a digest", "quay.io/acme/reports:v1@sha256:"+strings.Repeat("a", 64),
+			Reference{Registry: "quay.io", Repository: "acme/reports", Tag: "sha256:" + strings.Repeat("a", 64)}, ""),
+		Entry("a bad digest", "quay.io/acme/reports@sha256:abc", Reference{}, "bad digest"),
+		Entry("an upper case repository", "quay.io/Acme/reports", Reference{}, "bad repository"),
+		Entry("a bad tag", "quay.io/acme/reports:-v1", Reference{}, "bad tag"),
+	)
+
+	DescribeTable("printing references",
+		func(r Reference, expected string) {
+			Expect(r.String()).To(Equal(expected))
+		},
+		Entry("with a tag", Reference{Registry: "quay.io", Repository: "acme/reports", Tag: "v1"}, "quay.io/acme/reports:v1"),
+		Entry("with a digest", Reference{Registry: "quay.io", Repository: "acme/reports", Tag: "sha256:" + strings.Repeat("a", 64)},
+			"quay.io/acme/reports@sha256:"+strings.Repeat("a", 64)),
+		Entry("alone", Reference{Registry: "quay.io", Repository: "acme/reports"}, "quay.io/acme/reports"),
+	)
+
+	DescribeTable("making tags",
+		func(s string, expected string) {
+			Expect(Tag(s)).To(Equal(expected))
+			_, err := ParseReference("quay.io/acme/reports:" + Tag(s))
+			Expect(err).NotTo(HaveOccurred())
+		},
+		Entry("a valid one", "config-20250101T000000Z", "config-20250101T000000Z"),
+		Entry("with invalid characters", "my config/run:1", "my-config-run-1"),
+		Entry("starting with a dot", ".config", "_config"),
+		Entry("starting with a dash", "-config", "_config"),
+		Entry("too long", strings.Repeat("a", 200), strings.Repeat("a", 128)),
+	)
+
+	DescribeTable("parsing challenges",
+		func(challenge string, scheme string, params map[string]string) {
+			s, p := parseChallenge(challenge)
+			Expect(s).To(Equal(scheme))
+			Expect(p).To(Equal(params))
+		},
+		Entry("bearer", `Bearer realm="https://auth.example.com/token",service="registry",scope="repository:a/b:pull,push"`, "bearer",
+			map[string]string{"realm": "https://auth.example.com/token", "service": "registry", "scope": "repository:a/b:pull,push"}),
+		Entry("basic", `Basic realm="registry"`, "basic", map[string]string{"realm": "registry"}),
+		Entry("unquoted", `Bearer realm=https://auth.example.com/token, service=registry`, "bearer",
+			map[string]string{"realm": "https://auth.example.com/token", "service": "registry"}),
+	)
+
+	DescribeTable("reading docker configs",
+		func(cfg string, host string, user string, password string) {
+			c, err := New(Config{Auth: []byte(cfg)})
+			Expect(err).NotTo(HaveOccurred())
+			u, p, err := c.session(Reference{Registry: host, Repository: "a/b"}, "pull").credentials()
+			Expect(err).NotTo(HaveOccurred())
+			Expect([]string{u, p}).To(Equal([]string{user, password}))
+		},
+		Entry("an auth", `{"auths":{"quay.io":{"auth":"dXNlcjpwYXNz"}}}`, "quay.io", "user", "pass"),
+		Entry("a username and password", `{"auths":{"quay.io":{"username":"user","password":"pass"}}}`, "quay.io", "user", "pass"),
+		Entry("a docker hub url", `{"auths":{"https://index.docker.io/v1/":{"auth":"dXNlcjpwYXNz"}}}`, "docker.io", "user", "pass"),
+		Entry("a legacy dockercfg", `{"quay.io":{"auth":"dXNlcjpwYXNz"}}`, "quay.io", "user", "pass"),
+		Entry("another registry", `{"auths":{"quay.io":{"auth":"dXNlcjpwYXNz"}}}`, "registry.example.com", "", ""),
+	)
+
+	It("rejects bad docker configs and cas", func() {
+		_, err := New(Config{Auth: []byte("not json")})
+		Expect(err).To(MatchError(ContainSubstring("docker config")))
+		_, err = New(Config{CA: []byte("not pem")})
+		Expect(err).To(MatchError("no certificate found in the ca"))
+	})
+
+	Context("with a registry", func() {
+		var (
+			ctx = context.Background()
+			reg *registry
+			ref Reference
+			c   *Client
+		)
+
+		BeforeEach(func() {
+			reg = newRegistry("hcr/reports")
+			DeferCleanup(reg.Close)
+			var err error
+			ref, err = ParseReference(reg.host() + "/hcr/reports:config-20250101T000000Z")
+			Expect(err).NotTo(HaveOccurred())
+			c, err = New(Config{Auth: auth(reg.host()), PlainHTTP: true})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("pushes and pulls a bundle", func() {
+			bundle := []byte("a tar.gz")
+			annotations := map[string]string{AnnotationRun: "20250101T000000Z", AnnotationFindings + "high": "2"}
+			d, err := c.Push(ctx, ref, "config-20250101T000000Z.tar.gz", bytesOpener(bundle), annotations)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reg.manifests).To(HaveKey(d))
+			Expect(reg.uploads).To(Equal(2))
+
+			_, err = c.Push(ctx, ref, "config-20250101T000000Z.tar.gz", bytesOpener(bundle), annotations)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reg.uploads).To(Equal(2), "blobs the repository has are not sent again")
+
+			dir := GinkgoT().TempDir()
+			byDigest := ref
+			byDigest.Tag = d
+			for _, r := range []Reference{ref, byDigest} {
+				m, file, err := c.Pull(ctx, r, dir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(m.Annotations).To(Equal(annotations))
+				Expect(file).To(Equal(filepath.Join(dir, "config-20250101T000000Z.tar.gz")))
+				b, err := os.ReadFile(file)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(b).To(Equal(bundle))
+			}
+		})
+
+		It("lists the tags page by page", func() {
+			for _, t := range []string{"a", "b", "c", "d", "e"} {
+				r := ref
+				r.Tag = t
+				_, err := c.Push(ctx, r, t+".tar.gz", bytesOpener([]byte(t)), nil)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			tags, err := c.Tags(ctx, ref)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tags).To(Equal([]string{"a", "b", "c", "d", "e"}))
+		})
+
+		It("refuses a bundle that does not match its digest", func() {
+			_, err := c.Push(ctx, ref, "bundle.tar.gz", bytesOpener([]byte("a tar.gz")), nil)
+			Expect(err).NotTo(HaveOccurred())
+			for d := range reg.blobs {
+				if d != digest(emptyConfig) {
+					reg.blobs[d] = []byte("tampered")
+				}
+			}
+			dir := GinkgoT().TempDir()
+			_, _, err = c.Pull(ctx, ref, dir)
+			Expect(err).To(MatchError(ContainSubstring("bundle blob is")))
+			entries, err := os.ReadDir(dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		It("refuses artifacts that are not bundles", func() {
+			reg.manifests[ref.Tag] = []byte(`{"schemaVersion":2,"mediaType":"` + ManifestMediaType + `","layers":[]}`)
+			_, _, err := c.Pull(ctx, ref, GinkgoT().TempDir())
+			Expect(err).To(MatchError(ContainSubstring("is not a report bundle")))
+		})
+
+		It("surfaces the registry errors", func() {
+			r := ref
+			r.Tag = "missing"
+			_, _, err := c.Pull(ctx, r, GinkgoT().TempDir())
+			Expect(err).To(MatchError(ContainSubstring("MANIFEST_UNKNOWN: manifest unknown")))
+		})
+
+		It("fails without credentials", func() {
+			anonymous, err := New(Config{PlainHTTP: true})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = anonymous.Push(ctx, ref, "bundle.tar.gz", bytesOpener([]byte("a tar.gz")), nil)
+			Expect(err).To(MatchError(ContainSubstring("token request: 401")))
+		})
+	})
+})
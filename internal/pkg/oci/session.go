@@ -0,0 +1,312 @@
+package oci
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// session talks to one repository with the token scope of actions, pull or pull,push.
+type session struct {
+	c       *Client
+	ref     Reference
+	actions string
+}
+
+func (c *Client) session(ref Reference, actions string) *session {
+	return &session{c: c, ref: ref, actions: actions}
+}
+
+func (s *session) host() string {
+	if s.ref.Registry == dockerHub {
+		return dockerHubApi
+	}
+	return s.ref.Registry
+}
+
+func (s *session) base() string {
+	scheme := "https"
+	if s.c.cfg.PlainHTTP {
+		scheme = "http"
+	}
+	return scheme + "://" + s.host() + "/v2/" + s.ref.Repository
+}
+
+func (s *session) scope() string {
+	return "repository:" + s.ref.Repository + ":" + s.actions
+}
+
+// registryError is the error document of the distribution API.
+type registryError struct {
+	Status string
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (e registryError) Error() string {
+	msgs := []string{}
+	for _, d := range e.Errors {
+		msgs = append(msgs, d.Code+": "+d.Message)
+	}
+	if len(msgs) == 0 {
+		return e.Status
+	}
+	return e.Status + ": " + strings.Join(msgs, ", ")
+}
+
+// do sends a request to path, relative to the repository, or to the absolute url a registry
+// handed out, authenticating on the first 401. Responses other than 2xx are errors.
+func (s *session) do(ctx context.Context, method string, path string, header http.Header, open Opener, size int64) (*http.Response, error) {
+	u := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		base, err := url.Parse(s.base())
+		if err != nil {
+			return nil, err
+		}
+		ref, err := url.Parse(path)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(path, "/v2/") {
+			ref.Path = base.Path + ref.Path
+		}
+		u = base.ResolveReference(ref).String()
+	}
+	var resp *http.Response
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if open != nil {
+			if req.Body, err = open(); err != nil {
+				return nil, err
+			}
+			req.ContentLength = size
+		}
+		if auth := s.c.tokens[s.tokenKey()]; auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		if resp, err = s.c.http.Do(req); err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			break
+		}
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err = s.authenticate(ctx, challenge); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		e := registryError{Status: resp.Status}
+		if b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16)); err == nil {
+			_ = json.Unmarshal(b, &e)
+		}
+		return nil, fmt.Errorf("%s %s: %w", method, s.ref, e)
+	}
+	return resp, nil
+}
+
+func (s *session) tokenKey() string {
+	return s.host() + " " + s.scope()
+}
+
+// authenticate answers a WWW-Authenticate challenge, with basic credentials or with a bearer
+// token from the realm of the challenge for the scope of the session.
+func (s *session) authenticate(ctx context.Context, challenge string) error {
+	user, password, err := s.credentials()
+	if err != nil {
+		return err
+	}
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		if user == "" {
+			return fmt.Errorf("%s: the registry wants credentials and there are none for it", s.ref.Registry)
+		}
+		s.c.tokens[s.tokenKey()] = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("%s: unsupported authentication challenge %q", s.ref.Registry, challenge)
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return fmt.Errorf("%s: bad token realm %q", s.ref.Registry, params["realm"])
+	}
+	q := realm.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", s.scope())
+	realm.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+	resp, err := s.c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: token request: %s", s.ref.Registry, resp.Status)
+	}
+	t := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return fmt.Errorf("%s: token response: %w", s.ref.Registry, err)
+	}
+	if t.Token == "" {
+		t.Token = t.AccessToken
+	}
+	if t.Token == "" {
+		return fmt.Errorf("%s: empty token", s.ref.Registry)
+	}
+	logger.Debug("registry token", zap.String("registry", s.ref.Registry), zap.String("scope", s.scope()), zap.Bool("credentials", user != ""))
+	s.c.tokens[s.tokenKey()] = "Bearer " + t.Token
+	return nil
+}
+
+// parseChallenge splits `Bearer realm="...",service="...",scope="..."`.
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+	for rest != "" {
+		var k, v string
+		k, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			v, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			v, rest, _ = strings.Cut(rest, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	return strings.ToLower(scheme), params
+}
+
+// credentials are the user and password of the registry in the docker config, empty when there are none.
+func (s *session) credentials() (string, string, error) {
+	if len(s.c.cfg.Auth) == 0 {
+		return "", "", nil
+	}
+	auths, err := parseAuths(s.c.cfg.Auth)
+	if err != nil {
+		return "", "", err
+	}
+	for k, a := range auths {
+		if h := authHost(k); h != s.ref.Registry && !(s.ref.Registry == dockerHub && strings.HasSuffix(h, "."+dockerHub)) {
+			continue
+		}
+		if a.Auth != "" {
+			b, err := base64.StdEncoding.DecodeString(a.Auth)
+			if err != nil {
+				return "", "", fmt.Errorf("auth of %s: %w", k, err)
+			}
+			user, password, _ := strings.Cut(string(b), ":")
+			return user, password, nil
+		}
+		return a.Username, a.Password, nil
+	}
+	return "", "", nil
+}
+
+type dockerAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// parseAuths reads the auths of a docker config json, or of the legacy .dockercfg which is the auths alone.
+func parseAuths(b []byte) (map[string]dockerAuth, error) {
+	cfg := struct {
+		Auths map[string]dockerAuth `json:"auths"`
+	}{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("docker config: %w", err)
+	}
+	if cfg.Auths != nil {
+		return cfg.Auths, nil
+	}
+	legacy := map[string]dockerAuth{}
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return nil, errors.New("docker config: no auths")
+	}
+	return legacy, nil
+}
+
+// authHost is the registry of a docker config key, which may be a url like https://index.docker.io/v1/.
+func authHost(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, _, _ := strings.Cut(key, "/")
+	return host
+}
+
+// pushBlob uploads the blob d unless the repository has it, in a single monolithic upload.
+func (s *session) pushBlob(ctx context.Context, d Descriptor, open Opener) error {
+	if resp, err := s.do(ctx, http.MethodHead, "/blobs/"+d.Digest, nil, nil, 0); err == nil {
+		resp.Body.Close()
+		return nil
+	}
+	resp, err := s.do(ctx, http.MethodPost, "/blobs/uploads/", nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	loc, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("%s: upload without a location", s.ref)
+	}
+	q := loc.Query()
+	q.Set("digest", d.Digest)
+	loc.RawQuery = q.Encode()
+	h := http.Header{"Content-Type": {"application/octet-stream"}}
+	if resp, err = s.do(ctx, http.MethodPut, loc.String(), h, open, d.Size); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	logger.Debug("blob pushed", zap.String("repository", s.ref.String()), zap.String("digest", d.Digest), zap.Int64("size", d.Size))
+	return nil
+}
+
+// manifest gets the manifest of tag, checking it against the digest when tag is one.
+func (s *session) manifest(ctx context.Context, tag string) (Manifest, error) {
+	m := Manifest{}
+	if tag == "" {
+		return m, fmt.Errorf("%s: a tag or digest is needed to pull", s.ref)
+	}
+	resp, err := s.do(ctx, http.MethodGet, "/manifests/"+tag, http.Header{"Accept": {ManifestMediaType}}, nil, 0)
+	if err != nil {
+		return m, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return m, err
+	}
+	if digestRe.MatchString(tag) && digest(b) != tag {
+		return m, fmt.Errorf("%s: manifest does not match its digest", s.ref)
+	}
+	return m, json.Unmarshal(b, &m)
+}
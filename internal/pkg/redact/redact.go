@@ -0,0 +1,506 @@
+package redact
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"adoption.latam/hcr/internal/pkg/util"
+	"adoption.latam/hcr/internal/pkg/util/log"
+)
+
+const (
+	// SecretsHash keeps the Secret keys with the sha256 of each value.
+	SecretsHash = "hash"
+	// SecretsKeys keeps only the Secret keys.
+	SecretsKeys = "keys"
+	// SecretsKeep leaves Secrets untouched.
+	SecretsKeep = "keep"
+
+	Mask = "**redacted**"
+
+	// AlertmanagerKey is the Secret key of the alertmanager configuration, see alertmanager.
+	AlertmanagerKey = "alertmanager.yaml"
+
+	lastApplied = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+var (
+	logger = log.Logger().Named("hcr.redact")
+	// DefaultPattern matches names usually holding credentials.
+	DefaultPattern = `(?i)(pass(word|wd)?|secret|token|api[_-]?key|private[_-]?key|credential|auth)`
+)
+
+// Spec is spec.redaction. Env and ConfigMapKeys are regular expressions matched against env var
+// names and ConfigMap data keys. Sed expressions run over every document once serialized.
+// Keep lists namespace/name patterns of Secrets left as they are.
+type Spec struct {
+	Secrets       string   `json:"secrets"`
+	Keep          []string `json:"keep"`
+	Env           []string `json:"env"`
+	ConfigMapKeys []string `json:"configMapKeys"`
+	Sed           []string `json:"sed"`
+}
+
+// DefaultSpec is what an empty spec.redaction means.
+func DefaultSpec() Spec {
+	return Spec{Secrets: SecretsHash, Env: []string{DefaultPattern}, ConfigMapKeys: []string{DefaultPattern}}
+}
+
+// Object lists the fields redacted in one object.
+type Object struct {
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Fields    []string `json:"fields"`
+}
+
+type Summary struct {
+	Secrets       int      `json:"secrets"`
+	SecretKeys    int      `json:"secretKeys"`
+	Env           int      `json:"env"`
+	ConfigMapKeys int      `json:"configMapKeys"`
+	Sed           int      `json:"sed"`
+	Objects       []Object `json:"objects,omitempty"`
+}
+
+type Redactor struct {
+	spec          Spec
+	env           []*regexp.Regexp
+	configMapKeys []*regexp.Regexp
+	Summary       Summary
+}
+
+func New(spec Spec) (*Redactor, error) {
+	if spec.Secrets == "" {
+		spec.Secrets = SecretsHash
+	}
+	if spec.Secrets != SecretsHash && spec.Secrets != SecretsKeys && spec.Secrets != SecretsKeep {
+		return nil, fmt.Errorf("secrets must be one of %s, %s or %s", SecretsHash, SecretsKeys, SecretsKeep)
+	}
+	r := &Redactor{spec: spec}
+	var err error
+	if r.env, err = compile(spec.Env); err != nil {
+		return nil, fmt.Errorf("env: %w", err)
+	}
+	if r.configMapKeys, err = compile(spec.ConfigMapKeys); err != nil {
+		return nil, fmt.Errorf("configMapKeys: %w", err)
+	}
+	for _, k := range spec.Keep {
+		if _, err = path.Match(k, ""); err != nil {
+			return nil, fmt.Errorf("keep %s: %w", k, err)
+		}
+	}
+	for _, expr := range spec.Sed {
+		if _, err = util.Sed(expr, ""); err != nil {
+			return nil, fmt.Errorf("sed %s: %w", expr, err)
+		}
+	}
+	return r, nil
+}
+
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = re
+	}
+	return res, nil
+}
+
+func matches(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Item redacts one object in place: Secret data, matching ConfigMap keys and env vars of
+// any pod template, and the last applied configuration annotation which holds a copy of them.
+func (r *Redactor) Item(item map[string]any) {
+	kind, _ := item["kind"].(string)
+	r.redact(kind, item)
+}
+
+func (r *Redactor) redact(kind string, item map[string]any) {
+	meta, _ := item["metadata"].(map[string]any)
+	ns, _ := meta["namespace"].(string)
+	name, _ := meta["name"].(string)
+	if kind == "Secret" && r.kept(ns, name) {
+		return
+	}
+	fields := r.item(kind, item)
+	if annotations, ok := meta["annotations"].(map[string]any); ok {
+		if applied, ok := annotations[lastApplied].(string); ok {
+			var obj map[string]any
+			if kind == "Secret" && r.spec.Secrets != SecretsKeep {
+				annotations[lastApplied] = Mask
+				fields = append(fields, "metadata.annotations."+lastApplied)
+			} else if json.Unmarshal([]byte(applied), &obj) == nil {
+				// the copy holds the keys of the object, counted once already
+				counted := r.Summary
+				if len(r.item(kind, obj)) > 0 {
+					b, _ := json.Marshal(obj)
+					annotations[lastApplied] = string(b)
+					fields = append(fields, "metadata.annotations."+lastApplied)
+				}
+				r.Summary = counted
+			}
+		}
+	}
+	if len(fields) > 0 {
+		r.Summary.Objects = append(r.Summary.Objects, Object{Kind: kind, Namespace: ns, Name: name, Fields: fields})
+	}
+}
+
+func (r *Redactor) item(kind string, item map[string]any) []string {
+	fields := []string{}
+	switch kind {
+	case "Secret":
+		if r.spec.Secrets == SecretsKeep {
+			break
+		}
+		redacted := false
+		for _, field := range []string{"data", "stringData"} {
+			data, ok := item[field].(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, k := range sortedKeys(data) {
+				if am, ok := alertmanager(field, k, data[k]); ok {
+					data[k] = am
+				} else {
+					data[k] = r.secretValue(field, data[k])
+				}
+				fields = append(fields, field+"."+k)
+				r.Summary.SecretKeys++
+				redacted = true
+			}
+		}
+		if redacted {
+			r.Summary.Secrets++
+		}
+	case "ConfigMap":
+		for _, field := range []string{"data", "binaryData"} {
+			data, ok := item[field].(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, k := range sortedKeys(data) {
+				if matches(r.configMapKeys, k) {
+					data[k] = Mask
+					fields = append(fields, field+"."+k)
+					r.Summary.ConfigMapKeys++
+				}
+			}
+		}
+	}
+	return append(fields, r.maskEnv(item, "")...)
+}
+
+// secretValue hashes the decoded value of data or the value of stringData.
+func (r *Redactor) secretValue(field string, v any) any {
+	if r.spec.Secrets == SecretsKeys {
+		return ""
+	}
+	s, _ := v.(string)
+	b := []byte(s)
+	if field == "data" {
+		if decoded, err := base64.StdEncoding.DecodeString(s); err == nil {
+			b = decoded
+		}
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// alertmanager replaces the alertmanager configuration in v, the value of key in the Secret field,
+// by its structure: the receiver names and their *_configs keys with every entry masked, which is
+// what the checks read. Nothing else, credentials and urls included, is kept. It is false when v
+// is not an alertmanager configuration.
+func alertmanager(field string, key string, v any) (any, bool) {
+	if key != AlertmanagerKey {
+		return nil, false
+	}
+	s, _ := v.(string)
+	b := []byte(s)
+	if field == "data" {
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, false
+		}
+		b = decoded
+	}
+	config := map[string]any{}
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, false
+	}
+	list, _ := config["receivers"].([]any)
+	receivers := []any{}
+	for _, e := range list {
+		receiver, _ := e.(map[string]any)
+		summary := map[string]any{"name": receiver["name"]}
+		for k, configs := range receiver {
+			if entries, ok := configs.([]any); ok && strings.HasSuffix(k, "_configs") {
+				masked := make([]any, len(entries))
+				for i := range entries {
+					masked[i] = Mask
+				}
+				summary[k] = masked
+			}
+		}
+		receivers = append(receivers, summary)
+	}
+	out, err := util.ToYaml(map[string]any{"receivers": receivers})
+	if err != nil {
+		return nil, false
+	}
+	if field == "data" {
+		return base64.StdEncoding.EncodeToString(out), true
+	}
+	return string(out), true
+}
+
+// maskEnv walks v masking the value of every env entry whose name matches.
+func (r *Redactor) maskEnv(v any, at string) []string {
+	fields := []string{}
+	switch t := v.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(t) {
+			p := strings.TrimPrefix(at+"."+k, ".")
+			if list, ok := t[k].([]any); ok && k == "env" {
+				for _, e := range list {
+					entry, ok := e.(map[string]any)
+					name, _ := entry["name"].(string)
+					if _, hasValue := entry["value"]; ok && hasValue && matches(r.env, name) {
+						entry["value"] = Mask
+						fields = append(fields, p+"."+name)
+						r.Summary.Env++
+					}
+				}
+				continue
+			}
+			fields = append(fields, r.maskEnv(t[k], p)...)
+		}
+	case []any:
+		for i, e := range t {
+			fields = append(fields, r.maskEnv(e, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	}
+	return fields
+}
+
+func (r *Redactor) kept(ns string, name string) bool {
+	for _, k := range r.spec.Keep {
+		if ok, _ := path.Match(k, ns+"/"+name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// File redacts a kcdump file (json or yaml, gziped or not) document by document into dst.
+func (r *Redactor) File(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err = r.stream(in, out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// stream redacts the documents read from in, the content of the file name, into out. The gzip
+// writer is closed before returning since closing it writes the end of the stream.
+func (r *Redactor) stream(in io.Reader, out io.Writer, name string) error {
+	if !strings.HasSuffix(name, ".gz") {
+		return r.docs(in, out, name)
+	}
+	gzr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+	gzw := gzip.NewWriter(out)
+	if err = r.docs(gzr, gzw, strings.TrimSuffix(name, ".gz")); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+// docs redacts the json or yaml documents of reader, as told by name, into writer.
+func (r *Redactor) docs(reader io.Reader, writer io.Writer, name string) error {
+	isJson := strings.HasSuffix(name, ".json")
+	var next func(v any) error
+	if isJson {
+		next = json.NewDecoder(reader).Decode
+	} else {
+		next = yaml.NewDecoder(reader).Decode
+	}
+	for first := true; ; first = false {
+		var doc map[string]any
+		if err := next(&doc); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		r.Doc(doc)
+		var b []byte
+		var err error
+		if isJson {
+			if b, err = json.Marshal(doc); err == nil {
+				b = append(b, '\n')
+			}
+		} else if b, err = util.ToYaml(doc); err == nil && !first {
+			b = append([]byte("---\n"), b...)
+		}
+		if err != nil {
+			return err
+		}
+		if b, err = r.sed(b); err != nil {
+			return err
+		}
+		if _, err = writer.Write(b); err != nil {
+			return err
+		}
+	}
+}
+
+// Doc redacts a kcdump document, a List of items or a single object.
+func (r *Redactor) Doc(doc map[string]any) {
+	items, ok := doc["items"].([]any)
+	if !ok {
+		r.Item(doc)
+		return
+	}
+	listKind, _ := doc["kind"].(string)
+	for _, i := range items {
+		if item, ok := i.(map[string]any); ok {
+			// kcdump lists may leave the kind of the items out
+			kind, _ := item["kind"].(string)
+			if kind == "" {
+				kind = strings.TrimSuffix(listKind, "List")
+			}
+			r.redact(kind, item)
+		}
+	}
+}
+
+// Object returns a redacted copy of obj, an object read from the cluster rather than the dump,
+// with the sed expressions run over its json.
+func (r *Redactor) Object(obj map[string]any) (map[string]any, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	c := map[string]any{}
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	r.Item(c)
+	if b, err = json.Marshal(c); err != nil {
+		return nil, err
+	}
+	if b, err = r.sed(b); err != nil {
+		return nil, err
+	}
+	c = map[string]any{}
+	return c, json.Unmarshal(b, &c)
+}
+
+func (r *Redactor) sed(b []byte) ([]byte, error) {
+	if len(r.spec.Sed) == 0 {
+		return b, nil
+	}
+	s := string(b)
+	for _, expr := range r.spec.Sed {
+		out, err := util.Sed(expr, s)
+		if err != nil {
+			return nil, fmt.Errorf("sed %s: %w", expr, err)
+		}
+		s = out
+	}
+	if s != string(b) {
+		r.Summary.Sed++
+	}
+	return []byte(s), nil
+}
+
+// Dir redacts every dump file under src into the same layout under dst. Other files are copied.
+func (r *Redactor) Dir(src string, dst string) error {
+	if _, err := os.Stat(src); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	err := filepath.WalkDir(src, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if e.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if isDumpFile(p) {
+			if err = r.File(p, target); err != nil {
+				return fmt.Errorf("%s: %w", rel, err)
+			}
+			return nil
+		}
+		return copyFile(p, target)
+	})
+	logger.Info("dump redacted", zap.String("path", dst), zap.Int("secrets", r.Summary.Secrets),
+		zap.Int("env", r.Summary.Env), zap.Int("configMapKeys", r.Summary.ConfigMapKeys), zap.Int("sed", r.Summary.Sed))
+	return err
+}
+
+func isDumpFile(p string) bool {
+	p = strings.TrimSuffix(p, ".gz")
+	return strings.HasSuffix(p, ".json") || strings.HasSuffix(p, ".yaml") || strings.HasSuffix(p, ".yml")
+}
+
+func copyFile(src string, dst string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, 0600)
+}
@@ -0,0 +1,197 @@
+package redact
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// sha256 of "secret"
+const secretSum = "sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+
+// failingWriter fails every write like a full disk.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// object decodes a json object.
+func object(j string) map[string]any {
+	m := map[string]any{}
+	Expect(json.Unmarshal([]byte(j), &m)).To(Succeed())
+	return m
+}
+
+var _ = Describe("Redact", func() {
+	DescribeTable("specs",
+		func(spec Spec, expected string) {
+			_, err := New(spec)
+			if expected == "" {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(ContainSubstring(expected)))
+			}
+		},
+		Entry("the default one", DefaultSpec(), ""),
+		Entry("an unknown secrets mode", Spec{Secrets: "drop"}, "secrets must be one of"),
+		Entry("a bad env pattern", Spec{Env: []string{"("}}, "env"),
+		Entry("a bad configMapKeys pattern", Spec{ConfigMapKeys: []string{"["}}, "configMapKeys"),
+		Entry("a bad keep pattern", Spec{Keep: []string{"ns/["}}, "keep ns/["),
+		Entry("a bad sed expression", Spec{Sed: []string{"s/a"}}, "sed s/a"),
+	)
+
+	DescribeTable("objects",
+		func(spec Spec, item string, expected string, fields []string) {
+			r, err := New(spec)
+			Expect(err).NotTo(HaveOccurred())
+			obj := object(item)
+			r.Item(obj)
+			Expect(obj).To(Equal(object(expected)))
+			if fields == nil {
+				Expect(r.Summary.Objects).To(BeEmpty())
+			} else {
+				Expect(r.Summary.Objects).To(HaveLen(1))
+				Expect(r.Summary.Objects[0].Fields).To(Equal(fields))
+			}
+		},
+		Entry("hash the decoded Secret data", DefaultSpec(),
+			`{"kind":"Secret","metadata":{"namespace":"a","name":"s"},"data":{"k":"c2VjcmV0"},"stringData":{"p":"secret"}}`,
+			`{"kind":"Secret","metadata":{"namespace":"a","name":"s"},"data":{"k":"`+secretSum+`"},"stringData":{"p":"`+secretSum+`"}}`,
+			[]string{"data.k", "stringData.p"}),
+		Entry("drop the Secret values with keys", Spec{Secrets: SecretsKeys},
+			`{"kind":"Secret","metadata":{"namespace":"a","name":"s"},"data":{"k":"c2VjcmV0"}}`,
+			`{"kind":"Secret","metadata":{"namespace":"a","name":"s"},"data":{"k":""}}`,
+			[]string{"data.k"}),
+		Entry("leave Secrets with keep", Spec{Secrets: SecretsKeep},
+			`{"kind":"Secret","metadata":{"namespace":"a","name":"s"},"data":{"k":"c2VjcmV0"}}`,
+			`{"kind":"Secret","metadata":{"namespace":"a","name":"s"},"data":{"k":"c2VjcmV0"}}`,
+			nil),
+		Entry("leave the Secrets kept", Spec{Keep: []string{"app/*"}},
+			`{"kind":"Secret","metadata":{"namespace":"app","name":"s"},"data":{"k":"c2VjcmV0"}}`,
+			`{"kind":"Secret","metadata":{"namespace":"app","name":"s"},"data":{"k":"c2VjcmV0"}}`,
+			nil),
+		Entry("redact the alertmanager-main Secret by default", DefaultSpec(),
+			`{"kind":"Secret","metadata":{"namespace":"openshift-monitoring","name":"alertmanager-main"},"data":{"k":"c2VjcmV0"}}`,
+			`{"kind":"Secret","metadata":{"namespace":"openshift-monitoring","name":"alertmanager-main"},"data":{"k":"`+secretSum+`"}}`,
+			[]string{"data.k"}),
+		Entry("keep only the receivers structure of the alertmanager configuration", DefaultSpec(),
+			`{"kind":"Secret","metadata":{"name":"s"},"stringData":{"alertmanager.yaml":"global: {smtp_auth_password: secret}\nreceivers:\n- name: ops\n  slack_configs: [{api_url: 'https://hooks.slack.com/secret'}]\n  send_resolved: true\n- name: none\n"}}`,
+			`{"kind":"Secret","metadata":{"name":"s"},"stringData":{"alertmanager.yaml":"receivers:\n  - name: ops\n    slack_configs:\n      - '**redacted**'\n  - name: none\n"}}`,
+			[]string{"stringData.alertmanager.yaml"}),
+		Entry("hash an alertmanager configuration that does not parse", DefaultSpec(),
+			`{"kind":"Secret","metadata":{"name":"s"},"stringData":{"alertmanager.yaml":"secret"}}`,
+			`{"kind":"Secret","metadata":{"name":"s"},"stringData":{"alertmanager.yaml":"`+secretSum+`"}}`,
+			[]string{"stringData.alertmanager.yaml"}),
+		Entry("mask the matching ConfigMap keys", DefaultSpec(),
+			`{"kind":"ConfigMap","metadata":{"name":"c"},"data":{"db_password":"x","level":"debug"}}`,
+			`{"kind":"ConfigMap","metadata":{"name":"c"},"data":{"db_password":"**redacted**","level":"debug"}}`,
+			[]string{"data.db_password"}),
+		Entry("mask the matching env values of pod templates", DefaultSpec(),
+			`{"kind":"Deployment","metadata":{"name":"d"},"spec":{"template":{"spec":{"containers":[{"env":[{"name":"API_TOKEN","value":"x"},{"name":"LEVEL","value":"debug"},{"name":"AUTH","valueFrom":{"secretKeyRef":{"name":"s"}}}]}]}}}}`,
+			`{"kind":"Deployment","metadata":{"name":"d"},"spec":{"template":{"spec":{"containers":[{"env":[{"name":"API_TOKEN","value":"**redacted**"},{"name":"LEVEL","value":"debug"},{"name":"AUTH","valueFrom":{"secretKeyRef":{"name":"s"}}}]}]}}}}`,
+			[]string{"spec.template.spec.containers[0].env.API_TOKEN"}),
+		Entry("redact the copy in the last applied configuration", DefaultSpec(),
+			`{"kind":"ConfigMap","metadata":{"name":"c","annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"data\":{\"token\":\"x\"}}"}},"data":{"token":"x"}}`,
+			`{"kind":"ConfigMap","metadata":{"name":"c","annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"data\":{\"token\":\"**redacted**\"}}"}},"data":{"token":"**redacted**"}}`,
+			[]string{"data.token", "metadata.annotations.kubectl.kubernetes.io/last-applied-configuration"}),
+	)
+
+	It("summarizes the base64 alertmanager configuration of Secret data", func() {
+		r, err := New(DefaultSpec())
+		Expect(err).NotTo(HaveOccurred())
+		config := "receivers:\n- name: ops\n  webhook_configs:\n  - url: https://alerts.example.com/token\n  - url: https://other.example.com\n"
+		obj := object(`{"kind":"Secret","metadata":{"namespace":"openshift-monitoring","name":"alertmanager-main"},"data":{"alertmanager.yaml":"` +
+			base64.StdEncoding.EncodeToString([]byte(config)) + `"}}`)
+		r.Item(obj)
+		b, err := base64.StdEncoding.DecodeString(obj["data"].(map[string]any)["alertmanager.yaml"].(string))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("receivers:\n  - name: ops\n    webhook_configs:\n      - '**redacted**'\n      - '**redacted**'\n"))
+	})
+
+	It("counts the keys of the last applied configuration once", func() {
+		r, err := New(DefaultSpec())
+		Expect(err).NotTo(HaveOccurred())
+		r.Item(object(`{"kind":"ConfigMap","metadata":{"name":"c","annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"data\":{\"token\":\"x\"},\"spec\":{\"env\":[{\"name\":\"TOKEN\",\"value\":\"x\"}]}}"}},` +
+			`"data":{"token":"x"},"spec":{"env":[{"name":"TOKEN","value":"x"}]}}`))
+		Expect(r.Summary.ConfigMapKeys).To(Equal(1))
+		Expect(r.Summary.Env).To(Equal(1))
+		Expect(r.Summary.Objects).To(HaveLen(1))
+	})
+
+	It("redacts the items of lists, which may leave their kind out", func() {
+		r, err := New(DefaultSpec())
+		Expect(err).NotTo(HaveOccurred())
+		doc := object(`{"kind":"SecretList","items":[{"metadata":{"name":"s"},"data":{"k":"c2VjcmV0"}}]}`)
+		r.Doc(doc)
+		Expect(doc["items"].([]any)[0].(map[string]any)["data"]).To(Equal(map[string]any{"k": secretSum}))
+		Expect(r.Summary.Secrets).To(Equal(1))
+		Expect(r.Summary.SecretKeys).To(Equal(1))
+	})
+
+	It("redacts a copy of an object running the sed expressions", func() {
+		r, err := New(Spec{Env: []string{DefaultPattern}, ConfigMapKeys: []string{DefaultPattern}, Sed: []string{"s/internal\\.corp/host/g"}})
+		Expect(err).NotTo(HaveOccurred())
+		obj := object(`{"kind":"ConfigMap","metadata":{"name":"c"},"data":{"token":"t","url":"http://a.internal.corp"}}`)
+		c, err := r.Object(obj)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c).To(Equal(object(`{"kind":"ConfigMap","metadata":{"name":"c"},"data":{"token":"` + Mask + `","url":"http://a.host"}}`)))
+		Expect(obj["data"]).To(HaveKeyWithValue("token", "t"))
+	})
+
+	It("fails when the end of a gziped file cannot be written", func() {
+		var gz bytes.Buffer
+		w := gzip.NewWriter(&gz)
+		_, err := w.Write([]byte("apiVersion: v1\nkind: ConfigMapList\nitems: []\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		r, err := New(DefaultSpec())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.stream(&gz, failingWriter{}, "configmaps.yaml.gz")).To(MatchError("disk full"))
+	})
+
+	It("redacts a dump directory file by file running the sed expressions", func() {
+		src, dst := GinkgoT().TempDir(), GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(src, "configmaps.yaml"),
+			[]byte("kind: ConfigMapList\nitems:\n- metadata:\n    name: c\n  data:\n    host: db.corp.example.com\n    secret: x\n"), 0644)).To(Succeed())
+		f, err := os.Create(filepath.Join(src, "secrets.json.gz"))
+		Expect(err).NotTo(HaveOccurred())
+		gz := gzip.NewWriter(f)
+		_, err = gz.Write([]byte(`{"kind":"SecretList","items":[{"metadata":{"name":"s"},"data":{"k":"c2VjcmV0"}}]}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gz.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(src, "README"), []byte("db.corp.example.com"), 0644)).To(Succeed())
+
+		spec := DefaultSpec()
+		spec.Sed = []string{`s/[a-z0-9.-]*\.corp\.example\.com/**redacted**/g`}
+		r, err := New(spec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Dir(src, dst)).To(Succeed())
+
+		b, err := os.ReadFile(filepath.Join(dst, "configmaps.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).NotTo(ContainSubstring("corp.example.com"))
+		Expect(string(b)).To(ContainSubstring("secret: '**redacted**'"))
+		f, err = os.Open(filepath.Join(dst, "secrets.json.gz"))
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+		gzr, err := gzip.NewReader(f)
+		Expect(err).NotTo(HaveOccurred())
+		b, err = io.ReadAll(gzr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring(secretSum))
+		b, err = os.ReadFile(filepath.Join(dst, "README"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("db.corp.example.com"), "files other than dumps are copied")
+		Expect(r.Summary.Sed).To(Equal(1))
+	})
+})
@@ -0,0 +1,347 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mauricioscastro/kcdump/pkg/yjq"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"adoption.latam/hcr/internal/pkg/dump"
+	"adoption.latam/hcr/internal/pkg/history"
+	"adoption.latam/hcr/internal/pkg/i18n"
+)
+
+// Funcs are the helpers available to every template. t, num, date, datetime, table and trend
+// are in english here and replaced by LocaleFuncs for the language of the report.
+func Funcs() template.FuncMap {
+	funcs := template.FuncMap{
+		"jq":        jq,
+		"toJson":    toJson,
+		"toYaml":    toYaml,
+		"default":   defaultValue,
+		"join":      join,
+		"list":      list,
+		"upper":     strings.ToUpper,
+		"lower":     strings.ToLower,
+		"title":     title,
+		"repeat":    strings.Repeat,
+		"quantity":  quantity,
+		"cores":     cores,
+		"bytes":     bytesOf,
+		"sumQty":    sumQuantities,
+		"humanSize": humanSize,
+		"percent":   percent,
+		"duration":  duration,
+		"since":     since,
+		"age":       age,
+		"humanDur":  humanDuration,
+		"escape":    escape,
+		"indent":    indent,
+	}
+	for k, f := range LocaleFuncs(i18n.MustNew(i18n.Default)) {
+		funcs[k] = f
+	}
+	return funcs
+}
+
+// LocaleFuncs are the helpers whose output depends on the language.
+// t translates a string (a format when given arguments) with the catalog of l.
+func LocaleFuncs(l *i18n.Localizer) template.FuncMap {
+	return template.FuncMap{
+		"t":        l.T,
+		"num":      l.Number,
+		"date":     l.Date,
+		"datetime": l.DateTime,
+		"table": func(rows any, columns ...string) (string, error) {
+			return table(l, rows, columns...)
+		},
+		"trend": func(points []history.Point, chart string) (string, error) {
+			return trend(l, points, chart)
+		},
+	}
+}
+
+// table renders rows (a list of maps or structs) as a markdown table. Columns are keys
+// or dotted paths into each row optionally prefixed by a header: "Namespace=metadata.namespace".
+// Headers are translated.
+func table(l *i18n.Localizer, rows any, columns ...string) (string, error) {
+	list, ok := dump.Normalize(rows).([]any)
+	if !ok {
+		return "", fmt.Errorf("table: expected a list but got %T", rows)
+	}
+	headers := make([]string, len(columns))
+	paths := make([]string, len(columns))
+	for i, c := range columns {
+		if h, p, found := strings.Cut(c, "="); found {
+			headers[i], paths[i] = l.T(h), p
+		} else {
+			headers[i], paths[i] = l.T(title(c)), c
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("| " + strings.Join(headers, " | ") + " |\n")
+	sb.WriteString("|" + strings.Repeat(" --- |", len(columns)) + "\n")
+	for _, row := range list {
+		cells := make([]string, len(paths))
+		for i, p := range paths {
+			cells[i] = escape(lookup(row, p))
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return sb.String(), nil
+}
+
+func lookup(v any, path string) any {
+	for _, k := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+// escape makes any value safe to be placed inside a markdown table cell.
+func escape(v any) string {
+	var s string
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		s = t
+	case map[string]any, []any:
+		b, _ := json.Marshal(t)
+		s = string(b)
+	default:
+		s = fmt.Sprint(t)
+	}
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(strings.TrimSpace(s), "\n", "<br>")
+}
+
+// jq evaluates expr over data. A single result is returned as is, many as a list.
+func jq(expr string, data any) (any, error) {
+	in, err := json.Marshal(dump.Normalize(data))
+	if err != nil {
+		return nil, err
+	}
+	// yjq formats the expression before parsing it
+	out, err := yjq.JqEval("["+strings.ReplaceAll(expr, "%", "%%")+"]", string(in))
+	if err != nil {
+		return nil, err
+	}
+	var res []any
+	if err = json.Unmarshal([]byte(out), &res); err != nil {
+		return nil, err
+	}
+	if len(res) == 1 {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+func toJson(v any) (string, error) {
+	b, err := json.Marshal(dump.Normalize(v))
+	return string(b), err
+}
+
+func toYaml(v any) (string, error) {
+	b, err := yaml.Marshal(dump.Normalize(v))
+	return strings.TrimSuffix(string(b), "\n"), err
+}
+
+func defaultValue(d any, v any) any {
+	if v == nil || v == "" {
+		return d
+	}
+	return v
+}
+
+func join(sep string, v any) string {
+	list, ok := dump.Normalize(v).([]any)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	s := make([]string, len(list))
+	for i, e := range list {
+		s[i] = fmt.Sprint(e)
+	}
+	return strings.Join(s, sep)
+}
+
+// indent prefixes every non empty line with n spaces. ex: tables inside admonitions
+func indent(n int, s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = strings.Repeat(" ", n) + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func list(v ...any) []any {
+	return v
+}
+
+func title(s string) string {
+	if s == "" {
+		return s
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+func toQuantity(v any) (resource.Quantity, error) {
+	switch t := v.(type) {
+	case resource.Quantity:
+		return t, nil
+	case string:
+		return resource.ParseQuantity(t)
+	case nil:
+		return resource.Quantity{}, nil
+	default:
+		return resource.ParseQuantity(fmt.Sprint(t))
+	}
+}
+
+// quantity parses a kubernetes quantity like 500m, 2Gi or 1.5 and returns it in canonical form.
+func quantity(v any) (string, error) {
+	q, err := toQuantity(v)
+	if err != nil {
+		return "", err
+	}
+	return q.String(), nil
+}
+
+// cores is a cpu quantity as a number of cores.
+func cores(v any) (float64, error) {
+	q, err := toQuantity(v)
+	if err != nil {
+		return 0, err
+	}
+	return float64(q.MilliValue()) / 1000, nil
+}
+
+// bytesOf is a memory or storage quantity as a number of bytes.
+func bytesOf(v any) (int64, error) {
+	q, err := toQuantity(v)
+	if err != nil {
+		return 0, err
+	}
+	return q.Value(), nil
+}
+
+func sumQuantities(v any) (string, error) {
+	list, ok := dump.Normalize(v).([]any)
+	if !ok {
+		return "", fmt.Errorf("sumQty: expected a list but got %T", v)
+	}
+	sum := resource.Quantity{}
+	for _, e := range list {
+		q, err := toQuantity(e)
+		if err != nil {
+			return "", err
+		}
+		sum.Add(q)
+	}
+	return sum.String(), nil
+}
+
+// humanSize prints a number of bytes or a quantity using binary units. ex: 1.5Gi
+func humanSize(v any) (string, error) {
+	var b float64
+	switch t := v.(type) {
+	case int:
+		b = float64(t)
+	case int64:
+		b = float64(t)
+	case float64:
+		b = t
+	default:
+		q, err := toQuantity(v)
+		if err != nil {
+			return "", err
+		}
+		b = float64(q.Value())
+	}
+	units := []string{"", "Ki", "Mi", "Gi", "Ti", "Pi"}
+	i := 0
+	for ; math.Abs(b) >= 1024 && i < len(units)-1; i++ {
+		b /= 1024
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(fmt.Sprintf("%.1f", b), "0"), ".") + units[i], nil
+}
+
+func percent(part any, total any) (string, error) {
+	p, err := cores(part)
+	if err != nil {
+		return "", err
+	}
+	t, err := cores(total)
+	if err != nil {
+		return "", err
+	}
+	if t == 0 {
+		return "-", nil
+	}
+	return fmt.Sprintf("%.1f%%", p/t*100), nil
+}
+
+// duration parses go durations (1h30m) and also accepts days (7d) as found in retention settings.
+func duration(s string) (time.Duration, error) {
+	if d, found := strings.CutSuffix(s, "d"); found {
+		if days, err := time.ParseDuration(d + "h"); err == nil {
+			return days * 24, nil
+		}
+	}
+	if w, found := strings.CutSuffix(s, "w"); found {
+		if weeks, err := time.ParseDuration(w + "h"); err == nil {
+			return weeks * 24 * 7, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// since is the duration elapsed from a RFC3339 timestamp.
+func since(ts string) (time.Duration, error) {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return 0, err
+	}
+	return time.Since(t), nil
+}
+
+// age is the kubectl like age of a RFC3339 timestamp. ex: 3d4h
+func age(ts string) (string, error) {
+	d, err := since(ts)
+	if err != nil {
+		return "", err
+	}
+	return humanDuration(d), nil
+}
+
+func humanDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	switch {
+	case days > 0 && hours > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case days > 0:
+		return fmt.Sprintf("%dd", days)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
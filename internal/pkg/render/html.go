@@ -0,0 +1,160 @@
+package render
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"os"
+	"sort"
+	"strings"
+
+	"adoption.latam/hcr/img"
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/compliance"
+	"adoption.latam/hcr/internal/pkg/diff"
+	"adoption.latam/hcr/internal/pkg/export"
+	"adoption.latam/hcr/internal/pkg/i18n"
+	"adoption.latam/hcr/internal/pkg/redact"
+	"adoption.latam/hcr/internal/pkg/score"
+	"adoption.latam/hcr/internal/pkg/waiver"
+)
+
+type severityCount struct {
+	Name  string
+	Count int
+}
+
+type htmlRow struct {
+	check.Finding
+	Remediation string
+	Evidence    string
+}
+
+type htmlChange struct {
+	check.Finding
+	Change string
+}
+
+type htmlPage struct {
+	Run        Run
+	Name       string
+	Authors    string
+	Language   string
+	CSS        template.CSS
+	Logo       template.URL
+	Results    []check.Result
+	Findings   []check.Finding
+	Severities []severityCount
+	Categories []string
+	Namespaces []string
+	Rows       []htmlRow
+	Diff       *diff.Findings
+	Changes    []htmlChange
+	Trends     []template.HTML
+	Score      *score.Card
+	Waived     []waiver.Waived
+	Expired    []waiver.Waiver
+	Baseline   *export.Baseline
+	Compliance []compliance.Framework
+	Redaction  *redact.Summary
+	Data       any
+}
+
+// WriteHtml writes a single self contained html file with every style, image and finding
+// embedded so it can be sent around and opened without docbase or MkDocs.
+func WriteHtml(file string, ctx Context, site Site) error {
+	tmpl, err := templatesFS.ReadFile("templates/html/report.html.tmpl")
+	if err != nil {
+		return err
+	}
+	l, err := i18n.New(ctx.Language)
+	if err != nil {
+		return err
+	}
+	funcs := template.FuncMap{"t": l.T, "num": l.Number, "date": l.Date, "datetime": l.DateTime}
+	t, err := template.New("report.html").Funcs(funcs).Parse(string(tmpl))
+	if err != nil {
+		return err
+	}
+	css, err := templatesFS.ReadFile("templates/assets/hcr.css")
+	if err != nil {
+		return err
+	}
+	page := htmlPage{
+		Run:        ctx.Run,
+		Name:       site.Name,
+		Authors:    strings.Join(site.Authors, ", "),
+		Language:   site.Language,
+		CSS:        template.CSS(css),
+		Logo:       template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(img.Logo)),
+		Results:    ctx.Results,
+		Findings:   ctx.Findings,
+		Categories: Categories(ctx.Findings),
+		Diff:       ctx.Diff,
+		Score:      ctx.Score,
+		Waived:     ctx.Waived,
+		Expired:    ctx.Expired,
+		Baseline:   ctx.Baseline,
+		Compliance: ctx.Compliance,
+		Redaction:  ctx.Redaction,
+		Data: map[string]any{"run": ctx.Run, "findings": ctx.Findings, "results": ctx.Results, "diff": ctx.Diff, "score": ctx.Score,
+			"waived": ctx.Waived, "baseline": ctx.Baseline, "compliance": ctx.Compliance},
+	}
+	if ctx.Diff != nil {
+		for _, f := range ctx.Diff.New {
+			page.Changes = append(page.Changes, htmlChange{f, "new"})
+		}
+		for _, f := range ctx.Diff.Resolved {
+			page.Changes = append(page.Changes, htmlChange{f, "resolved"})
+		}
+	}
+	if len(ctx.History) > 1 {
+		for _, chart := range []string{"findings", "score", "inventory", "usage"} {
+			svg, err := trend(l, ctx.History, chart)
+			if err != nil {
+				return err
+			}
+			// generated by lineChart with every text escaped
+			page.Trends = append(page.Trends, template.HTML(svg))
+		}
+	}
+	if page.Name == "" {
+		page.Name = l.T("Health Check Report")
+	}
+	if page.Language == "" {
+		page.Language = l.Lang
+	}
+	counts := check.CountBySeverity(ctx.Findings)
+	for _, s := range check.Severities {
+		page.Severities = append(page.Severities, severityCount{s, counts[s]})
+	}
+	remediation := map[string]string{}
+	for _, r := range ctx.Results {
+		remediation[r.Check.Id] = r.Check.Remediation
+	}
+	namespaces := map[string]bool{}
+	for _, f := range ctx.Findings {
+		row := htmlRow{Finding: f, Remediation: remediation[f.CheckId]}
+		if f.Evidence != nil {
+			b, _ := json.MarshalIndent(f.Evidence, "", "  ")
+			row.Evidence = string(b)
+		}
+		page.Rows = append(page.Rows, row)
+		if f.Namespace != "" {
+			namespaces[f.Namespace] = true
+		}
+	}
+	for ns := range namespaces {
+		page.Namespaces = append(page.Namespaces, ns)
+	}
+	sort.Strings(page.Namespaces)
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err = t.Execute(out, page); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
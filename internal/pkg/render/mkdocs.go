@@ -0,0 +1,198 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"adoption.latam/hcr/img"
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/i18n"
+	"adoption.latam/hcr/internal/pkg/util"
+)
+
+const (
+	DocsDir   = "docs"
+	AssetsDir = "assets"
+	logoFile  = "hcreport.png"
+)
+
+// Site is the MkDocs part of the spec (spec.report.site). Theme is merged over the default Material theme.
+type Site struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Authors     []string       `json:"authors"`
+	Language    string         `json:"language"`
+	Theme       map[string]any `json:"theme"`
+}
+
+// FrontMatter is the optional yaml header of a page. MkDocs strips it from the output.
+type FrontMatter struct {
+	Title  string `yaml:"title"`
+	Weight int    `yaml:"weight"`
+}
+
+type navNode struct {
+	title    string
+	file     string
+	weight   int
+	children map[string]*navNode
+}
+
+// WriteMkDocs writes path/mkdocs.yml with a navigation tree built from the pages rendered
+// under path/docs and the stylesheet docbase needs to build and serve the site.
+func WriteMkDocs(path string, pages []string, site Site) error {
+	if err := writeAssets(filepath.Join(path, DocsDir, AssetsDir)); err != nil {
+		return err
+	}
+	// site.Language may be any language the theme knows, directories are only translated for the ones with a catalog
+	l, err := i18n.New(site.Language)
+	if err != nil {
+		l = i18n.MustNew(i18n.Default)
+	}
+	root := &navNode{children: map[string]*navNode{}}
+	for _, p := range pages {
+		fm := readFrontMatter(filepath.Join(path, DocsDir, p))
+		node := root
+		dirs := strings.Split(filepath.Dir(p), "/")
+		for _, d := range dirs {
+			if d == "." {
+				continue
+			}
+			if _, ok := node.children[d]; !ok {
+				node.children[d] = &navNode{title: l.T(title(d)), weight: 1000, children: map[string]*navNode{}}
+			}
+			node = node.children[d]
+		}
+		n := &navNode{title: fm.Title, file: p, weight: fm.Weight}
+		if n.title == "" {
+			n.title = title(strings.TrimSuffix(filepath.Base(p), filepath.Ext(p)))
+		}
+		if filepath.Base(p) == "index.md" {
+			n.weight = -1
+		}
+		node.children[p] = n
+		if node != root && n.weight < node.weight {
+			node.weight = n.weight
+		}
+	}
+	name := site.Name
+	if name == "" {
+		name = l.T("Health Check Report")
+	}
+	language := site.Language
+	if language == "" {
+		language = "en"
+	}
+	theme := map[string]any{
+		"name":     "material",
+		"language": language,
+		"logo":     AssetsDir + "/" + logoFile,
+		"features": []string{"navigation.sections", "navigation.indexes", "navigation.top", "search.highlight", "content.code.copy"},
+		"palette":  map[string]any{"scheme": "default", "primary": "red", "accent": "red"},
+	}
+	for k, v := range site.Theme {
+		theme[k] = v
+	}
+	mkdocs := yaml.Node{Kind: yaml.MappingNode}
+	add := func(k string, v any) {
+		vn := yaml.Node{}
+		_ = vn.Encode(v)
+		mkdocs.Content = append(mkdocs.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k}, &vn)
+	}
+	add("site_name", name)
+	if site.Description != "" {
+		add("site_description", site.Description)
+	}
+	if len(site.Authors) > 0 {
+		add("site_author", strings.Join(site.Authors, ", "))
+		add("copyright", strings.Join(site.Authors, ", "))
+	}
+	add("docs_dir", DocsDir)
+	add("site_dir", "html")
+	add("use_directory_urls", false)
+	add("theme", theme)
+	// the search plugin only knows base languages (pt for pt-BR)
+	searchLang, _, _ := strings.Cut(language, "-")
+	add("plugins", []any{map[string]any{"search": map[string]any{"lang": searchLang}}})
+	add("markdown_extensions", []any{
+		"tables", "admonition", "attr_list", "md_in_html", "meta",
+		map[string]any{"toc": map[string]any{"permalink": true}},
+		"pymdownx.details", "pymdownx.superfences",
+	})
+	add("extra_css", []string{AssetsDir + "/hcr.css"})
+	add("extra", map[string]any{"generator": false})
+	add("nav", root.nav())
+	return util.WriteYaml(filepath.Join(path, "mkdocs.yml"), &mkdocs)
+}
+
+func (n *navNode) nav() []any {
+	children := make([]*navNode, 0, len(n.children))
+	for _, c := range n.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].weight != children[j].weight {
+			return children[i].weight < children[j].weight
+		}
+		return children[i].title < children[j].title
+	})
+	nav := make([]any, 0, len(children))
+	for _, c := range children {
+		if c.file != "" {
+			nav = append(nav, map[string]string{c.title: c.file})
+		} else {
+			nav = append(nav, map[string]any{c.title: c.nav()})
+		}
+	}
+	return nav
+}
+
+func readFrontMatter(file string) FrontMatter {
+	fm := FrontMatter{}
+	f, err := crypt.Open(file)
+	if err != nil {
+		return fm
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	if !s.Scan() || strings.TrimSpace(s.Text()) != "---" {
+		return fm
+	}
+	var header bytes.Buffer
+	for s.Scan() && strings.TrimSpace(s.Text()) != "---" {
+		header.WriteString(s.Text() + "\n")
+	}
+	if err = yaml.Unmarshal(header.Bytes(), &fm); err != nil {
+		logger.Warn("bad front matter in " + file)
+	}
+	return fm
+}
+
+func writeAssets(path string) error {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	assets, err := templatesFS.ReadDir("templates/assets")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(path, logoFile), img.Logo, 0644); err != nil {
+		return err
+	}
+	for _, a := range assets {
+		b, err := templatesFS.ReadFile("templates/assets/" + a.Name())
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(filepath.Join(path, a.Name()), b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
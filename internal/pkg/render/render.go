@@ -0,0 +1,224 @@
+package render
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/compliance"
+	"adoption.latam/hcr/internal/pkg/crypt"
+	"adoption.latam/hcr/internal/pkg/diff"
+	"adoption.latam/hcr/internal/pkg/dump"
+	"adoption.latam/hcr/internal/pkg/export"
+	"adoption.latam/hcr/internal/pkg/history"
+	"adoption.latam/hcr/internal/pkg/i18n"
+	"adoption.latam/hcr/internal/pkg/redact"
+	"adoption.latam/hcr/internal/pkg/score"
+	"adoption.latam/hcr/internal/pkg/util/log"
+	"adoption.latam/hcr/internal/pkg/waiver"
+)
+
+const (
+	templateExt = ".tmpl"
+	// a double underscore in a template name (a ConfigMap key can not hold a '/') is a directory separator.
+	dirSep = "__"
+	// a template name holding this placeholder is rendered once per finding category.
+	// ex: 'categories___category_.md.tmpl' renders to categories/observability.md
+	categoryPlaceholder = "_category_"
+)
+
+var (
+	logger = log.Logger().Named("hcr.render")
+	//go:embed templates/*.tmpl templates/assets templates/html
+	templatesFS embed.FS
+)
+
+type Run struct {
+	Id   string `json:"id"`
+	Date string `json:"date"`
+}
+
+type Page struct {
+	Path     string `json:"path"`
+	Category string `json:"category,omitempty"`
+}
+
+// Context is the dot handed to every template. Language selects the catalog of the
+// locale funcs, results and findings are expected to be localized already.
+type Context struct {
+	Run        Run
+	Language   string
+	Spec       any
+	Data       map[string]any
+	Results    []check.Result
+	Findings   []check.Finding
+	Diff       *diff.Findings
+	Drift      *diff.Drift
+	History    []history.Point
+	Score      *score.Card
+	Waived     []waiver.Waived
+	Expired    []waiver.Waiver
+	Baseline   *export.Baseline
+	Compliance []compliance.Framework
+	Redaction  *redact.Summary
+	Page       Page
+}
+
+type Renderer struct {
+	tmpl  *template.Template
+	pages []string
+}
+
+// DefaultTemplates returns the embedded templates keyed by name.
+func DefaultTemplates() (map[string]string, error) {
+	templates := map[string]string{}
+	err := fs.WalkDir(templatesFS, "templates", func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() && p != "templates" {
+			return fs.SkipDir
+		} else if e.IsDir() {
+			return nil
+		}
+		b, err := templatesFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		templates[filepath.Base(p)] = string(b)
+		return nil
+	})
+	return templates, err
+}
+
+// New parses all templates into one set so they can share partials. Names ending in .tmpl
+// and not starting with '_' are pages. ex: 'observability__index.md.tmpl' renders to observability/index.md
+// Pages whose path leaves the rendered tree are refused.
+func New(templates map[string]string) (*Renderer, error) {
+	r := &Renderer{tmpl: template.New("hcr").Funcs(Funcs())}
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := r.tmpl.New(name).Parse(templates[name]); err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		if strings.HasSuffix(name, templateExt) && !strings.HasPrefix(name, "_") {
+			if page := pagePath(name); !filepath.IsLocal(page) {
+				return nil, fmt.Errorf("template %s: page %s is outside the report", name, page)
+			}
+			r.pages = append(r.pages, name)
+		}
+	}
+	return r, nil
+}
+
+// Render executes every page template writing the markdown tree under path.
+// It returns the relative paths of the written pages.
+func (r *Renderer) Render(path string, ctx Context) ([]string, error) {
+	l, err := i18n.New(ctx.Language)
+	if err != nil {
+		return nil, err
+	}
+	r.tmpl.Funcs(LocaleFuncs(l))
+	// templates query them with jq, which fails on null
+	if ctx.Results == nil {
+		ctx.Results = []check.Result{}
+	}
+	if ctx.Findings == nil {
+		ctx.Findings = []check.Finding{}
+	}
+	written := []string{}
+	for _, name := range r.pages {
+		page := pagePath(name)
+		if !strings.Contains(page, categoryPlaceholder) {
+			ctx.Page = Page{Path: page}
+			if err := r.renderPage(path, name, ctx); err != nil {
+				return written, err
+			}
+			written = append(written, page)
+			continue
+		}
+		for _, category := range Categories(ctx.Findings) {
+			ctx.Page = Page{Path: strings.ReplaceAll(page, categoryPlaceholder, category), Category: category}
+			if !filepath.IsLocal(ctx.Page.Path) {
+				return written, fmt.Errorf("template %s: page %s of category %s is outside the report", name, ctx.Page.Path, category)
+			}
+			if err := r.renderPage(path, name, ctx); err != nil {
+				return written, err
+			}
+			written = append(written, ctx.Page.Path)
+		}
+	}
+	logger.Debug("rendered", zap.String("path", path), zap.Int("pages", len(written)))
+	return written, nil
+}
+
+// pagePath is the path of the page rendered by the template name.
+func pagePath(name string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(name, templateExt), dirSep, "/")
+}
+
+func (r *Renderer) renderPage(path string, name string, ctx Context) error {
+	var out bytes.Buffer
+	if err := r.tmpl.ExecuteTemplate(&out, name, ctx); err != nil {
+		return err
+	}
+	file := filepath.Join(path, ctx.Page.Path)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, out.Bytes(), 0644)
+}
+
+// Categories lists the distinct finding categories sorted by name.
+func Categories(findings []check.Finding) []string {
+	categories := []string{}
+	for _, f := range findings {
+		if !slices.Contains(categories, f.Category) {
+			categories = append(categories, f.Category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// LoadData reads every stage 2 yaml document under path keyed by its file name without extension.
+func LoadData(path string) (map[string]any, error) {
+	data := map[string]any{}
+	files, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return data, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		b, err := crypt.ReadFile(filepath.Join(path, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var doc any
+		if err = yaml.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		data[strings.TrimSuffix(f.Name(), ext)] = dump.Normalize(doc)
+	}
+	return data, nil
+}
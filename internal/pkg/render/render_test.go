@@ -0,0 +1,122 @@
+package render_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+	"adoption.latam/hcr/internal/pkg/render"
+)
+
+var _ = Describe("Render", func() {
+	findings := []check.Finding{
+		{CheckId: "OBS-1", Category: "observability", Severity: check.SeverityHigh, Name: "a|b"},
+		{CheckId: "SEC-1", Category: "security", Severity: check.SeverityLow, Name: "c"},
+		{CheckId: "OBS-2", Category: "observability", Severity: check.SeverityLow, Name: "d"},
+	}
+
+	DescribeTable("pages",
+		func(templates map[string]string, ctx render.Context, expected map[string]string) {
+			r, err := render.New(templates)
+			Expect(err).NotTo(HaveOccurred())
+			dir := GinkgoT().TempDir()
+			ctx.Language = "en"
+			written, err := r.Render(dir, ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(written).To(HaveLen(len(expected)))
+			for page, content := range expected {
+				Expect(written).To(ContainElement(page))
+				b, err := os.ReadFile(filepath.Join(dir, page))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(b)).To(Equal(content))
+			}
+		},
+		Entry("render under directories split by double underscores",
+			map[string]string{"ops__index.md.tmpl": "# {{ .Page.Path }}"},
+			render.Context{}, map[string]string{"ops/index.md": "# ops/index.md"}),
+		Entry("leave partials out and share them",
+			map[string]string{"_row.tmpl": "{{ .Name }}", "index.md.tmpl": `{{ range .Findings }}{{ template "_row.tmpl" . }};{{ end }}`},
+			render.Context{Findings: findings}, map[string]string{"index.md": "a|b;c;d;"}),
+		Entry("render once per category",
+			map[string]string{"categories___category_.md.tmpl": "{{ .Page.Category }}"},
+			render.Context{Findings: findings}, map[string]string{"categories/observability.md": "observability", "categories/security.md": "security"}),
+		Entry("query null results and findings as empty lists",
+			map[string]string{"index.md.tmpl": "{{ jq `length` .Results }} {{ jq `length` .Findings }}"},
+			render.Context{}, map[string]string{"index.md": "0 0"}),
+		Entry("render tables escaping the cells",
+			map[string]string{"index.md.tmpl": `{{ table .Findings "Check=checkId" "name" }}`},
+			render.Context{Findings: findings[:1]}, map[string]string{"index.md": "| Check | Name |\n| --- | --- |\n| OBS-1 | a\\|b |\n"}),
+	)
+
+	It("renders the embedded templates of an empty run", func() {
+		templates, err := render.DefaultTemplates()
+		Expect(err).NotTo(HaveOccurred())
+		r, err := render.New(templates)
+		Expect(err).NotTo(HaveOccurred())
+		written, err := r.Render(GinkgoT().TempDir(), render.Context{Language: "en"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(written).To(ContainElements("index.md", "summary.md", "checks.md"))
+	})
+
+	DescribeTable("refusing pages outside the report",
+		func(name string) {
+			_, err := render.New(map[string]string{name: "x"})
+			Expect(err).To(MatchError(ContainSubstring("is outside the report")))
+		},
+		Entry("going up with double underscores", "a__..__..__..__x.md.tmpl"),
+		Entry("going up at once", "..__x.md.tmpl"),
+		Entry("going back and up", "ops__..__..__x.md.tmpl"),
+	)
+
+	It("refuses category pages outside the report", func() {
+		r, err := render.New(map[string]string{"categories___category_.md.tmpl": "x"})
+		Expect(err).NotTo(HaveOccurred())
+		dir := GinkgoT().TempDir()
+		_, err = r.Render(filepath.Join(dir, "docs"), render.Context{Language: "en", Findings: []check.Finding{{Category: "../../x"}}})
+		Expect(err).To(MatchError(ContainSubstring("is outside the report")))
+		Expect(filepath.Join(dir, "x.md")).NotTo(BeAnExistingFile())
+	})
+
+	It("fails on templates that do not parse", func() {
+		_, err := render.New(map[string]string{"index.md.tmpl": "{{ .Run"})
+		Expect(err).To(MatchError(ContainSubstring("template index.md.tmpl")))
+	})
+
+	It("lists the categories once sorted by name", func() {
+		Expect(render.Categories(findings)).To(Equal([]string{"observability", "security"}))
+		Expect(render.Categories(nil)).To(BeEmpty())
+	})
+
+	DescribeTable("funcs",
+		func(text string, data any, expected string) {
+			t, err := template.New("t").Funcs(render.Funcs()).Parse(text)
+			Expect(err).NotTo(HaveOccurred())
+			var out strings.Builder
+			Expect(t.Execute(&out, data)).To(Succeed())
+			Expect(out.String()).To(Equal(expected))
+		},
+		Entry("jq returns a single result as is", "{{ jq `.a` . }}", map[string]any{"a": "x"}, "x"),
+		Entry("jq returns many results as a list", "{{ jq `.[]` . | join \",\" }}", []int{1, 2}, "1,2"),
+		Entry("jq keeps percent signs", "{{ jq `\"\\(.)%\"` . }}", 5, "5%"),
+		Entry("humanSize of bytes", "{{ humanSize 1536 }}", nil, "1.5Ki"),
+		Entry("humanSize of a quantity", `{{ humanSize "2Gi" }}`, nil, "2Gi"),
+		Entry("humanSize under a unit", "{{ humanSize 512 }}", nil, "512"),
+		Entry("cores of millicores", `{{ cores "500m" }}`, nil, "0.5"),
+		Entry("bytes of a quantity", `{{ bytes "1Ki" }}`, nil, "1024"),
+		Entry("sum of quantities", `{{ sumQty (list "500m" "1.5") }}`, nil, "2"),
+		Entry("percent", `{{ percent "250m" "1" }}`, nil, "25.0%"),
+		Entry("percent of nothing", `{{ percent "1" "0" }}`, nil, "-"),
+		Entry("duration in days", `{{ duration "7d" }}`, nil, "168h0m0s"),
+		Entry("duration in weeks", `{{ duration "2w" }}`, nil, "336h0m0s"),
+		Entry("duration in go syntax", `{{ duration "1h30m" }}`, nil, "1h30m0s"),
+		Entry("escape of table cells", `{{ escape "a|b\nc" }}`, nil, "a\\|b<br>c"),
+		Entry("indent of non empty lines", `{{ indent 2 "a\n\nb" }}`, nil, "  a\n\n  b"),
+		Entry("title of a word", `{{ title "security" }}`, nil, "Security"),
+		Entry("title of a multibyte first letter", `{{ title "épico" }}`, nil, "Épico"),
+	)
+})
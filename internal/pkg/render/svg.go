@@ -0,0 +1,168 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"adoption.latam/hcr/internal/pkg/history"
+	"adoption.latam/hcr/internal/pkg/i18n"
+)
+
+const (
+	chartWidth  = 720
+	chartHeight = 260
+	chartLeft   = 56
+	chartRight  = 16
+	chartTop    = 40
+	chartBottom = 36
+	chartTicks  = 4
+	chartLabels = 8
+)
+
+// same colors as the .sev-* classes in hcr.css
+var severityColors = map[string]string{
+	"critical": "#7b1010",
+	"high":     "#c9190b",
+	"medium":   "#ec7a08",
+	"low":      "#f0ab00",
+	"info":     "#2b9af3",
+}
+
+type series struct {
+	name   string
+	color  string
+	values []float64
+}
+
+// trend renders one of the trend charts over the history as an inline svg:
+// findings, score, inventory (nodes and pods) or usage (requested cpu and memory percent).
+// Titles and legends are in the language of l.
+func trend(l *i18n.Localizer, points []history.Point, chart string) (string, error) {
+	labels := make([]string, len(points))
+	for i, p := range points {
+		labels[i] = p.Run
+		if t, err := time.Parse(time.RFC3339, p.Date); err == nil {
+			labels[i] = t.Format("2006-01-02")
+		}
+	}
+	values := func(f func(p history.Point) float64) []float64 {
+		v := make([]float64, len(points))
+		for i, p := range points {
+			v[i] = f(p)
+		}
+		return v
+	}
+	switch chart {
+	case "findings":
+		ss := []series{}
+		for _, s := range []string{"critical", "high", "medium", "low", "info"} {
+			ss = append(ss, series{l.T(s), severityColors[s], values(func(p history.Point) float64 { return float64(p.Findings[s]) })})
+		}
+		return lineChart(l.T("Findings by severity"), "", labels, ss), nil
+	case "score":
+		ss := []series{{l.T("score"), "#3e8635", values(func(p history.Point) float64 {
+			if p.Score == nil {
+				return math.NaN()
+			}
+			return *p.Score
+		})}}
+		return lineChart(l.T("Health score"), "", labels, ss), nil
+	case "inventory":
+		return lineChart(l.T("Nodes and pods"), "", labels, []series{
+			{l.T("nodes"), "#6753ac", values(func(p history.Point) float64 { return float64(p.Nodes) })},
+			{l.T("pods"), "#009596", values(func(p history.Point) float64 { return float64(p.Pods) })},
+		}), nil
+	case "usage":
+		return lineChart(l.T("Requested capacity"), "%", labels, []series{
+			{l.T("cpu"), "#0066cc", values(func(p history.Point) float64 { return p.Cpu.Percent })},
+			{l.T("memory"), "#8f4700", values(func(p history.Point) float64 { return p.Memory.Percent })},
+		}), nil
+	}
+	return "", fmt.Errorf("trend: unknown chart '%s'", chart)
+}
+
+// lineChart draws series sharing the x labels on a y axis starting at zero. NaN values are gaps.
+func lineChart(title string, unit string, labels []string, ss []series) string {
+	top := 0.0
+	for _, s := range ss {
+		for _, v := range s.values {
+			if !math.IsNaN(v) {
+				top = math.Max(top, v)
+			}
+		}
+	}
+	top = niceCeil(top)
+	plotW := float64(chartWidth - chartLeft - chartRight)
+	plotH := float64(chartHeight - chartTop - chartBottom)
+	x := func(i int) float64 {
+		if len(labels) < 2 {
+			return chartLeft + plotW/2
+		}
+		return chartLeft + plotW*float64(i)/float64(len(labels)-1)
+	}
+	y := func(v float64) float64 { return chartTop + plotH - plotH*v/top }
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" class="hcr-chart" viewBox="0 0 %d %d" width="100%%" role="img" aria-label="%s" font-family="sans-serif" font-size="11">`,
+		chartWidth, chartHeight, xmlText(title))
+	fmt.Fprintf(&sb, `<text x="%d" y="16" font-size="14" font-weight="bold">%s</text>`, chartLeft, xmlText(title))
+	for i := 0; i <= chartTicks; i++ {
+		v := top * float64(i) / chartTicks
+		fmt.Fprintf(&sb, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="#e0e0e0"/>`, chartLeft, chartWidth-chartRight, y(v), y(v))
+		fmt.Fprintf(&sb, `<text x="%d" y="%.1f" text-anchor="end" fill="#555">%s%s</text>`, chartLeft-6, y(v)+4, formatTick(v), unit)
+	}
+	step := int(math.Ceil(float64(len(labels)) / chartLabels))
+	for i, l := range labels {
+		if i%max(step, 1) == 0 || i == len(labels)-1 {
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%d" text-anchor="middle" fill="#555">%s</text>`, x(i), chartHeight-chartBottom+16, xmlText(l))
+		}
+	}
+	legend := chartLeft + 200
+	for _, s := range ss {
+		fmt.Fprintf(&sb, `<rect x="%d" y="8" width="10" height="10" fill="%s"/><text x="%d" y="17">%s</text>`, legend, s.color, legend+14, xmlText(s.name))
+		legend += 24 + 7*len(s.name)
+		var path strings.Builder
+		pen := "M"
+		for i, v := range s.values {
+			if math.IsNaN(v) {
+				pen = "M"
+				continue
+			}
+			fmt.Fprintf(&path, "%s%.1f %.1f ", pen, x(i), y(v))
+			pen = "L"
+			fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s %s: %s%s</title></circle>`,
+				x(i), y(v), s.color, xmlText(labels[i]), xmlText(s.name), formatTick(v), unit)
+		}
+		if path.Len() > 0 {
+			fmt.Fprintf(&sb, `<path d="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.TrimSpace(path.String()), s.color)
+		}
+	}
+	sb.WriteString(`</svg>`)
+	return sb.String()
+}
+
+// niceCeil rounds v up to 1, 2, 5 or 10 times a power of ten so the ticks are round numbers.
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	p := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*p {
+			return m * p
+		}
+	}
+	return 10 * p
+}
+
+func formatTick(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func xmlText(s string) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+	return r.Replace(s)
+}
@@ -0,0 +1,386 @@
+// Package s3 uploads files to S3 compatible object storage (AWS, MinIO, ODF/NooBaa) with a
+// signature version 4 signer of its own. Large files go as multipart uploads that resume the
+// parts the bucket already holds, told by their SHA256 checksums.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"adoption.latam/hcr/internal/pkg/util/log"
+)
+
+const (
+	DefaultRegion   = "us-east-1"
+	MinPartSize     = 5 << 20
+	DefaultPartSize = 16 << 20
+
+	SSES3  = "AES256"
+	SSEKMS = "aws:kms"
+
+	checksumAlgorithm = "SHA256"
+)
+
+var logger = log.Logger().Named("hcr.s3")
+
+type Config struct {
+	// Endpoint is the scheme and host, ex: https://s3.eu-west-1.amazonaws.com or http://localhost:9000.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// VirtualHosted addresses the bucket as a subdomain of the endpoint instead of the first path segment.
+	VirtualHosted bool
+	Credentials   Credentials
+	// CA is PEM trusted besides the system roots.
+	CA []byte
+	// SSE is empty, AES256 or aws:kms with KMSKeyId.
+	SSE      string
+	KMSKeyId string
+	PartSize int64
+}
+
+type Client struct {
+	cfg      Config
+	endpoint *url.URL
+	http     *http.Client
+}
+
+// Result is what Upload did.
+type Result struct {
+	Url     string `json:"url"`
+	Size    int64  `json:"size"`
+	Parts   int    `json:"parts,omitempty"`
+	Resumed int    `json:"resumed,omitempty"`
+}
+
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("endpoint %s must be http(s)://host[:port]", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("bucket is mandatory")
+	}
+	if cfg.Region == "" {
+		cfg.Region = DefaultRegion
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = DefaultPartSize
+	}
+	if cfg.PartSize < MinPartSize {
+		return nil, fmt.Errorf("part size must be at least %d bytes", MinPartSize)
+	}
+	if cfg.SSE != "" && cfg.SSE != SSES3 && cfg.SSE != SSEKMS {
+		return nil, fmt.Errorf("sse must be %s or %s", SSES3, SSEKMS)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(cfg.CA) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(cfg.CA) {
+			return nil, errors.New("no certificate found in the ca")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &Client{cfg: cfg, endpoint: u, http: &http.Client{Transport: transport}}, nil
+}
+
+// objectUrl is the url of key, or of the bucket when key is empty.
+func (c *Client) objectUrl(key string) *url.URL {
+	u := *c.endpoint
+	path := "/" + uriEncode(key, false)
+	if c.cfg.VirtualHosted {
+		u.Host = c.cfg.Bucket + "." + u.Host
+	} else {
+		path = "/" + uriEncode(c.cfg.Bucket, true) + path
+	}
+	u.Path, _ = url.PathUnescape(path)
+	u.RawPath = path
+	return &u
+}
+
+// Url is the object url of key.
+func (c *Client) Url(key string) string {
+	return c.objectUrl(key).String()
+}
+
+// s3Error is the error document returned by S3.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// do sends a signed request and returns the response body. Responses other than 2xx and
+// 200 responses holding an error document are errors.
+func (c *Client) do(ctx context.Context, method string, key string, query url.Values, header http.Header, body []byte) (http.Header, []byte, error) {
+	u := c.objectUrl(key)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.URL.RawPath = u.RawPath
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+	sum := sha256.Sum256(body)
+	sign(req, c.cfg.Credentials, c.cfg.Region, "s3", hex.EncodeToString(sum[:]), time.Now())
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode/100 != 2 || bytes.Contains(b[:min(len(b), 128)], []byte("<Error>")) {
+		e := s3Error{}
+		if xml.Unmarshal(b, &e) != nil || e.Code == "" {
+			e = s3Error{Code: resp.Status, Message: strings.TrimSpace(string(b))}
+		}
+		return nil, nil, fmt.Errorf("%s %s: %w", method, u.Path, e)
+	}
+	return resp.Header, b, nil
+}
+
+// CreateBucket creates the bucket, which may already be there.
+func (c *Client) CreateBucket(ctx context.Context) error {
+	_, _, err := c.do(ctx, http.MethodPut, "", nil, nil, nil)
+	e := s3Error{}
+	if errors.As(err, &e) && (e.Code == "BucketAlreadyOwnedByYou" || e.Code == "BucketAlreadyExists") {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) sseHeader() http.Header {
+	h := http.Header{}
+	if c.cfg.SSE != "" {
+		h.Set("X-Amz-Server-Side-Encryption", c.cfg.SSE)
+	}
+	if c.cfg.SSE == SSEKMS && c.cfg.KMSKeyId != "" {
+		h.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", c.cfg.KMSKeyId)
+	}
+	return h
+}
+
+// checksumHeader sets the SHA256 checksum S3 keeps with a part of an upload started with that
+// checksum algorithm.
+func checksumHeader(h http.Header, part []byte) string {
+	sum := sha256.Sum256(part)
+	checksum := base64.StdEncoding.EncodeToString(sum[:])
+	h.Set("X-Amz-Checksum-Sha256", checksum)
+	return checksum
+}
+
+func md5Header(h http.Header, part []byte) string {
+	sum := md5.Sum(part)
+	h.Set("Content-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// Upload reads r to key. What fits in a part is put at once, the rest is a multipart upload
+// that picks up an unfinished upload of the same key and skips the parts it already holds.
+// The parts are compared by their SHA256 checksums, or by their ETags when the storage keeps
+// no checksums, except under SSE-KMS where ETags are not the MD5 of the parts.
+// Failed multipart uploads are left in place to be resumed, a lifecycle rule aborting
+// incomplete uploads cleans the abandoned ones.
+func (c *Client) Upload(ctx context.Context, key string, r io.Reader) (Result, error) {
+	res := Result{Url: c.Url(key)}
+	part := make([]byte, c.cfg.PartSize)
+	n, err := io.ReadFull(r, part)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return res, err
+	}
+	if int64(n) < c.cfg.PartSize {
+		h := c.sseHeader()
+		md5Header(h, part[:n])
+		h.Set("Content-Type", contentType(key))
+		_, _, err = c.do(ctx, http.MethodPut, key, nil, h, part[:n])
+		res.Size = int64(n)
+		return res, err
+	}
+	up, done, err := c.resume(ctx, key)
+	if err != nil {
+		return res, err
+	}
+	completed := []completedPart{}
+	for number := 1; n > 0; number++ {
+		h := http.Header{}
+		etag := md5Header(h, part[:n])
+		checksum := ""
+		if up.checksum {
+			checksum = checksumHeader(h, part[:n])
+		}
+		if p, ok := done[number]; ok && p.Size == int64(n) && c.holds(p, etag, checksum) {
+			res.Resumed++
+		} else {
+			q := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {up.id}}
+			header, _, err := c.do(ctx, http.MethodPut, key, q, h, part[:n])
+			if err != nil {
+				return res, fmt.Errorf("part %d: %w", number, err)
+			}
+			etag = header.Get("ETag")
+		}
+		completed = append(completed, completedPart{PartNumber: number, ETag: etag, ChecksumSHA256: checksum})
+		res.Size += int64(n)
+		if n, err = io.ReadFull(r, part); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return res, err
+		}
+	}
+	res.Parts = len(completed)
+	body, err := xml.Marshal(completeUpload{Parts: completed})
+	if err != nil {
+		return res, err
+	}
+	_, _, err = c.do(ctx, http.MethodPost, key, url.Values{"uploadId": {up.id}}, http.Header{"Content-Type": {"application/xml"}}, body)
+	return res, err
+}
+
+// holds tells if the uploaded part p is the one of etag and checksum.
+func (c *Client) holds(p uploadedPart, etag string, checksum string) bool {
+	if checksum != "" {
+		return p.ChecksumSHA256 == checksum
+	}
+	return c.cfg.SSE != SSEKMS && p.ETag == etag
+}
+
+type listUploads struct {
+	Uploads []struct {
+		Key               string `xml:"Key"`
+		UploadId          string `xml:"UploadId"`
+		Initiated         string `xml:"Initiated"`
+		ChecksumAlgorithm string `xml:"ChecksumAlgorithm"`
+	} `xml:"Upload"`
+}
+
+// upload is a multipart upload, checksum when it was started with the SHA256 checksum algorithm.
+type upload struct {
+	id       string
+	checksum bool
+}
+
+type uploadedPart struct {
+	PartNumber     int    `xml:"PartNumber"`
+	ETag           string `xml:"ETag"`
+	Size           int64  `xml:"Size"`
+	ChecksumSHA256 string `xml:"ChecksumSHA256"`
+}
+
+type listParts struct {
+	IsTruncated          bool           `xml:"IsTruncated"`
+	NextPartNumberMarker int            `xml:"NextPartNumberMarker"`
+	Parts                []uploadedPart `xml:"Part"`
+}
+
+type completedPart struct {
+	PartNumber     int    `xml:"PartNumber"`
+	ETag           string `xml:"ETag"`
+	ChecksumSHA256 string `xml:"ChecksumSHA256,omitempty"`
+}
+
+type completeUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// resume returns the latest unfinished upload of key with its parts or starts a new one. New
+// uploads ask for SHA256 checksums, which storage not knowing them leaves out.
+func (c *Client) resume(ctx context.Context, key string) (upload, map[int]uploadedPart, error) {
+	done := map[int]uploadedPart{}
+	_, b, err := c.do(ctx, http.MethodGet, "", url.Values{"uploads": {""}, "prefix": {key}}, nil, nil)
+	if err != nil {
+		return upload{}, nil, err
+	}
+	lu := listUploads{}
+	if err = xml.Unmarshal(b, &lu); err != nil {
+		return upload{}, nil, err
+	}
+	sort.Slice(lu.Uploads, func(i, j int) bool { return lu.Uploads[i].Initiated > lu.Uploads[j].Initiated })
+	for _, u := range lu.Uploads {
+		if u.Key != key {
+			continue
+		}
+		marker := 0
+		for {
+			q := url.Values{"uploadId": {u.UploadId}, "part-number-marker": {strconv.Itoa(marker)}}
+			_, b, err = c.do(ctx, http.MethodGet, key, q, nil, nil)
+			if err != nil {
+				return upload{}, nil, err
+			}
+			lp := listParts{}
+			if err = xml.Unmarshal(b, &lp); err != nil {
+				return upload{}, nil, err
+			}
+			for _, p := range lp.Parts {
+				done[p.PartNumber] = p
+			}
+			if !lp.IsTruncated {
+				break
+			}
+			marker = lp.NextPartNumberMarker
+		}
+		logger.Info("resuming upload", zap.String("key", key), zap.Int("parts", len(done)))
+		return upload{id: u.UploadId, checksum: u.ChecksumAlgorithm == checksumAlgorithm}, done, nil
+	}
+	h := c.sseHeader()
+	h.Set("Content-Type", contentType(key))
+	h.Set("X-Amz-Checksum-Algorithm", checksumAlgorithm)
+	header, b, err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, h, nil)
+	if err != nil {
+		return upload{}, nil, err
+	}
+	initiated := struct {
+		UploadId string `xml:"UploadId"`
+	}{}
+	if err = xml.Unmarshal(b, &initiated); err != nil {
+		return upload{}, nil, err
+	}
+	return upload{id: initiated.UploadId, checksum: header.Get("X-Amz-Checksum-Algorithm") == checksumAlgorithm}, done, nil
+}
+
+func contentType(key string) string {
+	switch {
+	case strings.HasSuffix(key, ".tar.gz"), strings.HasSuffix(key, ".gz"):
+		return "application/gzip"
+	case strings.HasSuffix(key, ".json"), strings.HasSuffix(key, ".sarif"):
+		return "application/json"
+	case strings.HasSuffix(key, ".html"):
+		return "text/html"
+	case strings.HasSuffix(key, ".md"):
+		return "text/markdown"
+	case strings.HasSuffix(key, ".csv"):
+		return "text/csv"
+	case strings.HasSuffix(key, ".xml"):
+		return "application/xml"
+	case strings.HasSuffix(key, ".yaml"), strings.HasSuffix(key, ".yml"):
+		return "application/yaml"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,14 @@
+package s3
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestS3(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "S3 Suite")
+}
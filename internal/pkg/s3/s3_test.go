@@ -0,0 +1,156 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// failingReader fails once it is read.
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection lost")
+}
+
+// These specs run against a MinIO, or any S3 compatible storage, given by HCR_TEST_S3_ENDPOINT
+// and HCR_TEST_S3_ACCESS_KEY/HCR_TEST_S3_SECRET_KEY (minioadmin by default):
+//
+//	podman run -d -p 9000:9000 quay.io/minio/minio server /data
+//	HCR_TEST_S3_ENDPOINT=http://localhost:9000 go test ./internal/pkg/s3/...
+var _ = Describe("Client against MinIO", Ordered, func() {
+	var (
+		ctx    = context.Background()
+		client *Client
+	)
+
+	BeforeAll(func() {
+		endpoint := os.Getenv("HCR_TEST_S3_ENDPOINT")
+		if endpoint == "" {
+			Skip("HCR_TEST_S3_ENDPOINT is not set")
+		}
+		creds := Credentials{AccessKeyId: os.Getenv("HCR_TEST_S3_ACCESS_KEY"), SecretAccessKey: os.Getenv("HCR_TEST_S3_SECRET_KEY")}
+		if creds.AccessKeyId == "" {
+			creds = Credentials{AccessKeyId: "minioadmin", SecretAccessKey: "minioadmin"}
+		}
+		var err error
+		client, err = New(Config{
+			Endpoint:    endpoint,
+			Bucket:      "hcr-test-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+			Credentials: creds,
+			PartSize:    MinPartSize,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.CreateBucket(ctx)).To(Succeed())
+		Expect(client.CreateBucket(ctx)).To(Succeed(), "an existing bucket is not an error")
+	})
+
+	get := func(key string) []byte {
+		_, b, err := client.do(ctx, http.MethodGet, key, nil, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		return b
+	}
+
+	It("puts what fits in a part at once", func() {
+		data := []byte("# report\n")
+		res, err := client.Upload(ctx, "runs/20261019T000000Z/index.md", bytes.NewReader(data))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Parts).To(BeZero())
+		Expect(res.Size).To(BeEquivalentTo(len(data)))
+		Expect(get("runs/20261019T000000Z/index.md")).To(Equal(data))
+	})
+
+	It("uploads keys with spaces and unicode", func() {
+		key := "runs/relatório final+1.md"
+		_, err := client.Upload(ctx, key, bytes.NewReader([]byte("ok")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(get(key)).To(Equal([]byte("ok")))
+	})
+
+	It("resumes a failed multipart upload from the parts the bucket holds", func() {
+		data := make([]byte, 2*MinPartSize+123)
+		_, _ = rand.Read(data)
+		key := "bundles/20261019T000000Z.tar.gz"
+
+		By("failing after the first part")
+		_, err := client.Upload(ctx, key, io.MultiReader(bytes.NewReader(data[:MinPartSize]), failingReader{}))
+		Expect(err).To(MatchError(ContainSubstring("connection lost")))
+
+		By("uploading again")
+		res, err := client.Upload(ctx, key, bytes.NewReader(data))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Parts).To(Equal(3))
+		Expect(res.Resumed).To(Equal(1))
+		Expect(res.Size).To(BeEquivalentTo(len(data)))
+		Expect(bytes.Equal(get(key), data)).To(BeTrue())
+	})
+})
+
+var _ = Describe("Resuming multipart uploads", func() {
+	// resumed uploads data over an unfinished upload holding its first part, listed with a
+	// checksum algorithm and checksum or none and an ETag, and returns the parts put again.
+	resumed := func(sse string, algorithm string, checksum func([]byte) string, etag func([]byte) string) (Result, []string) {
+		data := make([]byte, MinPartSize+1)
+		_, _ = rand.Read(data)
+		first := data[:MinPartSize]
+		put := []string{}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /bucket/{$}", func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "<ListMultipartUploadsResult><Upload><Key>bundle.tar.gz</Key><UploadId>1</UploadId>"+
+				"<ChecksumAlgorithm>%s</ChecksumAlgorithm></Upload></ListMultipartUploadsResult>", algorithm)
+		})
+		mux.HandleFunc("GET /bucket/bundle.tar.gz", func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "<ListPartsResult><Part><PartNumber>1</PartNumber><ETag>%s</ETag><Size>%d</Size>"+
+				"<ChecksumSHA256>%s</ChecksumSHA256></Part></ListPartsResult>", etag(first), len(first), checksum(first))
+		})
+		mux.HandleFunc("PUT /bucket/bundle.tar.gz", func(w http.ResponseWriter, req *http.Request) {
+			put = append(put, req.URL.Query().Get("partNumber"))
+			w.Header().Set("ETag", `"opaque"`)
+		})
+		mux.HandleFunc("POST /bucket/bundle.tar.gz", func(w http.ResponseWriter, req *http.Request) {})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		client, err := New(Config{Endpoint: server.URL, Bucket: "bucket", SSE: sse, PartSize: MinPartSize})
+		Expect(err).NotTo(HaveOccurred())
+		res, err := client.Upload(context.Background(), "bundle.tar.gz", bytes.NewReader(data))
+		Expect(err).NotTo(HaveOccurred())
+		return res, put
+	}
+	sha := func(b []byte) string {
+		sum := sha256.Sum256(b)
+		return base64.StdEncoding.EncodeToString(sum[:])
+	}
+	md5sum := func(b []byte) string {
+		sum := md5.Sum(b)
+		return `"` + hex.EncodeToString(sum[:]) + `"`
+	}
+	none := func([]byte) string { return "" }
+
+	DescribeTable("skips the parts the bucket holds",
+		func(sse string, algorithm string, checksum func([]byte) string, etag func([]byte) string, expected []string) {
+			res, put := resumed(sse, algorithm, checksum, etag)
+			Expect(put).To(Equal(expected))
+			Expect(res.Parts).To(Equal(2))
+			Expect(res.Resumed).To(Equal(2 - len(expected)))
+		},
+		Entry("by checksum under SSE-KMS", SSEKMS, "SHA256", sha, func([]byte) string { return `"opaque"` }, []string{"2"}),
+		Entry("not with another checksum", SSEKMS, "SHA256", func([]byte) string { return "other" }, md5sum, []string{"1", "2"}),
+		Entry("by ETag without checksums", SSES3, "", none, md5sum, []string{"2"}),
+		Entry("not by ETag under SSE-KMS", SSEKMS, "", none, md5sum, []string{"1", "2"}),
+	)
+})
@@ -0,0 +1,118 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDate         = "20060102T150405Z"
+	algorithm       = "AWS4-HMAC-SHA256"
+	EmptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	contentSha256   = "X-Amz-Content-Sha256"
+	headerAmzDate   = "X-Amz-Date"
+	headerAmzToken  = "X-Amz-Security-Token"
+	scopeTerminator = "aws4_request"
+)
+
+type Credentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// sign adds the AWS signature version 4 headers to req. payloadHash is the hex sha256 of the body.
+// The path of req.URL must be set escaped in RawPath as the canonical request uses it as is.
+func sign(req *http.Request, creds Credentials, region string, service string, payloadHash string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set(headerAmzDate, t.Format(amzDate))
+	req.Header.Set(contentSha256, payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set(headerAmzToken, creds.SessionToken)
+	}
+	headers, signed := canonicalHeaders(req)
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{t.Format("20060102"), region, service, scopeTerminator}, "/")
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{algorithm, t.Format(amzDate), scope, hex.EncodeToString(sum[:])}, "\n")
+	key := hmacSha256([]byte("AWS4"+creds.SecretAccessKey), t.Format("20060102"))
+	for _, s := range []string{region, service, scopeTerminator} {
+		key = hmacSha256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSha256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, creds.AccessKeyId, scope, signed, signature))
+}
+
+func hmacSha256(key []byte, s string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+// canonicalHeaders signs host, content type and md5 and every x-amz header.
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || lk == "content-md5" || strings.HasPrefix(lk, "x-amz-") {
+			values[lk] = strings.Join(strings.Fields(strings.Join(v, ",")), " ")
+		}
+	}
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, n := range names {
+		b.WriteString(n + ":" + values[n] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+func canonicalQuery(q url.Values) string {
+	pairs := []string{}
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode escapes everything but the unreserved characters and, unless encodeSlash, the slash.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package score
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+// Grade is the letter given to scores at or above Min.
+type Grade struct {
+	Grade string  `json:"grade"`
+	Min   float64 `json:"min"`
+}
+
+// Spec holds the tunable weights. A finding costs the weight of its severity times the weight
+// of its category. Categories without a weight weigh 1 and also count 1 in the overall score.
+type Spec struct {
+	Severity map[string]float64 `json:"severity"`
+	Category map[string]float64 `json:"category"`
+	Grades   []Grade            `json:"grades"`
+}
+
+// Score of a category or the run. Evaluated counts the objects the checks evaluated.
+type Score struct {
+	Score     float64 `json:"score"`
+	Grade     string  `json:"grade"`
+	Findings  int     `json:"findings"`
+	Evaluated int     `json:"evaluated"`
+}
+
+type Category struct {
+	Name string `json:"name"`
+	Score
+}
+
+type Card struct {
+	Overall    Score      `json:"overall"`
+	Categories []Category `json:"categories"`
+}
+
+func DefaultSpec() Spec {
+	return Spec{
+		Severity: map[string]float64{
+			check.SeverityCritical: 10,
+			check.SeverityHigh:     5,
+			check.SeverityMedium:   2,
+			check.SeverityLow:      1,
+			check.SeverityInfo:     0,
+		},
+		Category: map[string]float64{},
+		Grades:   []Grade{{"A", 90}, {"B", 80}, {"C", 70}, {"D", 60}, {"F", 0}},
+	}
+}
+
+// Validate fills what spec left out with the defaults.
+func (s *Spec) Validate() error {
+	d := DefaultSpec()
+	if s.Severity == nil {
+		s.Severity = map[string]float64{}
+	}
+	for k, v := range d.Severity {
+		if _, ok := s.Severity[k]; !ok {
+			s.Severity[k] = v
+		}
+	}
+	for k, v := range s.Severity {
+		if v < 0 {
+			return fmt.Errorf("severity %s: negative weight", k)
+		}
+	}
+	if s.Category == nil {
+		s.Category = map[string]float64{}
+	}
+	for k, v := range s.Category {
+		if v < 0 {
+			return fmt.Errorf("category %s: negative weight", k)
+		}
+	}
+	if len(s.Grades) == 0 {
+		s.Grades = d.Grades
+	}
+	sort.SliceStable(s.Grades, func(i, j int) bool { return s.Grades[i].Min > s.Grades[j].Min })
+	return nil
+}
+
+// Compute scores every category from 100 down. The penalty of the findings is normalized by the
+// objects the checks of the category evaluated, their subjects and not every object they read:
+// score = 100 * evaluated / (evaluated + penalty). So a category whose penalty equals its
+// evaluated objects scores 50. Categories where nothing was evaluated and nothing was found are
+// left out. The overall score is the mean of the category scores weighted by the category weights.
+func Compute(spec Spec, results []check.Result) Card {
+	type acc struct {
+		penalty   float64
+		evaluated int
+		findings  int
+	}
+	categories := map[string]*acc{}
+	for _, r := range results {
+		if r.Status == check.StatusNotEvaluated {
+			continue
+		}
+		a, ok := categories[r.Check.Category]
+		if !ok {
+			a = &acc{}
+			categories[r.Check.Category] = a
+		}
+		a.evaluated += r.Evaluated
+		a.findings += len(r.Findings)
+		for _, f := range r.Findings {
+			a.penalty += spec.Severity[f.Severity] * spec.categoryWeight(f.Category)
+		}
+	}
+	card := Card{Categories: []Category{}}
+	var sum, weights float64
+	for name, a := range categories {
+		if a.evaluated == 0 && a.findings == 0 {
+			continue
+		}
+		s := 100 * float64(max(a.evaluated, 1)) / (float64(max(a.evaluated, 1)) + a.penalty)
+		c := Category{Name: name, Score: spec.score(s, a.findings, a.evaluated)}
+		card.Categories = append(card.Categories, c)
+		sum += c.Score.Score * spec.categoryWeight(name)
+		weights += spec.categoryWeight(name)
+		card.Overall.Findings += a.findings
+		card.Overall.Evaluated += a.evaluated
+	}
+	sort.Slice(card.Categories, func(i, j int) bool { return card.Categories[i].Name < card.Categories[j].Name })
+	overall := 100.0
+	if weights > 0 {
+		overall = sum / weights
+	}
+	card.Overall = spec.score(overall, card.Overall.Findings, card.Overall.Evaluated)
+	return card
+}
+
+func (s Spec) categoryWeight(category string) float64 {
+	if w, ok := s.Category[category]; ok {
+		return w
+	}
+	return 1
+}
+
+func (s Spec) score(value float64, findings int, evaluated int) Score {
+	value = math.Round(value*10) / 10
+	return Score{Score: value, Grade: s.grade(value), Findings: findings, Evaluated: evaluated}
+}
+
+// grade expects the grades sorted by Validate. A score under every boundary gets the last grade.
+func (s Spec) grade(value float64) string {
+	for _, g := range s.Grades {
+		if value >= g.Min {
+			return g.Grade
+		}
+	}
+	return s.Grades[len(s.Grades)-1].Grade
+}
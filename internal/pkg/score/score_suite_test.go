@@ -0,0 +1,14 @@
+package score
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestScore(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Score Suite")
+}
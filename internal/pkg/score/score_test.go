@@ -0,0 +1,71 @@
+package score
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+// result is a check of category evaluating objects with a finding per severity.
+func result(category string, evaluated int, severities ...string) check.Result {
+	r := check.Result{Check: check.Check{Category: category}, Status: check.StatusPass, Evaluated: evaluated}
+	for _, s := range severities {
+		r.Findings = append(r.Findings, check.Finding{Category: category, Severity: s})
+		r.Status = check.StatusFail
+	}
+	return r
+}
+
+var _ = Describe("Score", func() {
+	DescribeTable("validating specs",
+		func(spec Spec, expected string) {
+			err := spec.Validate()
+			if expected == "" {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(expected))
+			}
+		},
+		Entry("an empty spec", Spec{}, ""),
+		Entry("zero weights", Spec{Severity: map[string]float64{"high": 0}, Category: map[string]float64{"security": 0}}, ""),
+		Entry("a negative severity weight", Spec{Severity: map[string]float64{"high": -1}}, "severity high: negative weight"),
+		Entry("a negative category weight", Spec{Category: map[string]float64{"security": -2}}, "category security: negative weight"),
+	)
+
+	It("fills what the spec left out and sorts the grades", func() {
+		spec := Spec{Severity: map[string]float64{"high": 7}, Grades: []Grade{{"F", 0}, {"A", 90}}}
+		Expect(spec.Validate()).To(Succeed())
+		Expect(spec.Severity).To(HaveKeyWithValue("high", 7.0))
+		Expect(spec.Severity).To(HaveKeyWithValue("critical", 10.0))
+		Expect(spec.Grades).To(Equal([]Grade{{"A", 90}, {"F", 0}}))
+	})
+
+	DescribeTable("computing the card",
+		func(category map[string]float64, results []check.Result, overall Score, categories []Category) {
+			spec := Spec{Category: category}
+			Expect(spec.Validate()).To(Succeed())
+			card := Compute(spec, results)
+			Expect(card.Overall).To(Equal(overall))
+			Expect(card.Categories).To(Equal(categories))
+		},
+		Entry("of nothing evaluated", nil, nil,
+			Score{Score: 100, Grade: "A"}, []Category{}),
+		Entry("of clean checks", nil, []check.Result{result("a", 4)},
+			Score{Score: 100, Grade: "A", Evaluated: 4}, []Category{{"a", Score{Score: 100, Grade: "A", Evaluated: 4}}}),
+		Entry("halving a category whose penalty equals its objects", nil, []check.Result{result("a", 5, "high")},
+			Score{Score: 50, Grade: "F", Findings: 1, Evaluated: 5}, []Category{{"a", Score{Score: 50, Grade: "F", Findings: 1, Evaluated: 5}}}),
+		Entry("weighing the penalty by category", map[string]float64{"a": 2}, []check.Result{result("a", 5, "high")},
+			Score{Score: 33.3, Grade: "F", Findings: 1, Evaluated: 5}, []Category{{"a", Score{Score: 33.3, Grade: "F", Findings: 1, Evaluated: 5}}}),
+		Entry("weighing the overall mean by category", map[string]float64{"b": 3}, []check.Result{result("a", 5, "high"), result("b", 5)},
+			Score{Score: 87.5, Grade: "B", Findings: 1, Evaluated: 10},
+			[]Category{{"a", Score{Score: 50, Grade: "F", Findings: 1, Evaluated: 5}}, {"b", Score{Score: 100, Grade: "A", Evaluated: 5}}}),
+		Entry("normalizing by the evaluated objects", nil, []check.Result{result("a", 5000, "high"), result("b", 5, "high")},
+			Score{Score: 75, Grade: "C", Findings: 2, Evaluated: 5005},
+			[]Category{{"a", Score{Score: 99.9, Grade: "A", Findings: 1, Evaluated: 5000}}, {"b", Score{Score: 50, Grade: "F", Findings: 1, Evaluated: 5}}}),
+		Entry("leaving the checks not evaluated out", nil, []check.Result{{Check: check.Check{Category: "a"}, Status: check.StatusNotEvaluated}},
+			Score{Score: 100, Grade: "A"}, []Category{}),
+		Entry("with info findings free", nil, []check.Result{result("a", 2, "info", "info")},
+			Score{Score: 100, Grade: "A", Findings: 2, Evaluated: 2}, []Category{{"a", Score{Score: 100, Grade: "A", Findings: 2, Evaluated: 2}}}),
+	)
+})
@@ -0,0 +1,222 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mauricioscastro/kcdump/pkg/yjq"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"adoption.latam/hcr/internal/pkg/dump"
+	"adoption.latam/hcr/internal/pkg/util"
+	"adoption.latam/hcr/internal/pkg/util/log"
+)
+
+const (
+	StatusOk      = "ok"
+	StatusError   = "error"
+	StatusSkipped = "skipped"
+)
+
+var logger = log.Logger().Named("hcr.transform")
+
+// Step is one node of the stage 2 pipeline. Its input is an object keyed by the dump
+// resources listed in inputs (ex: nodes.v1) and by the outputs of the steps it depends on.
+// Exactly one of jq, yq or sed must be given. sed works over the input rendered as yaml.
+// The result is written to <output>.yaml, output defaulting to the name.
+type Step struct {
+	Name      string   `json:"name"`
+	Inputs    []string `json:"inputs,omitempty"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+	Jq        string   `json:"jq,omitempty"`
+	Yq        string   `json:"yq,omitempty"`
+	Sed       string   `json:"sed,omitempty"`
+	Output    string   `json:"output,omitempty"`
+}
+
+type Result struct {
+	Name     string `json:"name"`
+	Output   string `json:"output"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+func (s Step) output() string {
+	if s.Output != "" {
+		return s.Output
+	}
+	return s.Name
+}
+
+func (s Step) validate(steps map[string]Step) error {
+	ops := 0
+	for _, op := range []string{s.Jq, s.Yq, s.Sed} {
+		if op != "" {
+			ops++
+		}
+	}
+	if ops != 1 {
+		return fmt.Errorf("exactly one of jq, yq or sed is needed")
+	}
+	for _, d := range s.DependsOn {
+		if _, ok := steps[d]; !ok {
+			return fmt.Errorf("unknown dependency '%s'", d)
+		}
+	}
+	return nil
+}
+
+// Run executes the steps as a DAG writing every output as path/<output>.yaml.
+// Independent steps run in parallel. A failing step only stops the steps depending on it.
+func Run(d *dump.Dump, steps []Step, path string) ([]Result, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	byName := make(map[string]Step, len(steps))
+	byOutput := make(map[string]bool, len(steps))
+	for _, s := range steps {
+		if _, dup := byName[s.Name]; dup || s.Name == "" {
+			return nil, fmt.Errorf("transform step names must be unique and not empty: '%s'", s.Name)
+		}
+		byName[s.Name] = s
+		// an output is a file name under path, read back by the name alone
+		out := s.output()
+		if byOutput[out] || !filepath.IsLocal(out) || strings.ContainsAny(out, `/\`) {
+			return nil, fmt.Errorf("transform step outputs must be unique file names: '%s'", out)
+		}
+		byOutput[out] = true
+	}
+	results := make(map[string]Result, len(steps))
+	outputs := make(map[string]any, len(steps))
+	var lock sync.Mutex
+	done := func(s Step) bool { _, ok := results[s.Name]; return ok }
+	for len(results) < len(steps) {
+		wave := []Step{}
+		for _, s := range steps {
+			if done(s) {
+				continue
+			}
+			if err := s.validate(byName); err != nil {
+				results[s.Name] = Result{Name: s.Name, Output: s.output(), Status: StatusError, Error: err.Error()}
+				continue
+			}
+			ready := true
+			for _, dep := range s.DependsOn {
+				r, ok := results[dep]
+				if !ok {
+					ready = false
+				} else if r.Status != StatusOk {
+					results[s.Name] = Result{Name: s.Name, Output: s.output(), Status: StatusSkipped,
+						Error: fmt.Sprintf("dependency '%s' did not succeed", dep)}
+					ready = false
+					break
+				}
+			}
+			if ready && !done(s) {
+				wave = append(wave, s)
+			}
+		}
+		if len(wave) == 0 {
+			// whatever is left waits on itself
+			for _, s := range steps {
+				if !done(s) {
+					results[s.Name] = Result{Name: s.Name, Output: s.output(), Status: StatusError, Error: "dependency cycle"}
+				}
+			}
+			break
+		}
+		var wg sync.WaitGroup
+		for _, s := range wave {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				start := time.Now()
+				lock.Lock()
+				input := make(map[string]any, len(s.Inputs)+len(s.DependsOn))
+				for _, dep := range s.DependsOn {
+					input[byName[dep].output()] = outputs[byName[dep].output()]
+				}
+				lock.Unlock()
+				for _, in := range s.Inputs {
+					input[in] = d.Items(in)
+				}
+				out, err := s.run(input, path)
+				r := Result{Name: s.Name, Output: s.output(), Status: StatusOk, Duration: time.Since(start).Round(time.Millisecond).String()}
+				if err != nil {
+					logger.Warn("transform step", zap.String("step", s.Name), zap.Error(err))
+					r.Status = StatusError
+					r.Error = err.Error()
+				}
+				lock.Lock()
+				results[s.Name] = r
+				outputs[s.output()] = out
+				lock.Unlock()
+			}()
+		}
+		wg.Wait()
+	}
+	ordered := make([]Result, 0, len(steps))
+	for _, s := range steps {
+		ordered = append(ordered, results[s.Name])
+	}
+	return ordered, nil
+}
+
+func (s Step) run(input map[string]any, path string) (any, error) {
+	in, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	switch {
+	case s.Jq != "":
+		out, err = eval(yjq.JqEval, s.Jq, string(in))
+	case s.Yq != "":
+		out, err = eval(yjq.YqEvalJ2JC, s.Yq, string(in))
+	default:
+		out, err = sed(s.Sed, string(in))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, util.WriteYaml(filepath.Join(path, s.output()+".yaml"), out)
+}
+
+// eval collects all results of expr. A single result is kept as is, many become a list.
+func eval(evalFunc yjq.EvalFunc, expr string, input string) (any, error) {
+	// yjq formats the expression before parsing it
+	out, err := evalFunc("["+strings.ReplaceAll(expr, "%", "%%")+"]", input)
+	if err != nil {
+		return nil, err
+	}
+	var res []any
+	if err = json.Unmarshal([]byte(out), &res); err != nil {
+		return nil, err
+	}
+	if len(res) == 1 {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+func sed(expr string, input string) (any, error) {
+	y, err := yjq.J2Y(input)
+	if err != nil {
+		return nil, err
+	}
+	if y, err = util.Sed(expr, y); err != nil {
+		return nil, err
+	}
+	var out any
+	if err = yaml.Unmarshal([]byte(y), &out); err != nil {
+		return nil, fmt.Errorf("sed output is not yaml: %w", err)
+	}
+	return dump.Normalize(out), nil
+}
@@ -0,0 +1,14 @@
+package transform
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTransform(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Transform Suite")
+}
@@ -0,0 +1,115 @@
+package transform
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+
+	"adoption.latam/hcr/internal/pkg/dump"
+)
+
+const nodes = `apiVersion: v1
+kind: NodeList
+metadata:
+  apiName: nodes
+items:
+- metadata:
+    name: master-0
+    labels:
+      node-role.kubernetes.io/master: ""
+- metadata:
+    name: worker-0
+    labels:
+      node-role.kubernetes.io/worker: ""
+`
+
+var _ = Describe("Transform", func() {
+	var (
+		d    *dump.Dump
+		path string
+	)
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "nodes.yaml"), []byte(nodes), 0644)).To(Succeed())
+		var err error
+		d, err = dump.Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(GinkgoT().TempDir(), "data")
+	})
+
+	read := func(output string) any {
+		b, err := os.ReadFile(filepath.Join(path, output+".yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		var v any
+		Expect(yaml.Unmarshal(b, &v)).To(Succeed())
+		return v
+	}
+
+	DescribeTable("steps that run",
+		func(steps []Step, output string, expected any) {
+			results, err := Run(d, steps, path)
+			Expect(err).NotTo(HaveOccurred())
+			for _, r := range results {
+				Expect(r.Status).To(Equal(StatusOk), r.Error)
+			}
+			Expect(read(output)).To(Equal(expected))
+		},
+		Entry("jq over the dumped resources",
+			[]Step{{Name: "names", Inputs: []string{"nodes.v1"}, Jq: `[.["nodes.v1"][].metadata.name]`}},
+			"names", []any{"master-0", "worker-0"}),
+		Entry("yq over the output of a dependency",
+			[]Step{
+				{Name: "names", Inputs: []string{"nodes.v1"}, Jq: `[.["nodes.v1"][].metadata.name]`},
+				{Name: "count", DependsOn: []string{"names"}, Yq: `.names | length`},
+			},
+			"count", 2),
+		Entry("sed over the input as yaml",
+			[]Step{{Name: "masked", Inputs: []string{"nodes.v1"}, Sed: `s/worker-0/worker-x/g`}},
+			"masked", map[string]any{"nodes.v1": []any{
+				map[string]any{"metadata": map[string]any{"name": "master-0", "labels": map[string]any{"node-role.kubernetes.io/master": ""}}},
+				map[string]any{"metadata": map[string]any{"name": "worker-x", "labels": map[string]any{"node-role.kubernetes.io/worker": ""}}},
+			}}),
+		Entry("an output other than the name",
+			[]Step{
+				{Name: "names", Inputs: []string{"nodes.v1"}, Jq: `[.["nodes.v1"][].metadata.name]`, Output: "node-names"},
+				{Name: "first", DependsOn: []string{"names"}, Jq: `.["node-names"][0]`},
+			},
+			"first", "master-0"),
+	)
+
+	DescribeTable("steps that do not run",
+		func(steps []Step, status string, expected string) {
+			results, err := Run(d, steps, path)
+			Expect(err).NotTo(HaveOccurred())
+			last := results[len(results)-1]
+			Expect(last.Status).To(Equal(status))
+			Expect(last.Error).To(ContainSubstring(expected))
+		},
+		Entry("without an operation", []Step{{Name: "a"}}, StatusError, "exactly one of jq, yq or sed"),
+		Entry("with two operations", []Step{{Name: "a", Jq: ".", Yq: "."}}, StatusError, "exactly one of jq, yq or sed"),
+		Entry("with an unknown dependency", []Step{{Name: "a", DependsOn: []string{"b"}, Jq: "."}}, StatusError, "unknown dependency 'b'"),
+		Entry("in a dependency cycle",
+			[]Step{{Name: "a", DependsOn: []string{"b"}, Jq: "."}, {Name: "b", DependsOn: []string{"a"}, Jq: "."}},
+			StatusError, "dependency cycle"),
+		Entry("after a failed dependency",
+			[]Step{{Name: "a", Jq: `error("boom")`}, {Name: "b", DependsOn: []string{"a"}, Jq: "."}},
+			StatusSkipped, "dependency 'a' did not succeed"),
+	)
+
+	DescribeTable("pipelines rejected as a whole",
+		func(steps []Step, expected string) {
+			_, err := Run(d, steps, path)
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+		},
+		Entry("with a step without name", []Step{{Jq: "."}}, "names must be unique and not empty"),
+		Entry("with two steps of a name", []Step{{Name: "a", Jq: "."}, {Name: "a", Yq: "."}}, "names must be unique and not empty: 'a'"),
+		Entry("with two steps of an output", []Step{{Name: "a", Jq: "."}, {Name: "b", Output: "a", Jq: "."}}, "outputs must be unique file names: 'a'"),
+		Entry("with an output out of the path", []Step{{Name: "a", Output: "../a", Jq: "."}}, "outputs must be unique file names: '../a'"),
+		Entry("with an output in a directory", []Step{{Name: "a", Output: "x/a", Jq: "."}}, "outputs must be unique file names: 'x/a'"),
+		Entry("with an absolute output", []Step{{Name: "/tmp/a", Jq: "."}}, "outputs must be unique file names: '/tmp/a'"),
+	)
+})
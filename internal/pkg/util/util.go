@@ -1,6 +1,7 @@
 package util
 
 import (
+	"bytes"
 	"os"
 	"strings"
 
@@ -12,19 +13,28 @@ import (
 
 func ValidateJson(yamlInput string, jsonSchemaAsYaml string) error {
 	var (
-		input        map[string]interface{}
+		input        any
+		inputString  string
 		schemaString string
+		schemaDoc    any
 		schema       *jsonschema.Schema
 		err          error
 	)
-	if err = yaml.Unmarshal([]byte(yamlInput), &input); err != nil {
+	// go through json so the validator gets the value types it understands
+	if inputString, err = yjq.Y2JC(yamlInput); err != nil {
+		return err
+	}
+	if input, err = jsonschema.UnmarshalJSON(strings.NewReader(inputString)); err != nil {
 		return err
 	}
 	if schemaString, err = yjq.Y2JC(jsonSchemaAsYaml); err != nil {
 		return err
 	}
+	if schemaDoc, err = jsonschema.UnmarshalJSON(strings.NewReader(schemaString)); err != nil {
+		return err
+	}
 	compiler := jsonschema.NewCompiler()
-	if err = compiler.AddResource("schema.json", strings.NewReader(schemaString)); err != nil {
+	if err = compiler.AddResource("schema.json", schemaDoc); err != nil {
 		return err
 	}
 	if schema, err = compiler.Compile("schema.json"); err != nil {
@@ -55,3 +65,22 @@ func Sed(expr string, input string) (string, error) {
 	}
 	return s, e
 }
+
+func ToYaml(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	err := enc.Close()
+	return b.Bytes(), err
+}
+
+func WriteYaml(file string, v any) error {
+	b, err := ToYaml(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0644)
+}
@@ -0,0 +1,163 @@
+package waiver
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+const dateLayout = "2006-01-02"
+
+// Object selects findings by object. Empty fields match anything and every field accepts
+// shell patterns as in path.Match.
+type Object struct {
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// Waiver accepts the findings of checks for the selected namespaces or objects until it expires.
+// Without namespaces and objects it covers every finding of the checks.
+type Waiver struct {
+	Name          string   `json:"name"`
+	Checks        []string `json:"checks"`
+	Namespaces    []string `json:"namespaces,omitempty"`
+	Objects       []Object `json:"objects,omitempty"`
+	Justification string   `json:"justification"`
+	Approver      string   `json:"approver"`
+	Expires       string   `json:"expires"`
+}
+
+// Waived is a finding and the waiver that accepted it.
+type Waived struct {
+	check.Finding
+	Waiver Waiver `json:"waiver"`
+}
+
+// Validate checks every waiver carries what an auditor asks for and names the unnamed ones.
+func Validate(waivers []Waiver) error {
+	for i := range waivers {
+		w := &waivers[i]
+		if w.Name == "" {
+			w.Name = fmt.Sprintf("waiver-%d", i)
+		}
+		if len(w.Checks) == 0 {
+			return fmt.Errorf("waiver %s: no checks", w.Name)
+		}
+		if w.Justification == "" || w.Approver == "" {
+			return fmt.Errorf("waiver %s: justification and approver are mandatory", w.Name)
+		}
+		if _, err := w.ExpiresAt(); err != nil {
+			return fmt.Errorf("waiver %s: %w", w.Name, err)
+		}
+		patterns := append(slices.Clone(w.Namespaces), w.Checks...)
+		for _, o := range w.Objects {
+			patterns = append(patterns, o.Kind, o.Namespace, o.Name)
+		}
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("waiver %s: pattern %s: %w", w.Name, p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ExpiresAt parses expires as a RFC3339 time or as a date, in which case the waiver holds through that whole day (UTC).
+func (w Waiver) ExpiresAt() (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, w.Expires); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(dateLayout, w.Expires)
+	if err != nil {
+		return t, fmt.Errorf("expires: expected a date (%s) or a RFC3339 time: %s", dateLayout, w.Expires)
+	}
+	return t.AddDate(0, 0, 1), nil
+}
+
+func (w Waiver) Expired(now time.Time) bool {
+	t, err := w.ExpiresAt()
+	return err != nil || !now.Before(t)
+}
+
+func (w Waiver) Matches(f check.Finding) bool {
+	if !anyMatch(w.Checks, f.CheckId) {
+		return false
+	}
+	if len(w.Namespaces) == 0 && len(w.Objects) == 0 {
+		return true
+	}
+	if f.Namespace != "" && anyMatch(w.Namespaces, f.Namespace) {
+		return true
+	}
+	for _, o := range w.Objects {
+		if match(o.Kind, f.Kind) && match(o.Namespace, f.Namespace) && match(o.Name, f.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply moves the findings accepted by a current waiver out of the results. A failed check left
+// without findings passes. Expired waivers accept nothing and are returned to be flagged.
+func Apply(waivers []Waiver, results []check.Result, now time.Time) ([]check.Result, []Waived, []Waiver) {
+	current := []Waiver{}
+	expired := []Waiver{}
+	for _, w := range waivers {
+		if w.Expired(now) {
+			expired = append(expired, w)
+		} else {
+			current = append(current, w)
+		}
+	}
+	waived := []Waived{}
+	kept := make([]check.Result, len(results))
+	for i, r := range results {
+		kept[i] = r
+		if len(r.Findings) == 0 || len(current) == 0 {
+			continue
+		}
+		kept[i].Findings = nil
+		for _, f := range r.Findings {
+			if w := find(current, f); w != nil {
+				waived = append(waived, Waived{Finding: f, Waiver: *w})
+			} else {
+				kept[i].Findings = append(kept[i].Findings, f)
+			}
+		}
+		if r.Status == check.StatusFail && len(kept[i].Findings) == 0 {
+			kept[i].Status = check.StatusPass
+		}
+	}
+	return kept, waived, expired
+}
+
+func find(waivers []Waiver, f check.Finding) *Waiver {
+	for i := range waivers {
+		if waivers[i].Matches(f) {
+			return &waivers[i]
+		}
+	}
+	return nil
+}
+
+func anyMatch(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// match treats an empty pattern as a wildcard.
+func match(pattern string, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
@@ -0,0 +1,87 @@
+package waiver
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"adoption.latam/hcr/internal/pkg/check"
+)
+
+var _ = Describe("Waiver", func() {
+	valid := func(w Waiver) Waiver {
+		w.Justification, w.Approver = "known", "admin@example.com"
+		if w.Expires == "" {
+			w.Expires = "2026-12-31"
+		}
+		return w
+	}
+
+	DescribeTable("validating waivers",
+		func(w Waiver, expected string) {
+			err := Validate([]Waiver{w})
+			if expected == "" {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(ContainSubstring(expected)))
+			}
+		},
+		Entry("a complete waiver", valid(Waiver{Name: "w", Checks: []string{"OBS-*"}}), ""),
+		Entry("an expiry time", valid(Waiver{Name: "w", Checks: []string{"OBS-1"}, Expires: "2026-12-31T12:00:00Z"}), ""),
+		Entry("a waiver without checks", valid(Waiver{Name: "w"}), "waiver w: no checks"),
+		Entry("a waiver without approver", Waiver{Name: "w", Checks: []string{"A"}, Justification: "j", Expires: "2026-01-01"}, "justification and approver are mandatory"),
+		Entry("a waiver without expiry", Waiver{Name: "w", Checks: []string{"A"}, Justification: "j", Approver: "a"}, "expires"),
+		Entry("an expiry that is not a date", valid(Waiver{Name: "w", Checks: []string{"A"}, Expires: "31/12/2026"}), "expected a date"),
+		Entry("a bad pattern", valid(Waiver{Name: "w", Checks: []string{"OBS-["}}), "pattern OBS-["),
+		Entry("a bad object pattern", valid(Waiver{Name: "w", Checks: []string{"A"}, Objects: []Object{{Kind: "ConfigMap", Name: "legacy-["}}}), "pattern legacy-["),
+		Entry("an unnamed waiver", valid(Waiver{}), "waiver waiver-0: no checks"),
+	)
+
+	DescribeTable("expiring",
+		func(expires string, now string, expired bool) {
+			at, err := time.Parse(time.RFC3339, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(Waiver{Expires: expires}.Expired(at)).To(Equal(expired))
+		},
+		Entry("holds through the whole day of a date", "2026-10-19", "2026-10-19T23:59:59Z", false),
+		Entry("ends with the day of a date", "2026-10-19", "2026-10-20T00:00:00Z", true),
+		Entry("ends at a time", "2026-10-19T12:00:00Z", "2026-10-19T12:00:00Z", true),
+		Entry("has expired when the expiry does not parse", "soon", "2000-01-01T00:00:00Z", true),
+	)
+
+	f := check.Finding{CheckId: "OBS-8", Kind: "PrometheusRule", Namespace: "app", Name: "legacy-rules"}
+
+	DescribeTable("matching findings",
+		func(w Waiver, matches bool) {
+			Expect(w.Matches(f)).To(Equal(matches))
+		},
+		Entry("by check alone", Waiver{Checks: []string{"OBS-8"}}, true),
+		Entry("by check pattern", Waiver{Checks: []string{"OBS-*"}}, true),
+		Entry("not of other checks", Waiver{Checks: []string{"OBS-1"}}, false),
+		Entry("by namespace pattern", Waiver{Checks: []string{"OBS-8"}, Namespaces: []string{"ap*"}}, true),
+		Entry("not of other namespaces", Waiver{Checks: []string{"OBS-8"}, Namespaces: []string{"openshift-*"}}, false),
+		Entry("by object", Waiver{Checks: []string{"OBS-8"}, Objects: []Object{{Kind: "PrometheusRule", Name: "legacy-*"}}}, true),
+		Entry("not of other objects", Waiver{Checks: []string{"OBS-8"}, Objects: []Object{{Kind: "ConfigMap"}}}, false),
+	)
+
+	It("moves the waived findings out and flags the expired waivers", func() {
+		now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+		waivers := []Waiver{
+			valid(Waiver{Name: "current", Checks: []string{"OBS-8"}}),
+			valid(Waiver{Name: "expired", Checks: []string{"OBS-9"}, Expires: "2026-01-01"}),
+		}
+		results := []check.Result{
+			{Check: check.Check{Id: "OBS-8"}, Status: check.StatusFail, Findings: []check.Finding{f}},
+			{Check: check.Check{Id: "OBS-9"}, Status: check.StatusFail, Findings: []check.Finding{{CheckId: "OBS-9", Name: "x"}}},
+		}
+		kept, waived, expired := Apply(waivers, results, now)
+		Expect(kept[0].Status).To(Equal(check.StatusPass))
+		Expect(kept[0].Findings).To(BeEmpty())
+		Expect(kept[1].Status).To(Equal(check.StatusFail))
+		Expect(kept[1].Findings).To(HaveLen(1))
+		Expect(waived).To(Equal([]Waived{{Finding: f, Waiver: waivers[0]}}))
+		Expect(expired).To(Equal([]Waiver{waivers[1]}))
+		Expect(results[0].Findings).To(HaveLen(1), "the results handed in are left as they are")
+	})
+})
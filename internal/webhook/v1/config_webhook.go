@@ -18,6 +18,7 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"go.uber.org/zap"
@@ -27,6 +28,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	hcrv1 "adoption.latam/hcr/api/v1"
+	"adoption.latam/hcr/internal/pkg/hcr"
 	"adoption.latam/hcr/internal/pkg/util/log"
 )
 
@@ -38,9 +40,60 @@ var logger = log.Logger().Named("hcr.cfg.hook")
 func SetupConfigWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&hcrv1.Config{}).
 		WithValidator(&ConfigCustomValidator{}).
+		WithDefaulter(&ConfigCustomDefaulter{}).
 		Complete()
 }
 
+// +kubebuilder:webhook:path=/mutate-hcr-adoption-latam-v1-config,mutating=true,failurePolicy=fail,sideEffects=None,groups=hcr.adoption.latam,resources=configs,verbs=create;update,versions=v1,name=mconfig-v1.kb.io,admissionReviewVersions=v1
+
+// ConfigCustomDefaulter records who approves a remediation plan. Whenever the approval annotation
+// changes it sets the approved-by annotation to the user of the request, otherwise it keeps the
+// one recorded before so nobody can claim an approval as someone else.
+type ConfigCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &ConfigCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type Config.
+func (d *ConfigCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	config, ok := obj.(*hcrv1.Config)
+	if !ok {
+		return fmt.Errorf("expected a Config object but got %T", obj)
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	old := &hcrv1.Config{}
+	if len(req.OldObject.Raw) > 0 {
+		if err = json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return err
+		}
+	}
+	approval := config.Annotations[hcr.AnnotationApproveRemediation]
+	approvedBy := old.Annotations[hcr.AnnotationApprovedBy]
+	if approval != old.Annotations[hcr.AnnotationApproveRemediation] {
+		approvedBy = ""
+		if approval != "" {
+			b, err := json.Marshal(hcr.Approver{Approval: approval, User: req.UserInfo})
+			if err != nil {
+				return err
+			}
+			approvedBy = string(b)
+			logger.Info("remediation approved", zap.String("name", config.GetName()), zap.String("approval", approval),
+				zap.String("user", req.UserInfo.Username))
+		}
+	}
+	if approvedBy == "" {
+		delete(config.Annotations, hcr.AnnotationApprovedBy)
+		return nil
+	}
+	if config.Annotations == nil {
+		config.Annotations = map[string]string{}
+	}
+	config.Annotations[hcr.AnnotationApprovedBy] = approvedBy
+	return nil
+}
+
 // TODO(user): EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
@@ -17,10 +17,19 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"encoding/json"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	admissionv1 "k8s.io/api/admission/v1"
+	authnv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
 	hcrv1 "adoption.latam/hcr/api/v1"
+	"adoption.latam/hcr/internal/pkg/hcr"
 	// TODO (user): Add any additional imports if needed
 )
 
@@ -68,4 +77,43 @@ var _ = Describe("Config Webhook", func() {
 		// })
 	})
 
+	Context("When approving a remediation plan under Defaulting Webhook", func() {
+		const approval = "20261019T000000Z:all"
+		// request defaults obj as updated from oldObj by user.
+		request := func(user string) {
+			raw, err := json.Marshal(oldObj)
+			Expect(err).NotTo(HaveOccurred())
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo:  authnv1.UserInfo{Username: user, Groups: []string{"ops"}},
+				OldObject: runtime.RawExtension{Raw: raw},
+			}}
+			Expect((&ConfigCustomDefaulter{}).Default(admission.NewContextWithRequest(context.Background(), req), obj)).To(Succeed())
+		}
+		approver := func() hcr.Approver {
+			a := hcr.Approver{}
+			Expect(json.Unmarshal([]byte(obj.Annotations[hcr.AnnotationApprovedBy]), &a)).To(Succeed())
+			return a
+		}
+
+		It("records the user who sets the approval", func() {
+			obj.Annotations = map[string]string{hcr.AnnotationApproveRemediation: approval}
+			request("alice")
+			Expect(approver()).To(Equal(hcr.Approver{Approval: approval, User: authnv1.UserInfo{Username: "alice", Groups: []string{"ops"}}}))
+		})
+
+		It("keeps the approver recorded when someone else updates the Config", func() {
+			obj.Annotations = map[string]string{hcr.AnnotationApproveRemediation: approval}
+			request("alice")
+			oldObj = obj.DeepCopy()
+			obj.Annotations[hcr.AnnotationApprovedBy] = `{"approval":"` + approval + `","user":{"username":"mallory"}}`
+			request("mallory")
+			Expect(approver().User.Username).To(Equal("alice"))
+		})
+
+		It("drops the approver with the approval", func() {
+			obj.Annotations = map[string]string{hcr.AnnotationApprovedBy: `{"approval":"` + approval + `","user":{"username":"mallory"}}`}
+			request("mallory")
+			Expect(obj.Annotations).NotTo(HaveKey(hcr.AnnotationApprovedBy))
+		})
+	})
 })